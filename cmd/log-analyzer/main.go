@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"kinsta-log-analyzer/pkg/analyzer"
@@ -22,6 +23,12 @@ var (
 	outputDir  = flag.String("output", "./output", "Output directory for reports")
 	showVersion = flag.Bool("version", false, "Show version information")
 	verbose    = flag.Bool("verbose", false, "Enable verbose logging")
+	compareFile = flag.String("compare", "", "Earlier log file to compare --input against (compare mode)")
+	splitAt    = flag.String("split-at", "", "Compare --input before/after this time (JST \"2006-01-02 15:04\" or RFC3339)")
+	stateDir   = flag.String("state-dir", "", "Directory of saved run summaries; enables baseline comparison")
+	saveSnapshot = flag.String("save-snapshot", "", "Also save the analyzer state to this file for later --merge")
+	mergeFiles = flag.String("merge", "", "Comma-separated snapshot files to merge into the report (--input optional)")
+	workers    = flag.Int("workers", 1, "Number of parser workers and aggregator shards (1 = sequential)")
 )
 
 func main() {
@@ -46,15 +53,28 @@ func main() {
 		os.Exit(0)
 	}
 
-	if *inputFile == "" {
+	if *inputFile == "" && *mergeFiles == "" {
 		fmt.Fprintf(os.Stderr, "Error: --input flag is required\n")
 		flag.Usage()
 		os.Exit(1)
 	}
 
 	// Check if input file exists
-	if _, err := os.Stat(*inputFile); os.IsNotExist(err) {
-		log.Fatalf("Error: Input file does not exist: %s", *inputFile)
+	if *inputFile != "" {
+		if _, err := os.Stat(*inputFile); os.IsNotExist(err) {
+			log.Fatalf("Error: Input file does not exist: %s", *inputFile)
+		}
+	}
+	if *mergeFiles != "" && (*compareFile != "" || *splitAt != "") {
+		log.Fatalf("Error: --merge cannot be used with --compare or --split-at")
+	}
+	if *compareFile != "" && *splitAt != "" {
+		log.Fatalf("Error: --compare and --split-at cannot be used together")
+	}
+	if *compareFile != "" {
+		if _, err := os.Stat(*compareFile); os.IsNotExist(err) {
+			log.Fatalf("Error: Compare file does not exist: %s", *compareFile)
+		}
 	}
 
 	// Load configuration
@@ -66,25 +86,58 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	if crs := cfg.CRSImport(); crs != nil && *verbose {
+		log.Printf("Imported %d CRS rules from %d files (%d skipped)", crs.Imported, len(crs.Files), len(crs.Skipped))
+	}
 
 	// Override output directory if specified via command line
 	if *outputDir != "./output" {
 		cfg.Output.OutputDirectory = *outputDir
 	}
+	if *stateDir != "" {
+		cfg.Baseline.Enabled = true
+		cfg.Baseline.StateDir = *stateDir
+	}
+
+	if *compareFile != "" || *splitAt != "" {
+		runCompare(cfg)
+		return
+	}
 
 	// Create analyzer
 	analyzer := analyzer.NewAnalyzer(cfg)
+	analyzer.SetWorkers(*workers)
+
+	startTime := time.Now()
 
 	// Analyze the log file
-	if *verbose {
-		log.Printf("Starting analysis of: %s", *inputFile)
+	if *inputFile != "" {
+		if *verbose {
+			log.Printf("Starting analysis of: %s", *inputFile)
+		}
+		if err := analyzer.ProcessFile(*inputFile); err != nil {
+			log.Fatalf("Analysis failed: %v", err)
+		}
 	}
-	
-	startTime := time.Now()
-	result, err := analyzer.AnalyzeFile(*inputFile)
-	if err != nil {
-		log.Fatalf("Analysis failed: %v", err)
+
+	// Save this input's state before merging or closing open sessions
+	if *saveSnapshot != "" {
+		if err := writeSnapshot(analyzer, *saveSnapshot); err != nil {
+			log.Fatalf("Failed to save snapshot: %v", err)
+		}
+		if *verbose {
+			log.Printf("Saved snapshot: %s", *saveSnapshot)
+		}
 	}
+
+	// Merge pre-aggregated snapshots
+	if *mergeFiles != "" {
+		if err := mergeSnapshots(cfg, analyzer, strings.Split(*mergeFiles, ",")); err != nil {
+			log.Fatalf("Merge failed: %v", err)
+		}
+	}
+
+	result := analyzer.Result()
 	duration := time.Since(startTime)
 
 	if *verbose {
@@ -92,6 +145,11 @@ func main() {
 		log.Printf("Processed %d requests", result.Summary.TotalRequests)
 	}
 
+	// Compare against previous runs, then record this one
+	if cfg.Baseline.Enabled {
+		result.Baseline = runBaseline(cfg, result)
+	}
+
 	// Generate report
 	if *verbose {
 		log.Printf("Generating report in: %s", cfg.Output.OutputDirectory)
@@ -107,6 +165,155 @@ func main() {
 	printSummary(result, reportPath, duration)
 }
 
+func writeSnapshot(a *analyzer.Analyzer, path string) error {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create snapshot directory: %v", err)
+		}
+	}
+	return analyzer.WriteSnapshot(path, a.Snapshot())
+}
+
+// mergeSnapshots restores each snapshot file and merges it into a.
+func mergeSnapshots(cfg *config.Config, a *analyzer.Analyzer, paths []string) error {
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		if *verbose {
+			log.Printf("Merging snapshot: %s", path)
+		}
+		snap, err := analyzer.ReadSnapshot(path)
+		if err != nil {
+			return err
+		}
+		other, err := analyzer.NewAnalyzerFromSnapshot(cfg, snap)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if err := a.Merge(other); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// runBaseline scores result against the saved summaries of previous runs and
+// saves a summary of this run. State directory problems are logged rather
+// than fatal so the regular report is still produced.
+func runBaseline(cfg *config.Config, result *analyzer.AnalysisResult) analyzer.BaselineAnalysis {
+	label := filepath.Base(*inputFile)
+	if *mergeFiles != "" {
+		label = "merge:" + *mergeFiles
+	}
+	current := analyzer.NewRunSummary(label, result)
+
+	bc := analyzer.BaselineSettings(cfg)
+	history, err := analyzer.LoadBaseline(bc.StateDir, bc.Runs)
+	if err != nil {
+		log.Printf("Warning: failed to load baseline: %v", err)
+	}
+	baseline := analyzer.CompareToBaseline(current, history, cfg)
+
+	path, err := analyzer.SaveRunSummary(bc.StateDir, current, bc.Runs)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	} else if *verbose {
+		log.Printf("Saved run summary: %s", path)
+	}
+	return baseline
+}
+
+// runCompare analyzes two inputs — --compare vs --input, or --input before
+// and after --split-at — and writes a comparison report instead of the
+// regular one.
+func runCompare(cfg *config.Config) {
+	beforeLabel, afterLabel := filepath.Base(*compareFile), filepath.Base(*inputFile)
+	beforeFile := *compareFile
+	var split time.Time
+	if *splitAt != "" {
+		var err error
+		split, err = parseSplitTime(*splitAt)
+		if err != nil {
+			log.Fatalf("Invalid --split-at: %v", err)
+		}
+		beforeFile = *inputFile
+		beforeLabel = fmt.Sprintf("%s (%s より前)", afterLabel, split.In(utils.JST).Format("2006-01-02 15:04:05"))
+		afterLabel = fmt.Sprintf("%s (%s 以降)", afterLabel, split.In(utils.JST).Format("2006-01-02 15:04:05"))
+	}
+
+	startTime := time.Now()
+
+	beforeAnalyzer := analyzer.NewAnalyzer(cfg)
+	afterAnalyzer := analyzer.NewAnalyzer(cfg)
+	beforeAnalyzer.SetWorkers(*workers)
+	afterAnalyzer.SetWorkers(*workers)
+	if !split.IsZero() {
+		beforeAnalyzer.SetTimeWindow(time.Time{}, split)
+		afterAnalyzer.SetTimeWindow(split, time.Time{})
+	}
+
+	if *verbose {
+		log.Printf("Analyzing before: %s", beforeLabel)
+	}
+	before, err := beforeAnalyzer.AnalyzeFile(beforeFile)
+	if err != nil {
+		log.Fatalf("Analysis failed: %v", err)
+	}
+	if *verbose {
+		log.Printf("Analyzing after: %s", afterLabel)
+	}
+	after, err := afterAnalyzer.AnalyzeFile(*inputFile)
+	if err != nil {
+		log.Fatalf("Analysis failed: %v", err)
+	}
+	duration := time.Since(startTime)
+
+	cmp := analyzer.Compare(before, after)
+	cmp.BeforeLabel = beforeLabel
+	cmp.AfterLabel = afterLabel
+
+	reporter := report.NewMarkdownReporter(cfg.Output.OutputDirectory)
+	reportPath, err := reporter.GenerateComparisonReport(cmp)
+	if err != nil {
+		log.Fatalf("Failed to generate report: %v", err)
+	}
+
+	printComparison(cmp, reportPath, duration)
+}
+
+// parseSplitTime accepts RFC3339, or "2006-01-02 15:04[:05]" interpreted as JST.
+func parseSplitTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, s, utils.JST); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time %q", s)
+}
+
+func printComparison(cmp *analyzer.Comparison, reportPath string, duration time.Duration) {
+	fmt.Println("=== Kinsta ログ比較結果 ===")
+	fmt.Printf("解析時間: %v\n", duration)
+	fmt.Printf("レポート生成: %s\n\n", reportPath)
+
+	fmt.Printf("比較元: %s\n", cmp.BeforeLabel)
+	fmt.Printf("比較先: %s\n\n", cmp.AfterLabel)
+
+	fmt.Printf("総リクエスト数: %s → %s\n", utils.FormatNumber(cmp.Before.TotalRequests), utils.FormatNumber(cmp.After.TotalRequests))
+	fmt.Printf("リクエスト数/時間: %.1f → %.1f\n", cmp.RequestsPerHour.Before, cmp.RequestsPerHour.After)
+	fmt.Printf("エラー率: %.2f%% → %.2f%% (%+.2fpt)\n", cmp.ErrorRate.Before, cmp.ErrorRate.After, cmp.ErrorRate.Change)
+	fmt.Printf("95パーセンタイル: %.3f秒 → %.3f秒\n", cmp.Percentile95.Before, cmp.Percentile95.After)
+	fmt.Printf("新たに上位に現れたエラーURL: %d\n", len(cmp.NewErrorURLs))
+	fmt.Printf("新たな攻撃元IP: %d\n\n", len(cmp.NewAttackingIPs))
+
+	fmt.Printf("📊 詳細レポート: %s\n", reportPath)
+}
+
 func printSummary(result *analyzer.AnalysisResult, reportPath string, duration time.Duration) {
 	fmt.Println("=== Kinsta ログ解析結果 ===")
 	fmt.Printf("解析時間: %v\n", duration)
@@ -114,6 +321,7 @@ func printSummary(result *analyzer.AnalysisResult, reportPath string, duration t
 
 	// Basic statistics
 	fmt.Printf("総リクエスト数: %s\n", utils.FormatNumber(result.Summary.TotalRequests))
+	fmt.Printf("ユニークIP数(推定): %s (±%s)\n", utils.FormatNumber(result.Summary.Uniques.IPs.Estimate), utils.FormatNumber(result.Summary.Uniques.IPs.ErrorMargin))
 	fmt.Printf("エラー率: %.2f%%\n", result.Summary.ErrorRate)
 	fmt.Printf("平均レスポンス時間: %.3f秒\n\n", result.Summary.AvgResponseTime)
 
@@ -121,13 +329,70 @@ func printSummary(result *analyzer.AnalysisResult, reportPath string, duration t
 	fmt.Println("セキュリティ分析:")
 	fmt.Printf("  SQLインジェクション試行: %s\n", utils.FormatNumber(result.SecurityAnalysis.SQLInjectionAttempts))
 	fmt.Printf("  XSS試行: %s\n", utils.FormatNumber(result.SecurityAnalysis.XSSAttempts))
+	fmt.Printf("  パストラバーサル試行: %s\n", utils.FormatNumber(result.SecurityAnalysis.PathTraversalAttempts))
+	fmt.Printf("  LFI/RFI試行: %s / %s\n", utils.FormatNumber(result.SecurityAnalysis.LFIAttempts), utils.FormatNumber(result.SecurityAnalysis.RFIAttempts))
+	fmt.Printf("  機密ファイルへのリクエスト: %s（漏洩の可能性: %d件）\n", utils.FormatNumber(result.Exposure.Requests), len(result.Exposure.Leaks))
+	fmt.Printf("  一致した検出ルール: %d件\n", len(result.SecurityAnalysis.Rules))
+	if cves := probedCVEs(result.SecurityAnalysis.CVERules); len(cves) > 0 {
+		fmt.Printf("  探索されたCVE: %s\n", strings.Join(cves, ", "))
+	} else {
+		fmt.Println("  探索されたCVE: なし")
+	}
 	fmt.Printf("  疑わしいIP: %d\n\n", len(result.SecurityAnalysis.SuspiciousIPs))
 
+	// WordPress summary
+	wp := result.WordPress
+	fmt.Println("WordPress:")
+	fmt.Printf("  ログイン試行: %s（失敗 %s / 成功 %s）、ブルートフォースIP: %d\n", utils.FormatNumber(wp.Login.Attempts),
+		utils.FormatNumber(wp.Login.Failures), utils.FormatNumber(wp.Login.Successes), len(wp.Login.BruteForceIPs))
+	if wp.Distributed.WindowMinutes > 0 {
+		fmt.Printf("  分散型ブルートフォース: %d件（%s IP）\n", len(wp.Distributed.Campaigns), utils.FormatNumber(campaignIPs(wp.Distributed.Campaigns)))
+	}
+	fmt.Printf("  xmlrpc.php POST: %s\n", utils.FormatNumber(wp.XMLRPC.Posts))
+	fmt.Printf("  ユーザー列挙: %s\n", utils.FormatNumber(wp.UserEnum.AuthorRequests+wp.UserEnum.RESTRequests))
+	fmt.Printf("  プラグイン・テーマ探索: %s（%d スラッグ）\n", utils.FormatNumber(wp.Probes.Requests), wp.Probes.Slugs)
+	if wp.Probes.VulnDB > 0 {
+		fmt.Printf("  既知の脆弱性を持つ探索対象: %d（サイト上に存在の可能性: %d）\n", len(wp.Probes.Vulnerable), len(exposedComponents(wp.Probes.Vulnerable)))
+	}
+	fmt.Println()
+
+	// Rate limit recommendations
+	if recommended := recommendedRateLimits(result.RateLimits); len(recommended) > 0 {
+		fmt.Println("レート制限の推奨値 (Nginx limit_req):")
+		for _, c := range recommended {
+			fmt.Printf("  %s: rate=%s burst=%d（制限: 悪用 %s件 / %s IP、正規 %s件 / %s IP）\n", c.Class, formatNginxRate(c.RatePerMinute), c.Burst,
+				utils.FormatNumber(c.LimitedAbusiveRequests), utils.FormatNumber(c.LimitedAbusiveClients),
+				utils.FormatNumber(c.LimitedLegitRequests), utils.FormatNumber(c.LimitedLegitClients))
+		}
+		fmt.Println()
+	}
+
 	// Performance summary
 	fmt.Println("パフォーマンス分析:")
 	fmt.Printf("  遅いリクエスト(3秒超): %s\n", utils.FormatNumber(result.Statistics.ResponseTimeStats.SlowRequests))
 	fmt.Printf("  最大レスポンス時間: %.3f秒\n", result.Statistics.ResponseTimeStats.Maximum)
-	fmt.Printf("  95パーセンタイル: %.3f秒\n\n", result.Statistics.ResponseTimeStats.Percentile95)
+	fmt.Printf("  95パーセンタイル: %.3f秒\n", result.Statistics.ResponseTimeStats.Percentile95)
+	fmt.Printf("  異常検知区間: %d\n\n", len(result.Anomalies.Intervals))
+
+	if result.Baseline.Enabled {
+		fmt.Println("ベースライン比較:")
+		if len(result.Baseline.Metrics) == 0 {
+			fmt.Printf("  過去の実行 %d件（%d件以上で比較開始）\n\n", result.Baseline.Runs, result.Baseline.MinRuns)
+		} else {
+			fmt.Printf("  過去 %d 回の実行と比較: 劣化 %d件\n", result.Baseline.Runs, result.Baseline.Regressions)
+			for _, m := range result.Baseline.Metrics {
+				if !m.Regression {
+					continue
+				}
+				route := m.Route
+				if route == "" {
+					route = "全体"
+				}
+				fmt.Printf("  ⚠️  %s %s: %.3f (ベースライン %.3f, スコア %.1f)\n", route, m.Name, m.Current, m.Median, m.Score)
+			}
+			fmt.Println()
+		}
+	}
 
 	// Top error summary
 	if len(result.HTTPErrors.TopErrorURLs) > 0 {
@@ -159,6 +424,11 @@ func printRecommendations(result *analyzer.AnalysisResult) {
 	recommendations := []string{}
 	
 	// Security recommendations
+	if leaks := result.Exposure.Leaks; len(leaks) > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("🚨 機密ファイル %d件（%s 等）が 2xx で配信されています - 直ちに削除・アクセス禁止し、含まれる認証情報を変更してください",
+				len(leaks), leaks[0].Path))
+	}
 	if len(result.SecurityAnalysis.SuspiciousIPs) > 0 {
 		recommendations = append(recommendations, 
 			fmt.Sprintf("🔒 疑わしいIP %d件のブロックを検討してください", len(result.SecurityAnalysis.SuspiciousIPs)))
@@ -170,18 +440,90 @@ func printRecommendations(result *analyzer.AnalysisResult) {
 			fmt.Sprintf("⚡ 遅いリクエスト %d件(3秒超)の調査をお勧めします", result.Statistics.ResponseTimeStats.SlowRequests))
 	}
 	
+	// HTTP method recommendations
+	if len(result.Methods.UnusualMethods) > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("🚫 通常使われないHTTPメソッド(TRACE/PROPFIND等)を送ったIP %d件 - Nginx で許可メソッドの制限を検討してください", len(result.Methods.UnusualMethods)))
+	}
+
+	// Redirect recommendations
+	if len(result.Redirects.Loops) > 0 || len(result.Redirects.Chains) > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("↪️  リダイレクトチェーン %d種 / ループ %d種を検出 - リダイレクト設定を最終URLへ直接向けてください",
+				len(result.Redirects.Chains), len(result.Redirects.Loops)))
+	}
+
+	// Hotlinking recommendations
+	if result.Referers.HotlinkRequests > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("🖼️  外部サイトからのホットリンク %d件(%s)を検出 - CDN/Nginx でリファラー制限を検討してください",
+				result.Referers.HotlinkRequests, utils.FormatBytes(result.Referers.HotlinkBytes)))
+	}
+
 	// Error rate recommendations
 	if result.Summary.ErrorRate > 5.0 {
 		recommendations = append(recommendations, 
 			fmt.Sprintf("❗ 高いエラー率 (%.2f%%) - エラー原因の調査が必要です", result.Summary.ErrorRate))
 	}
 	
+	// Client-closed (499) recommendations — usually the client gave up waiting on upstream
+	if closed := result.Statistics.StatusCodes[499]; closed > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("⏱️  クライアント切断(499) %d件 - upstream(PHP)の応答遅延・タイムアウトを確認してください", closed))
+	}
+
+	// Baseline regressions
+	if result.Baseline.Regressions > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("📉 過去 %d 回の実行と比べて劣化した指標 %d件 - デプロイ・設定変更やトラフィックの変化を確認してください",
+				result.Baseline.Runs, result.Baseline.Regressions))
+	}
+
 	// Security attack recommendations
 	if result.SecurityAnalysis.SQLInjectionAttempts > 0 || result.SecurityAnalysis.XSSAttempts > 0 {
 		recommendations = append(recommendations, 
-			"🛡️  追加のセキュリティ対策 (WAF、レート制限)の実装をお勧めします")
+			"🛡️  追加のセキュリティ対策 (WAF)の実装をお勧めします")
+	}
+	for _, c := range recommendedRateLimits(result.RateLimits) {
+		recommendations = append(recommendations,
+			fmt.Sprintf("🚦 %s へのレート制限 rate=%s burst=%d - 悪用リクエスト %s件（%s IP）を制限、正規クライアントの p%.0f は制限されません",
+				c.Class, formatNginxRate(c.RatePerMinute), c.Burst, utils.FormatNumber(c.LimitedAbusiveRequests),
+				utils.FormatNumber(c.LimitedAbusiveClients), result.RateLimits.Percentile))
+	}
+	if fileAttacks := result.SecurityAnalysis.PathTraversalAttempts + result.SecurityAnalysis.LFIAttempts + result.SecurityAnalysis.RFIAttempts; fileAttacks > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("📂 パストラバーサル/ファイルインクルード攻撃 %s件 - ファイルパスやURLを受け取るパラメータの検証と allow_url_include の無効化を確認してください",
+				utils.FormatNumber(fileAttacks)))
 	}
 	
+	if cves := probedCVEs(result.SecurityAnalysis.CVERules); len(cves) > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("🚨 既知の脆弱性を狙った攻撃を検出 (%s) - 該当ソフトウェアのバージョンとパッチ適用状況を確認してください", strings.Join(cves, ", ")))
+	}
+
+	// WordPress recommendations
+	if n := len(result.WordPress.Login.BruteForceIPs); n > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("🔐 wp-login.php へのブルートフォース %d IP - ログイン試行回数の制限と二要素認証を検討してください", n))
+	}
+	if campaigns := result.WordPress.Distributed.Campaigns; len(campaigns) > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("🕸️  多数のIPからの分散型ブルートフォース %d件（%s IP）- IP 単位の制限では防げないため、二要素認証・CAPTCHA・ログインURLの変更を検討してください",
+				len(campaigns), utils.FormatNumber(campaignIPs(campaigns))))
+	}
+	if result.WordPress.XMLRPC.Posts > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("📮 xmlrpc.php への POST %s件 - 利用していなければ XML-RPC を無効化してください", utils.FormatNumber(result.WordPress.XMLRPC.Posts)))
+	}
+	if exposed := exposedComponents(result.WordPress.Probes.Vulnerable); len(exposed) > 0 {
+		recommendations = append(recommendations,
+			fmt.Sprintf("🧩 探索された既知の脆弱性を持つプラグイン・テーマがサイト上に存在する可能性 (%s) - バージョンを確認し更新してください", strings.Join(exposed, ", ")))
+	}
+	if enum := result.WordPress.UserEnum; enum.AuthorRedirects > 0 || enum.RESTExposed > 0 {
+		recommendations = append(recommendations,
+			"👤 ユーザー名が列挙可能です - ?author=N のリダイレクトと REST API のユーザー一覧を制限してください")
+	}
+
 	if len(recommendations) == 0 {
 		recommendations = append(recommendations, "✅ 重大な問題は検出されませんでした")
 	}
@@ -237,5 +579,76 @@ func init() {
 		fmt.Fprintf(os.Stderr, "  %s --input /path/to/access.log\n", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "  %s --input /path/to/access.log --config custom.yaml --verbose\n", filepath.Base(os.Args[0]))
 		fmt.Fprintf(os.Stderr, "  %s --input /path/to/access.log --output /custom/output/dir\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s --input this-week.log --compare last-week.log\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s --input /path/to/access.log --state-dir ./state\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s --input mon.log --save-snapshot snapshots/mon.json\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s --merge snapshots/mon.json,snapshots/tue.json\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s --input /path/to/access.log --split-at \"2024-01-15 12:00\"\n", filepath.Base(os.Args[0]))
+		fmt.Fprintf(os.Stderr, "  %s --input /path/to/large-access.log --workers 8\n", filepath.Base(os.Args[0]))
+	}
+}
+
+// probedCVEs lists the CVEs of the rules that matched at least once, in
+// report order.
+func probedCVEs(cveRules []analyzer.RuleHit) []string {
+	var cves []string
+	seen := make(map[string]bool)
+	for _, r := range cveRules {
+		if r.Hits == 0 {
+			continue
+		}
+		for _, cve := range r.CVE {
+			if !seen[cve] {
+				seen[cve] = true
+				cves = append(cves, cve)
+			}
+		}
+	}
+	return cves
+}
+
+// exposedComponents lists the slugs of the probed vulnerable components that
+// answered 2xx or whose site version is affected.
+func exposedComponents(components []analyzer.VulnerableComponent) []string {
+	var slugs []string
+	for _, c := range components {
+		affected := false
+		for _, v := range c.Vulnerabilities {
+			affected = affected || v.Status == analyzer.VersionAffected
+		}
+		if c.Found > 0 || affected {
+			slugs = append(slugs, c.Slug)
+		}
+	}
+	return slugs
+}
+
+// campaignIPs sums the participating IPs of distributed brute force
+// campaigns; an IP taking part in several campaigns counts in each.
+func campaignIPs(campaigns []analyzer.LoginCampaign) int {
+	total := 0
+	for _, c := range campaigns {
+		total += c.IPs
+	}
+	return total
+}
+
+// recommendedRateLimits returns the classes that have a rate limit
+// recommendation.
+func recommendedRateLimits(rl analyzer.RateLimitAnalysis) []analyzer.RateLimitRecommendation {
+	var result []analyzer.RateLimitRecommendation
+	for _, c := range rl.Classes {
+		if c.RatePerMinute > 0 {
+			result = append(result, c)
+		}
 	}
-}
\ No newline at end of file
+	return result
+}
+
+// formatNginxRate writes a per-minute rate the way limit_req_zone takes it.
+func formatNginxRate(perMinute int) string {
+	if perMinute%60 == 0 {
+		return fmt.Sprintf("%dr/s", perMinute/60)
+	}
+	return fmt.Sprintf("%dr/m", perMinute)
+}
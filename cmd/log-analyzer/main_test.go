@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSplitTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Time
+		wantErr bool
+	}{
+		// Times without a zone are JST.
+		{"2024-01-15 12:00", time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC), false},
+		{"2024-01-15 12:00:30", time.Date(2024, 1, 15, 3, 0, 30, 0, time.UTC), false},
+		{"2024-01-15T12:00:00Z", time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC), false},
+		{"2024-01-15T12:00:00+09:00", time.Date(2024, 1, 15, 3, 0, 0, 0, time.UTC), false},
+		{"2024-01-15", time.Time{}, true},
+		{"12:00", time.Time{}, true},
+		{"", time.Time{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseSplitTime(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSplitTime(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseSplitTime(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -2,4 +2,4 @@ module kinsta-log-analyzer
 
 go 1.22
 
-require gopkg.in/yaml.v2 v2.4.0 // indirect
+require gopkg.in/yaml.v2 v2.4.0
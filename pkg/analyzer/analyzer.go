@@ -3,11 +3,14 @@ package analyzer
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
 	"time"
 
 	"kinsta-log-analyzer/pkg/config"
 	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/rules"
+	"kinsta-log-analyzer/pkg/sketch"
 	"kinsta-log-analyzer/pkg/utils"
 )
 
@@ -17,31 +20,63 @@ type AnalysisResult struct {
 	SecurityAnalysis  SecurityAnalysis
 	Statistics        Statistics
 	UserAgentAnalysis UserAgentAnalysis
+	Anomalies         AnomalyAnalysis
+	Sessions          SessionAnalysis
+	Uniques           UniqueAnalysis
+	Referers          RefererAnalysis
+	Methods           MethodAnalysis
+	Redirects         RedirectAnalysis
+	Upstream          UpstreamAnalysis
+	WordPress         WordPressAnalysis
+	Exposure          ExposureAnalysis
+	RateLimits        RateLimitAnalysis
+	Baseline          BaselineAnalysis // 過去の実行との比較（baseline 有効時のみ）
 }
 
 type Summary struct {
 	StartTime        time.Time
 	EndTime          time.Time
 	TotalRequests    int
+	StatusClasses    StatusClassCounts
 	ErrorRate        float64
 	AvgResponseTime  float64
+	Uniques          UniqueCounts // HyperLogLog 推定
+	ByClass          []ClassSummary
+}
+
+// StatusClassCounts はステータスコードのクラス（1xx〜5xx）別件数。
+// 範囲外のコード（0 や 600 以上）は Other に入る。
+type StatusClassCounts struct {
+	Informational int // 1xx
+	Success       int // 2xx
+	Redirection   int // 3xx
+	ClientError   int // 4xx
+	ServerError   int // 5xx
+	Other         int
 }
 
 type HTTPErrors struct {
 	ClientErrors      map[int]int // 4xx errors: status code -> count
 	ServerErrors      map[int]int // 5xx errors: status code -> count
 	TopErrorURLs      []URLError
-	ErrorURLsByStatus map[int][]URLError // statusCode -> Top URLs
+	URLsByStatus      map[int][]URLError // statusCode -> Top URLs（2xx/3xx を含む）
 }
 
 type SecurityAnalysis struct {
 	SQLInjectionAttempts int
 	XSSAttempts         int
+	PathTraversalAttempts int // ../ シーケンス（エンコード済みを含む）
+	LFIAttempts         int   // /etc/passwd、php:// などのローカルファイル読み取り
+	RFIAttempts         int   // パラメータ内のリモートURL
 	SuspiciousIPs       []SuspiciousIP
 	AttacksByIP         map[string]*IPAttacks
 	ErrorProneIPs       []IPErrorRate
 	BurstIPs            []BurstIP
 	ErrorSuspiciousIPs  []ErrorSuspiciousIP
+	Categories          []CategoryHit // カテゴリ別の検出（重大度・件数順）
+	Rules               []RuleHit     // ルール別の検出（重大度・件数順）
+	CVERules            []RuleHit     // CVE を持つ全ルール（未検出を含む。検出済み・最終検出の新しい順）
+	CRSImport           *rules.ImportReport // crs_files からのルール取り込み結果（未設定なら nil）
 }
 
 type Statistics struct {
@@ -52,6 +87,13 @@ type Statistics struct {
 	ResponseTimeStats  ResponseTimeStats
 	StatusCodes        map[int]int
 	SlowURLs           []URLError
+	TopNAccuracy       TopNAccuracy
+}
+
+// TopNAccuracy は Top-N 集計（Space-Saving）の精度。MaxError が 0 なら全件正確。
+type TopNAccuracy struct {
+	Capacity int // 0 は正確モード
+	MaxError int // 全カウンタ中の過大計上の上限
 }
 
 type UserAgentAnalysis struct {
@@ -62,14 +104,18 @@ type UserAgentAnalysis struct {
 }
 
 type URLError struct {
-	URL   string
-	Count int
+	URL        string
+	Count      int
+	ErrorBound int // Count の過大計上の上限（0 なら正確）
 }
 
 type SuspiciousIP struct {
 	IP               string
 	SQLAttempts      int
 	XSSAttempts      int
+	TraversalAttempts int
+	LFIAttempts      int
+	RFIAttempts      int
 	TotalRequests    int
 	AttackScore      int
 }
@@ -77,6 +123,9 @@ type SuspiciousIP struct {
 type IPAttacks struct {
 	SQLAttempts   int
 	XSSAttempts   int
+	TraversalAttempts int
+	LFIAttempts   int
+	RFIAttempts   int
 	TotalRequests int
 	ErrorCount    int
 }
@@ -115,19 +164,23 @@ type ErrorSuspiciousIP struct {
 }
 
 type IPCount struct {
-	IP    string
-	Count int
+	IP         string
+	Count      int
+	ErrorBound int // Count の過大計上の上限（0 なら正確）
 }
 
 type UACount struct {
-	UserAgent string
-	Count     int
+	UserAgent  string
+	Count      int
+	ErrorBound int // Count の過大計上の上限（0 なら正確）
 }
 
 type ResponseTimeStats struct {
-	Average     float64
-	Maximum     float64
+	Average      float64
+	Maximum      float64
+	Percentile50 float64
 	Percentile95 float64
+	Percentile99 float64
 	SlowRequests int
 }
 
@@ -135,25 +188,100 @@ type Analyzer struct {
 	config              *config.Config
 	totalRequests       int
 	errorRequests       int
-	responseTimeSum     float64
+	responseTimeMs      int64
 	responseTimeMax     float64
 	responseTimeCount   int
-	responseTimeSample  []float64 // Limited sampling for percentile calculation
+	responseTimeSample  responseSample // Limited sampling for percentile calculation
 	slowRequestCount    int
-	ipCounts            map[string]int
-	errorURLs           map[string]int
+	ipCounts            *sketch.TopK
+	errorURLs           *sketch.TopK
 	hourlyPattern       [24]int
 	hourlyClientErrors  [24]int
 	hourlyServerErrors  [24]int
 	statusCodes         map[int]int
-	userAgents          map[string]int
+	userAgents          *sketch.TopK
 	attacksByIP         map[string]*IPAttacks
 	crawlers            map[string]int
 	attackTools         map[string]int
-	errorsByUA          map[string]int
-	errorURLsByStatus   map[int]map[string]int
-	slowURLs            map[string]int
+	ruleHits            map[string]*ruleAccumulator     // rule ID -> hits
+	categoryHits        map[string]*categoryAccumulator // category -> hits
+	errorsByUA          *sketch.TopK
+	urlsByStatus        map[int]*sketch.TopK
+	slowURLs            *sketch.TopK
 	errorTimestampsByIP map[string][]time.Time
+	minuteBuckets       map[int64]*minuteBucket // unix minute -> bucket
+	openSessions        map[string]*openSession // IP + "\x00" + UA -> session in progress
+	sessionCount        int
+	sessionPages        int
+	sessionBounces      int
+	sessionDurationSum  float64
+	sessionEntryPages   map[string]int
+	sessionExitPages    map[string]int
+	sessionPaths        map[string]int
+	uniques             *uniqueSketches
+	hourlyUniques       map[int64]*uniqueSketches // unix hour -> sketches
+	dailyUniques        map[int64]*uniqueSketches // JST day start (unix) -> sketches
+	noReferer           int
+	internalReferers    int
+	externalReferers    int
+	refererHosts        *sketch.TopK
+	searchEngines       map[string]int
+	spamReferers        *sketch.TopK
+	hotlinkRequests     int
+	hotlinkBytes        int64
+	hotlinkHostRequests *sketch.TopK
+	hotlinkHostBytes    *sketch.TopK // host -> bytes
+	methodStatus        map[string]map[int]int
+	protocols           map[string]int
+	http10Clients       *sketch.TopK
+	postURLs            *sketch.TopK
+	unusualMethods      map[methodIP]int
+	redirectCodes       map[int]int
+	redirectURLs        *sketch.TopK
+	redirectMappings    *sketch.TopK // URI + "\x00" + UpstreamURI
+	redirectChains      map[string]*openRedirectChain // IP + "\x00" + UA -> chain in progress
+	redirectChainPaths  *sketch.TopK
+	redirectLoops       *sketch.TopK
+	upstreamPHP         upstreamAccumulator
+	upstreamStatic      upstreamAccumulator
+	upstreamUnknown     int
+	upstreamScripts     map[string]*upstreamAccumulator
+	upstreamRewrites    *sketch.TopK // request path + "\x00" + upstream path
+	loginByIP           map[string]*loginAccumulator // capped at heavy_hitters.capacity
+	loginTotals         loginAccumulator
+	loginUniqueIPs      *sketch.HyperLogLog
+	loginDropped        int
+	xmlrpcRequests      int
+	xmlrpcPosts         int
+	xmlrpcLargePosts    int
+	xmlrpcBlocked       int
+	xmlrpcIPs           *sketch.TopK
+	xmlrpcUniqueIPs     *sketch.HyperLogLog
+	authorRequests      int
+	authorRedirects     int
+	authorIDs           map[int]int
+	restUserRequests    int
+	restUserExposed     int
+	userEnumIPs         *sketch.TopK
+	probeRequests       int
+	probeIPs            *sketch.TopK
+	probeUniqueIPs      *sketch.HyperLogLog
+	probedComponents    map[string]*componentAccumulator // type + "\x00" + slug
+	componentVersions   map[string]map[string]int        // type + "\x00" + slug -> ?ver= value
+	distributedAttempts int
+	loginWindows        map[loginWindowKey]*loginWindow
+	rateClients         map[RequestClass]map[string]*rateTimeline // class -> IP -> timeline
+	rateAbusiveIPs      map[string]bool
+	rateDropped         map[RequestClass]int // clients dropped to respect heavy_hitters.capacity
+	exposures           map[string]*exposureAccumulator  // request path
+	exposureIPs         *sketch.TopK
+	exposureUniqueIPs   *sketch.HyperLogLog
+	exposureRewritten   int
+	classStats          map[RequestClass]*classAccumulator
+	windowFrom          time.Time // zero = unbounded
+	windowTo            time.Time // zero = unbounded (exclusive)
+	workers             int       // > 1 enables the parallel pipeline
+	position            int64     // input position of the entry being processed (1-based)
 	startTime           time.Time
 	endTime             time.Time
 }
@@ -164,47 +292,133 @@ const maxErrorTimestampsPerIP = 1000 // Cap to bound memory for burst detection
 func NewAnalyzer(cfg *config.Config) *Analyzer {
 	return &Analyzer{
 		config:              cfg,
-		ipCounts:            make(map[string]int),
-		errorURLs:           make(map[string]int),
+		ipCounts:            sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		errorURLs:           sketch.NewTopK(cfg.HeavyHitters.Capacity),
 		statusCodes:         make(map[int]int),
-		userAgents:          make(map[string]int),
+		userAgents:          sketch.NewTopK(cfg.HeavyHitters.Capacity),
 		attacksByIP:         make(map[string]*IPAttacks),
 		crawlers:            make(map[string]int),
 		attackTools:         make(map[string]int),
-		errorsByUA:          make(map[string]int),
-		errorURLsByStatus:   make(map[int]map[string]int),
-		slowURLs:            make(map[string]int),
+		ruleHits:            make(map[string]*ruleAccumulator),
+		categoryHits:        make(map[string]*categoryAccumulator),
+		errorsByUA:          sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		urlsByStatus:        make(map[int]*sketch.TopK),
+		slowURLs:            sketch.NewTopK(cfg.HeavyHitters.Capacity),
 		errorTimestampsByIP: make(map[string][]time.Time),
+		minuteBuckets:       make(map[int64]*minuteBucket),
+		openSessions:        make(map[string]*openSession),
+		sessionEntryPages:   make(map[string]int),
+		sessionExitPages:    make(map[string]int),
+		sessionPaths:        make(map[string]int),
+		uniques:             newUniqueSketches(cfg.Uniques.Precision),
+		hourlyUniques:       make(map[int64]*uniqueSketches),
+		dailyUniques:        make(map[int64]*uniqueSketches),
+		refererHosts:        sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		searchEngines:       make(map[string]int),
+		spamReferers:        sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		hotlinkHostRequests: sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		hotlinkHostBytes:    sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		methodStatus:        make(map[string]map[int]int),
+		protocols:           make(map[string]int),
+		http10Clients:       sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		postURLs:            sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		unusualMethods:      make(map[methodIP]int),
+		redirectCodes:       make(map[int]int),
+		redirectURLs:        sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		redirectMappings:    sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		redirectChains:      make(map[string]*openRedirectChain),
+		redirectChainPaths:  sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		redirectLoops:       sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		upstreamScripts:     make(map[string]*upstreamAccumulator),
+		upstreamRewrites:    sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		loginByIP:           make(map[string]*loginAccumulator),
+		loginUniqueIPs:      sketch.NewHyperLogLog(cfg.Uniques.Precision),
+		xmlrpcIPs:           sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		xmlrpcUniqueIPs:     sketch.NewHyperLogLog(cfg.Uniques.Precision),
+		authorIDs:           make(map[int]int),
+		userEnumIPs:         sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		probeIPs:            sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		probeUniqueIPs:      sketch.NewHyperLogLog(cfg.Uniques.Precision),
+		probedComponents:    make(map[string]*componentAccumulator),
+		loginWindows:        make(map[loginWindowKey]*loginWindow),
+		rateClients:         make(map[RequestClass]map[string]*rateTimeline),
+		rateAbusiveIPs:      make(map[string]bool),
+		rateDropped:         make(map[RequestClass]int),
+		componentVersions:   make(map[string]map[string]int),
+		exposures:           make(map[string]*exposureAccumulator),
+		exposureIPs:         sketch.NewTopK(cfg.HeavyHitters.Capacity),
+		exposureUniqueIPs:   sketch.NewHyperLogLog(cfg.Uniques.Precision),
+		classStats:          make(map[RequestClass]*classAccumulator),
 	}
 }
 
+// AnalyzeFile processes every entry of filePath and returns the result.
 func (a *Analyzer) AnalyzeFile(filePath string) (*AnalysisResult, error) {
+	if err := a.ProcessFile(filePath); err != nil {
+		return nil, err
+	}
+	return a.Result(), nil
+}
+
+// ProcessFile adds every entry of filePath to the analyzer's state without
+// producing a result, so that several files can be accumulated, snapshotted
+// or merged before calling Result.
+func (a *Analyzer) ProcessFile(filePath string) error {
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file: %v", err)
+		return fmt.Errorf("failed to open file: %v", err)
 	}
 	defer file.Close()
 
+	if a.workers > 1 {
+		return a.processParallel(file)
+	}
+
 	scanner := bufio.NewScanner(file)
-	
+
+	// processEntry does not retain the entry, so one is reused for every line.
+	var entry parser.LogEntry
 	for scanner.Scan() {
-		line := scanner.Text()
-		entry, err := parser.ParseLogLine(line)
-		if err != nil {
+		if err := parser.ParseInto(&entry, scanner.Bytes()); err != nil {
 			// Skip invalid lines but continue processing
 			continue
 		}
 
-		a.processEntry(entry)
+		if !a.inWindow(entry.Timestamp) {
+			continue
+		}
+
+		a.position++
+		a.processEntry(&entry)
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %v", err)
+		return fmt.Errorf("error reading file: %v", err)
 	}
 
-	return a.generateResult(), nil
+	return nil
+}
+
+// SetTimeWindow restricts analysis to entries with from <= timestamp < to.
+// A zero from or to leaves that side unbounded.
+func (a *Analyzer) SetTimeWindow(from, to time.Time) {
+	a.windowFrom = from
+	a.windowTo = to
 }
 
+func (a *Analyzer) inWindow(t time.Time) bool {
+	if !a.windowFrom.IsZero() && t.Before(a.windowFrom) {
+		return false
+	}
+	if !a.windowTo.IsZero() && !t.Before(a.windowTo) {
+		return false
+	}
+	return true
+}
+
+// processEntry adds entry to the analyzer's state. a.position must hold the
+// entry's position in the input, which decides the keys kept by capped
+// per-minute tables.
 func (a *Analyzer) processEntry(entry *parser.LogEntry) {
 	a.totalRequests++
 
@@ -222,7 +436,7 @@ func (a *Analyzer) processEntry(entry *parser.LogEntry) {
 	}
 
 	// Response time tracking (online statistics)
-	a.responseTimeSum += entry.ResponseTime
+	a.responseTimeMs += toMillis(entry.ResponseTime)
 	a.responseTimeCount++
 	if entry.ResponseTime > a.responseTimeMax {
 		a.responseTimeMax = entry.ResponseTime
@@ -231,25 +445,48 @@ func (a *Analyzer) processEntry(entry *parser.LogEntry) {
 	// Track slow requests
 	if entry.ResponseTime > a.config.Thresholds.SlowRequestTime {
 		a.slowRequestCount++
-		a.slowURLs[entry.URI]++
+		a.slowURLs.Add(entry.URI, 1)
 	}
 
-	// Reservoir sampling for percentile calculation (memory-efficient)
-	if len(a.responseTimeSample) < maxResponseTimeSamples {
-		a.responseTimeSample = append(a.responseTimeSample, entry.ResponseTime)
-	} else {
-		// Random replacement to maintain uniform distribution
-		// This is a simple reservoir sampling algorithm
-		if a.responseTimeCount < maxResponseTimeSamples*10 {
-			// For first 10K requests, replace randomly to get good sample
-			randIndex := a.responseTimeCount % maxResponseTimeSamples
-			a.responseTimeSample[randIndex] = entry.ResponseTime
-		}
-		// After 10K requests, sample becomes stable enough
-	}
+	// Bounded sample for percentile calculation (memory-efficient)
+	sample := sampleOf(entry)
+	a.responseTimeSample.add(sample)
 
 	// IP counting
-	a.ipCounts[entry.ClientIP]++
+	a.ipCounts.Add(entry.ClientIP, 1)
+
+	// Static / dynamic / REST ... classification
+	a.trackClass(entry, sample)
+
+	// Per-minute series for anomaly detection
+	a.trackMinute(entry)
+
+	// Security rules are matched once; sessions skip crawlers and tools
+	detections := a.config.Rules().Match(rules.Request{URI: entry.URI, UserAgent: entry.UserAgent, Referer: entry.Referer})
+
+	// Visitor sessions (IP + UA)
+	a.trackSession(entry, isAutomatedClient(detections))
+
+	// Approximate unique counts (bounded memory)
+	a.trackUniques(entry)
+
+	// Referer analysis
+	a.trackReferer(entry)
+
+	// HTTP method / protocol distribution
+	a.trackMethod(entry)
+
+	// Redirects (3xx) and inferred chains
+	a.trackRedirect(entry)
+
+	// Upstream (Nginx rewrite) analysis
+	a.trackUpstream(entry)
+
+	// WordPress: login brute force, XML-RPC, user enumeration, plugin/theme probing
+	a.trackWordPress(entry)
+
+	// Per-client request rates for rate limit recommendations
+	a.trackRate(entry, detections)
 
 	// Hourly pattern — bucket by JST so reports show local time
 	hour := entry.Timestamp.In(utils.JST).Hour()
@@ -262,9 +499,15 @@ func (a *Analyzer) processEntry(entry *parser.LogEntry) {
 
 	// Status codes
 	a.statusCodes[entry.StatusCode]++
+	if a.config.TracksStatusURLs(entry.StatusCode) {
+		if a.urlsByStatus[entry.StatusCode] == nil {
+			a.urlsByStatus[entry.StatusCode] = sketch.NewTopK(a.config.HeavyHitters.Capacity)
+		}
+		a.urlsByStatus[entry.StatusCode].Add(entry.URI, 1)
+	}
 
 	// User agent analysis
-	a.userAgents[entry.UserAgent]++
+	a.userAgents.Add(entry.UserAgent, 1)
 
 	// Initialize IP attacks if not exists
 	if a.attacksByIP[entry.ClientIP] == nil {
@@ -275,46 +518,48 @@ func (a *Analyzer) processEntry(entry *parser.LogEntry) {
 	// Error analysis
 	if entry.IsError() {
 		a.errorRequests++
-		a.errorURLs[entry.URI]++
-		a.errorsByUA[entry.UserAgent]++
+		a.errorURLs.Add(entry.URI, 1)
+		a.errorsByUA.Add(entry.UserAgent, 1)
 		a.attacksByIP[entry.ClientIP].ErrorCount++
 
-		if a.errorURLsByStatus[entry.StatusCode] == nil {
-			a.errorURLsByStatus[entry.StatusCode] = make(map[string]int)
-		}
-		a.errorURLsByStatus[entry.StatusCode][entry.URI]++
-
-		if len(a.errorTimestampsByIP[entry.ClientIP]) < maxErrorTimestampsPerIP {
-			a.errorTimestampsByIP[entry.ClientIP] = append(a.errorTimestampsByIP[entry.ClientIP], entry.Timestamp)
-		}
-	}
-
-	// Security analysis
-	if a.config.IsSQLInjectionAttempt(entry.URI, entry.UserAgent) {
-		a.attacksByIP[entry.ClientIP].SQLAttempts++
+		a.errorTimestampsByIP[entry.ClientIP] = keepEarliest(a.errorTimestampsByIP[entry.ClientIP], entry.Timestamp)
 	}
 
-	if a.config.IsXSSAttempt(entry.URI, entry.UserAgent) {
-		a.attacksByIP[entry.ClientIP].XSSAttempts++
-	}
+	// Security rules (SQLi, XSS, scanners, crawlers...)
+	a.trackDetections(entry, detections)
+}
 
-	// Crawler detection
-	if a.config.IsCrawler(entry.UserAgent) {
-		a.crawlers[entry.UserAgent]++
-	}
+// toMillis converts a response time to whole milliseconds, the resolution of
+// the log. Latency sums are kept as integers so that they come out the same
+// whichever order shards or snapshots are added up in.
+func toMillis(seconds float64) int64 {
+	return int64(math.Round(seconds * 1000))
+}
 
-	// Attack tool detection
-	if a.config.IsAttackTool(entry.UserAgent) {
-		a.attackTools[entry.UserAgent]++
-	}
+// avgSeconds returns the mean in seconds of n response times summing to ms.
+func avgSeconds(ms int64, n int) float64 {
+	return float64(ms) / float64(n) / 1000
 }
 
-func (a *Analyzer) generateResult() *AnalysisResult {
+// Result summarises everything processed so far. It closes the sessions and
+// redirect chains still in progress, so it must only be called once, after
+// any Snapshot.
+func (a *Analyzer) Result() *AnalysisResult {
 	return &AnalysisResult{
 		Summary:           a.generateSummary(),
 		HTTPErrors:        a.generateHTTPErrors(),
 		SecurityAnalysis:  a.generateSecurityAnalysis(),
 		Statistics:        a.generateStatistics(),
 		UserAgentAnalysis: a.generateUserAgentAnalysis(),
+		Anomalies:         a.generateAnomalies(),
+		Sessions:          a.generateSessions(),
+		Uniques:           a.generateUniques(),
+		Referers:          a.generateRefererAnalysis(),
+		Methods:           a.generateMethodAnalysis(),
+		Redirects:         a.generateRedirectAnalysis(),
+		Upstream:          a.generateUpstreamAnalysis(),
+		WordPress:         a.generateWordPressAnalysis(),
+		Exposure:          a.generateExposureAnalysis(),
+		RateLimits:        a.generateRateLimits(),
 	}
 }
\ No newline at end of file
@@ -0,0 +1,45 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"kinsta-log-analyzer/pkg/config"
+)
+
+const (
+	testConfigPath = "../../config.yaml"
+	sampleLogPath  = "../../logs/sample-access.log"
+)
+
+func loadTestConfig(t *testing.T) *config.Config {
+	t.Helper()
+	cfg, err := config.LoadConfig(testConfigPath)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	return cfg
+}
+
+func analyzeFile(t *testing.T, cfg *config.Config, path string, workers int) *AnalysisResult {
+	t.Helper()
+	a := NewAnalyzer(cfg)
+	a.SetWorkers(workers)
+	result, err := a.AnalyzeFile(path)
+	if err != nil {
+		t.Fatalf("AnalyzeFile(%s) error = %v", path, err)
+	}
+	return result
+}
+
+// assertSameResult reports every top-level section of got that differs from
+// want, so a failure points at the analysis that is not reproducible.
+func assertSameResult(t *testing.T, got, want *AnalysisResult) {
+	t.Helper()
+	gv, wv := reflect.ValueOf(*got), reflect.ValueOf(*want)
+	for i := 0; i < gv.NumField(); i++ {
+		if !reflect.DeepEqual(gv.Field(i).Interface(), wv.Field(i).Interface()) {
+			t.Errorf("%s differs:\n got  %+v\n want %+v", gv.Type().Field(i).Name, gv.Field(i).Interface(), wv.Field(i).Interface())
+		}
+	}
+}
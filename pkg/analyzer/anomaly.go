@@ -0,0 +1,317 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+// maxKeysPerMinute caps the distinct URLs/IPs remembered per minute bucket so
+// that a scanner hitting random URLs cannot grow a single bucket without bound.
+// The keys kept are the ones that appeared first in the input.
+const maxKeysPerMinute = 200
+
+// minuteBucket aggregates the entries that fall into one wall-clock minute.
+type minuteBucket struct {
+	requests       int
+	errors         int
+	responseTimeMs int64
+	urls           map[string]keyCount
+	ips            map[string]keyCount
+}
+
+// keyCount counts a URL or IP of a minute bucket. first is the input position
+// of its first entry, so that buckets built by separate shards can be merged
+// into the one a sequential pass builds.
+type keyCount struct {
+	count int
+	first int64
+}
+
+// countKey counts key in m unless m is full and key is new.
+func countKey(m map[string]keyCount, key string, position int64) {
+	if c, ok := m[key]; ok {
+		c.count++
+		m[key] = c
+	} else if len(m) < maxKeysPerMinute {
+		m[key] = keyCount{count: 1, first: position}
+	}
+}
+
+// mergeKeyCounts adds src to dst and keeps the maxKeysPerMinute keys that
+// appeared first. A key among those in the combined input is among them in
+// every part it appears in, so its count is complete.
+func mergeKeyCounts(dst, src map[string]keyCount) {
+	for k, c := range src {
+		if mine, ok := dst[k]; ok {
+			mine.count += c.count
+			mine.first = min(mine.first, c.first)
+			dst[k] = mine
+		} else {
+			dst[k] = c
+		}
+	}
+	if len(dst) <= maxKeysPerMinute {
+		return
+	}
+	keys := make([]string, 0, len(dst))
+	for k := range dst {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if fi, fj := dst[keys[i]].first, dst[keys[j]].first; fi != fj {
+			return fi < fj
+		}
+		return keys[i] < keys[j]
+	})
+	for _, k := range keys[maxKeysPerMinute:] {
+		delete(dst, k)
+	}
+}
+
+// Minimum scale per series, used when the baseline MAD is 0 (e.g. a quiet site
+// where every minute so far had 0 errors). Without a floor a single error
+// would have an infinite score.
+const (
+	minScaleRequests = 1.0
+	minScaleErrors   = 1.0
+	minScaleLatency  = 0.05 // seconds
+)
+
+// AnomalyAnalysis は分単位のリクエスト数・エラー数・レイテンシの異常検知の結果。
+type AnomalyAnalysis struct {
+	Enabled        bool
+	WindowMinutes  int
+	ScoreThreshold float64
+	Intervals      []AnomalyInterval
+}
+
+// AnomalyInterval は異常と判定された連続する分のまとまり。
+type AnomalyInterval struct {
+	Start           time.Time
+	End             time.Time // 最後の分の開始時刻
+	Series          []string  // 異常と判定された系列（例: "リクエスト数", "エラー数", "レイテンシ"）
+	Score           float64   // 区間内の最大ロバストZスコア
+	Requests        int
+	Errors          int
+	AvgResponseTime float64
+	TopURLs         []URLError
+	TopIPs          []IPCount
+}
+
+func (a *Analyzer) trackMinute(entry *parser.LogEntry) {
+	// Lines without a [timestamp] parse with a zero time; they belong to no minute.
+	if !a.config.Anomaly.Enabled || entry.Timestamp.IsZero() {
+		return
+	}
+	key := entry.Timestamp.Unix() / 60
+	b := a.minuteBuckets[key]
+	if b == nil {
+		b = &minuteBucket{urls: make(map[string]keyCount), ips: make(map[string]keyCount)}
+		a.minuteBuckets[key] = b
+	}
+	b.requests++
+	b.responseTimeMs += toMillis(entry.ResponseTime)
+	if entry.IsError() {
+		b.errors++
+	}
+	countKey(b.urls, entry.URI, a.position)
+	countKey(b.ips, entry.ClientIP, a.position)
+}
+
+// generateAnomalies scores every minute against the rolling median/MAD of the
+// preceding WindowMinutes minutes for three series (requests, errors, average
+// latency). Only upward deviations are flagged. Consecutive anomalous minutes
+// are merged into one interval; Top 10 intervals by score are returned.
+//
+// Only minutes with requests are scored: an empty minute has 0 requests, 0
+// errors and no latency, so it can never deviate upwards. Empty minutes still
+// count as 0 in the request/error baselines and break intervals, but they are
+// never materialised, so a stray far-off timestamp costs nothing.
+func (a *Analyzer) generateAnomalies() AnomalyAnalysis {
+	cfg := a.config.Anomaly
+	result := AnomalyAnalysis{Enabled: cfg.Enabled, WindowMinutes: cfg.WindowMinutes, ScoreThreshold: cfg.ScoreThreshold}
+	if !cfg.Enabled || cfg.WindowMinutes <= 0 || cfg.ScoreThreshold <= 0 || len(a.minuteBuckets) == 0 {
+		return result
+	}
+	minBaseline := cfg.MinBaseline
+	if minBaseline <= 0 {
+		minBaseline = 1
+	}
+
+	keys := make([]int64, 0, len(a.minuteBuckets))
+	for k := range a.minuteBuckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	first := keys[0]
+
+	var current *AnomalyInterval
+	var intervals []AnomalyInterval
+	seriesSeen := map[string]bool{}
+	urls := map[string]int{}
+	ips := map[string]int{}
+	flush := func() {
+		if current == nil {
+			return
+		}
+		for _, name := range []string{"リクエスト数", "エラー数", "レイテンシ"} {
+			if seriesSeen[name] {
+				current.Series = append(current.Series, name)
+			}
+		}
+		if current.Requests > 0 {
+			current.AvgResponseTime /= float64(current.Requests)
+		}
+		current.TopURLs = topURLs(urls, 5)
+		current.TopIPs = topIPs(ips, 5)
+		intervals = append(intervals, *current)
+		current = nil
+		seriesSeen = map[string]bool{}
+		urls = map[string]int{}
+		ips = map[string]int{}
+	}
+
+	lo := 0
+	var requests, errors, latency []float64
+	for i, k := range keys {
+		// Baseline window [k-WindowMinutes, k), clipped to the first minute.
+		windowStart := k - int64(cfg.WindowMinutes)
+		if windowStart < first {
+			windowStart = first
+		}
+		for keys[lo] < windowStart {
+			lo++
+		}
+		requests, errors, latency = requests[:0], errors[:0], latency[:0]
+		for _, pk := range keys[lo:i] {
+			b := a.minuteBuckets[pk]
+			requests = append(requests, float64(b.requests))
+			errors = append(errors, float64(b.errors))
+			latency = append(latency, avgSeconds(b.responseTimeMs, b.requests))
+		}
+		for empty := int(k-windowStart) - (i - lo); empty > 0; empty-- {
+			requests = append(requests, 0)
+			errors = append(errors, 0)
+		}
+
+		b := a.minuteBuckets[k]
+		score := 0.0
+		flagged := map[string]bool{}
+		if s, ok := robustScore(requests, float64(b.requests), minBaseline, minScaleRequests); ok && s >= cfg.ScoreThreshold {
+			flagged["リクエスト数"] = true
+			score = math.Max(score, s)
+		}
+		if s, ok := robustScore(errors, float64(b.errors), minBaseline, minScaleErrors); ok && s >= cfg.ScoreThreshold {
+			flagged["エラー数"] = true
+			score = math.Max(score, s)
+		}
+		if s, ok := robustScore(latency, avgSeconds(b.responseTimeMs, b.requests), minBaseline, minScaleLatency); ok && s >= cfg.ScoreThreshold {
+			flagged["レイテンシ"] = true
+			score = math.Max(score, s)
+		}
+
+		// An empty minute between two flagged ones ends the interval.
+		if len(flagged) == 0 || (current != nil && k != current.End.Unix()/60+1) {
+			flush()
+		}
+		if len(flagged) == 0 {
+			continue
+		}
+
+		start := time.Unix(k*60, 0).UTC()
+		if current == nil {
+			current = &AnomalyInterval{Start: start}
+		}
+		current.End = start
+		if score > current.Score {
+			current.Score = score
+		}
+		for name := range flagged {
+			seriesSeen[name] = true
+		}
+		current.Requests += b.requests
+		current.Errors += b.errors
+		current.AvgResponseTime += float64(b.responseTimeMs) / 1000 // divided by Requests in flush
+		for u, c := range b.urls {
+			urls[u] += c.count
+		}
+		for ip, c := range b.ips {
+			ips[ip] += c.count
+		}
+	}
+	flush()
+
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Score > intervals[j].Score
+	})
+	if len(intervals) > 10 {
+		intervals = intervals[:10]
+	}
+	result.Intervals = intervals
+	return result
+}
+
+// robustScore returns the modified Z-score 0.6745*(x-median)/MAD of x against
+// baseline, with the scale floored at minScale. ok is false when baseline has
+// fewer than minBaseline points. baseline is not modified.
+func robustScore(baseline []float64, x float64, minBaseline int, minScale float64) (float64, bool) {
+	if len(baseline) < minBaseline {
+		return 0, false
+	}
+	values := append([]float64(nil), baseline...)
+	med := median(values)
+	for i, v := range values {
+		values[i] = math.Abs(v - med)
+	}
+	mad := median(values)
+	// 0.6745*(x-med)/mad == (x-med)/(1.4826*mad)
+	scale := math.Max(1.4826*mad, minScale)
+	return (x - med) / scale, true
+}
+
+// median sorts values in place and returns the median.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
+
+func topURLs(counts map[string]int, limit int) []URLError {
+	result := make([]URLError, 0, len(counts))
+	for u, c := range counts {
+		result = append(result, URLError{URL: u, Count: c})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].URL < result[j].URL
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+func topIPs(counts map[string]int, limit int) []IPCount {
+	result := make([]IPCount, 0, len(counts))
+	for ip, c := range counts {
+		result = append(result, IPCount{IP: ip, Count: c})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].IP < result[j].IP
+	})
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
@@ -0,0 +1,152 @@
+package analyzer
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+func TestRobustScore(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseline    []float64
+		x           float64
+		minBaseline int
+		minScale    float64
+		want        float64
+		wantOK      bool
+	}{
+		// median 3, MAD 1
+		{"odd baseline", []float64{5, 1, 4, 2, 3}, 10, 5, 0, 7 / 1.4826, true},
+		// median 2.5, MAD 1
+		{"even baseline", []float64{4, 1, 3, 2}, 2.5, 4, 0, 0, true},
+		{"below the median", []float64{4, 1, 3, 2}, 1, 4, 0, -1.5 / 1.4826, true},
+		{"fewer points than the minimum baseline", []float64{1, 2, 3}, 10, 4, 0, 0, false},
+		{"empty baseline", nil, 1, 1, 1, 0, false},
+		{"zero MAD falls back to minScale", []float64{5, 5, 5, 5}, 7, 4, 1, 2, true},
+		{"smaller minScale scores higher", []float64{5, 5, 5, 5}, 7, 4, 0.5, 4, true},
+		{"minScale below the MAD is ignored", []float64{5, 1, 4, 2, 3}, 10, 5, 1, 7 / 1.4826, true},
+		{"minScale above the MAD wins", []float64{5, 1, 4, 2, 3}, 10, 5, 2, 3.5, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseline := append([]float64(nil), tt.baseline...)
+			got, ok := robustScore(baseline, tt.x, tt.minBaseline, tt.minScale)
+			if ok != tt.wantOK || math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("robustScore() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+			if !reflect.DeepEqual(baseline, tt.baseline) {
+				t.Errorf("baseline modified: %v, want %v", baseline, tt.baseline)
+			}
+		})
+	}
+}
+
+func TestGenerateAnomalies(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	minute := func(i int) time.Time { return start.Add(time.Duration(i) * time.Minute) }
+
+	// steady returns n minutes of 9, 10 and 11 requests in turn.
+	steady := func(n, base int) []int {
+		counts := make([]int, n)
+		for i := range counts {
+			counts[i] = base + i%3 - 1
+		}
+		return counts
+	}
+	join := func(parts ...[]int) []int {
+		var counts []int
+		for _, p := range parts {
+			counts = append(counts, p...)
+		}
+		return counts
+	}
+
+	tests := []struct {
+		name          string
+		windowMinutes int
+		counts        []int // requests in each minute from start
+		want          []time.Time
+	}{
+		{"steady traffic", 60, steady(40, 10), nil},
+		{"spike after the minimum baseline", 60, join(steady(30, 10), []int{100}, steady(10, 10)), []time.Time{minute(30)}},
+		{"spike before the minimum baseline", 60, join(steady(5, 10), []int{100}, steady(10, 10)), nil},
+		{"drop is not flagged", 60, join(steady(30, 10), []int{1}, steady(10, 10)), nil},
+		// Against the whole history a minute of 30 is below the median; against
+		// the last 10 minutes of 10 it is a spike.
+		{"baseline rolls with the window", 10, join(steady(20, 100), steady(20, 10), []int{30}), []time.Time{minute(40)}},
+		{"window covering the whole history", 60, join(steady(20, 100), steady(20, 10), []int{30}), nil},
+		// Missing minutes after the first request count as 0 requests, so a
+		// quiet site's next busy minute stands out.
+		{"empty minutes count as zero", 60, join([]int{20}, make([]int, 29), []int{20}), []time.Time{minute(30)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadTestConfig(t)
+			cfg.Anomaly.Enabled = true
+			cfg.Anomaly.WindowMinutes = tt.windowMinutes
+			cfg.Anomaly.MinBaseline = 10
+			cfg.Anomaly.ScoreThreshold = 3.5
+			a := NewAnalyzer(cfg)
+			for i, n := range tt.counts {
+				for j := 0; j < n; j++ {
+					a.trackMinute(&parser.LogEntry{Timestamp: minute(i), ClientIP: "192.0.2.1", URI: "/", StatusCode: 200})
+				}
+			}
+
+			var got []time.Time
+			for _, in := range a.generateAnomalies().Intervals {
+				got = append(got, in.Start)
+				if !in.End.Equal(in.Start) || !reflect.DeepEqual(in.Series, []string{"リクエスト数"}) {
+					t.Errorf("interval = %v-%v %v, want one minute of リクエスト数", in.Start, in.End, in.Series)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("intervals start at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateAnomaliesMergesMinutes(t *testing.T) {
+	cfg := loadTestConfig(t)
+	cfg.Anomaly.Enabled = true
+	cfg.Anomaly.WindowMinutes = 60
+	cfg.Anomaly.MinBaseline = 10
+	cfg.Anomaly.ScoreThreshold = 3.5
+	a := NewAnalyzer(cfg)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	add := func(i, n, status int, ip string) {
+		for j := 0; j < n; j++ {
+			a.trackMinute(&parser.LogEntry{Timestamp: start.Add(time.Duration(i) * time.Minute), ClientIP: ip, URI: "/", StatusCode: status})
+		}
+	}
+	for i := 0; i < 30; i++ {
+		add(i, 10+i%3-1, 200, "192.0.2.1")
+	}
+	// Two anomalous minutes in a row: a flood, then a flood of errors.
+	add(30, 100, 200, "203.0.113.1")
+	add(31, 10, 200, "192.0.2.1")
+	add(31, 50, 500, "203.0.113.1")
+
+	got := a.generateAnomalies().Intervals
+	if len(got) != 1 {
+		t.Fatalf("got %d intervals, want 1: %+v", len(got), got)
+	}
+	in := got[0]
+	if !in.Start.Equal(start.Add(30*time.Minute)) || !in.End.Equal(start.Add(31*time.Minute)) {
+		t.Errorf("interval = %v-%v, want minutes 30-31", in.Start, in.End)
+	}
+	if !reflect.DeepEqual(in.Series, []string{"リクエスト数", "エラー数"}) {
+		t.Errorf("Series = %v, want リクエスト数, エラー数", in.Series)
+	}
+	if in.Requests != 160 || in.Errors != 50 {
+		t.Errorf("Requests, Errors = %d, %d, want 160, 50", in.Requests, in.Errors)
+	}
+	if len(in.TopIPs) == 0 || in.TopIPs[0].IP != "203.0.113.1" || in.TopIPs[0].Count != 150 {
+		t.Errorf("TopIPs = %+v, want 203.0.113.1 with 150 first", in.TopIPs)
+	}
+}
@@ -0,0 +1,337 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"kinsta-log-analyzer/pkg/config"
+	"kinsta-log-analyzer/pkg/utils"
+)
+
+// runSummaryVersion is bumped whenever RunSummary changes incompatibly;
+// summaries with another version are ignored when loading the baseline.
+const runSummaryVersion = 1
+
+const runSummaryPrefix = "run_"
+
+// RunSummary は1回の実行の要約。state_dir に JSON で保存され、
+// 以降の実行のベースラインになる。
+type RunSummary struct {
+	Version         int            `json:"version"`
+	Input           string         `json:"input"`
+	AnalyzedAt      time.Time      `json:"analyzed_at"`
+	StartTime       time.Time      `json:"start_time"`
+	EndTime         time.Time      `json:"end_time"`
+	TotalRequests   int            `json:"total_requests"`
+	RequestsPerHour float64        `json:"requests_per_hour"`
+	ErrorRate       float64        `json:"error_rate"`
+	ServerErrorRate float64        `json:"server_error_rate"`
+	AvgResponseTime float64        `json:"avg_response_time"`
+	Percentile50    float64        `json:"p50"`
+	Percentile95    float64        `json:"p95"`
+	Percentile99    float64        `json:"p99"`
+	HourlyShare     [24]float64    `json:"hourly_share"` // JST の時間別リクエスト割合（トラフィック形状）
+	Routes          []RouteSummary `json:"routes"`
+}
+
+// RouteSummary はリクエスト分類（"class:dynamic" 等）または upstream スクリプト
+// （"script:/index.php" 等）ごとの要約。スクリプトはパーセンタイルを持たない。
+type RouteSummary struct {
+	Route           string  `json:"route"`
+	Requests        int     `json:"requests"`
+	ErrorRate       float64 `json:"error_rate"`
+	AvgResponseTime float64 `json:"avg_response_time"`
+	Percentile50    float64 `json:"p50,omitempty"`
+	Percentile95    float64 `json:"p95,omitempty"`
+	Percentile99    float64 `json:"p99,omitempty"`
+}
+
+type BaselineAnalysis struct {
+	Enabled     bool
+	Runs        int // 比較に使った過去の実行数
+	MinRuns     int
+	Threshold   float64
+	Metrics     []BaselineMetric
+	Regressions int
+}
+
+// BaselineMetric は1指標の今回値とベースライン（過去の実行の中央値）の比較。
+type BaselineMetric struct {
+	Name          string
+	Route         string // 全体指標なら ""
+	Current       float64
+	Median        float64
+	ChangePercent float64
+	Score         float64 // ロバストZスコア（劣化方向が正）
+	Regression    bool
+}
+
+// NewRunSummary condenses result into the form persisted between runs.
+func NewRunSummary(input string, result *AnalysisResult) RunSummary {
+	s := RunSummary{
+		Version:         runSummaryVersion,
+		Input:           input,
+		AnalyzedAt:      time.Now(),
+		StartTime:       result.Summary.StartTime,
+		EndTime:         result.Summary.EndTime,
+		TotalRequests:   result.Summary.TotalRequests,
+		RequestsPerHour: requestsPerHour(result.Summary),
+		ErrorRate:       result.Summary.ErrorRate,
+		AvgResponseTime: result.Summary.AvgResponseTime,
+		Percentile50:    result.Statistics.ResponseTimeStats.Percentile50,
+		Percentile95:    result.Statistics.ResponseTimeStats.Percentile95,
+		Percentile99:    result.Statistics.ResponseTimeStats.Percentile99,
+	}
+	if s.TotalRequests > 0 {
+		s.ServerErrorRate = float64(result.Summary.StatusClasses.ServerError) / float64(s.TotalRequests) * 100
+		for hour, count := range result.Statistics.HourlyPattern {
+			s.HourlyShare[hour] = float64(count) / float64(s.TotalRequests)
+		}
+	}
+	for _, c := range result.Summary.ByClass {
+		s.Routes = append(s.Routes, RouteSummary{
+			Route:           "class:" + string(c.Class),
+			Requests:        c.TotalRequests,
+			ErrorRate:       c.ErrorRate,
+			AvgResponseTime: c.AvgResponseTime,
+			Percentile50:    c.Percentile50,
+			Percentile95:    c.Percentile95,
+			Percentile99:    c.Percentile99,
+		})
+	}
+	for _, sc := range result.Upstream.Scripts {
+		if sc.Script == otherUpstream {
+			continue
+		}
+		s.Routes = append(s.Routes, RouteSummary{
+			Route:           "script:" + sc.Script,
+			Requests:        sc.Requests,
+			ErrorRate:       sc.ErrorRate,
+			AvgResponseTime: sc.AvgResponseTime,
+		})
+	}
+	return s
+}
+
+// SaveRunSummary writes s into dir and then removes all but the newest keep
+// summaries. It returns the path written.
+func SaveRunSummary(dir string, s RunSummary, keep int) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %v", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode run summary: %v", err)
+	}
+	// Nanoseconds keep names unique and lexically ordered for back-to-back runs.
+	name := fmt.Sprintf("%s%s.json", runSummaryPrefix, s.AnalyzedAt.In(utils.JST).Format("20060102_150405.000000000"))
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write run summary: %v", err)
+	}
+
+	if keep > 0 {
+		files, err := runSummaryFiles(dir)
+		if err != nil {
+			return path, err
+		}
+		for len(files) > keep {
+			if err := os.Remove(files[0]); err != nil {
+				return path, fmt.Errorf("failed to prune run summary: %v", err)
+			}
+			files = files[1:]
+		}
+	}
+	return path, nil
+}
+
+// LoadBaseline returns up to n of the newest run summaries in dir, oldest
+// first. A missing directory yields an empty baseline; unreadable or
+// incompatible files are skipped.
+func LoadBaseline(dir string, n int) ([]RunSummary, error) {
+	files, err := runSummaryFiles(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var runs []RunSummary
+	for i := len(files) - 1; i >= 0 && len(runs) < n; i-- {
+		data, err := os.ReadFile(files[i])
+		if err != nil {
+			continue
+		}
+		var s RunSummary
+		if err := json.Unmarshal(data, &s); err != nil || s.Version != runSummaryVersion {
+			continue
+		}
+		runs = append(runs, s)
+	}
+	for i, j := 0, len(runs)-1; i < j; i, j = i+1, j-1 {
+		runs[i], runs[j] = runs[j], runs[i]
+	}
+	return runs, nil
+}
+
+// runSummaryFiles lists the run summaries in dir sorted oldest first.
+func runSummaryFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasPrefix(e.Name(), runSummaryPrefix) || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		files = append(files, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Minimum scales for baseline scores, analogous to the anomaly detector's:
+// without a floor, a perfectly flat history would make any change infinitely
+// significant. The relative floor scales with the baseline median.
+const (
+	baselineScaleErrorRate = 0.5  // percentage points
+	baselineScaleLatency   = 0.05 // seconds
+	baselineScaleShape     = 0.02 // total variation distance
+	baselineRelativeScale  = 0.05 // 5% of the median
+)
+
+// Defaults for baseline settings left out of (or <= 0 in) the config.
+const (
+	defaultBaselineRuns           = 14
+	defaultBaselineMinRuns        = 3
+	defaultBaselineScoreThreshold = 3.5
+	defaultBaselineStateDir       = "./state"
+)
+
+// BaselineSettings returns cfg.Baseline with unset run counts, threshold and
+// state directory replaced by their defaults. Without this a config that
+// omits runs would load no history and never compare anything.
+func BaselineSettings(cfg *config.Config) config.Baseline {
+	bc := cfg.Baseline
+	if bc.Runs <= 0 {
+		bc.Runs = defaultBaselineRuns
+	}
+	if bc.MinRuns <= 0 {
+		bc.MinRuns = defaultBaselineMinRuns
+	}
+	if bc.ScoreThreshold <= 0 {
+		bc.ScoreThreshold = defaultBaselineScoreThreshold
+	}
+	if bc.StateDir == "" {
+		bc.StateDir = defaultBaselineStateDir
+	}
+	return bc
+}
+
+// CompareToBaseline scores current against history (previous runs). Each
+// metric is flagged as a regression when its robust Z-score in the harmful
+// direction reaches ScoreThreshold and it moved at least MinChangePercent
+// from the baseline median. Route metrics need MinRequestsForErrorRate
+// requests in the current run to be scored.
+func CompareToBaseline(current RunSummary, history []RunSummary, cfg *config.Config) BaselineAnalysis {
+	bc := BaselineSettings(cfg)
+	result := BaselineAnalysis{Enabled: true, Runs: len(history), MinRuns: bc.MinRuns, Threshold: bc.ScoreThreshold}
+	if len(history) == 0 || len(history) < bc.MinRuns {
+		return result
+	}
+
+	add := func(name, route string, values []float64, x, absScale float64, bothDirections bool) {
+		if len(values) == 0 || len(values) < bc.MinRuns {
+			return
+		}
+		med := median(append([]float64(nil), values...))
+		score, ok := robustScore(values, x, bc.MinRuns, math.Max(absScale, baselineRelativeScale*math.Abs(med)))
+		if !ok {
+			return
+		}
+		m := BaselineMetric{Name: name, Route: route, Current: x, Median: med, Score: score}
+		if med != 0 {
+			m.ChangePercent = (x - med) / med * 100
+		}
+		if bothDirections {
+			m.Score = math.Abs(score)
+		}
+		changed := (med == 0 && x != 0) || math.Abs(m.ChangePercent) >= bc.MinChangePercent
+		m.Regression = m.Score >= bc.ScoreThreshold && changed
+		if m.Regression {
+			result.Regressions++
+		}
+		result.Metrics = append(result.Metrics, m)
+	}
+
+	series := func(f func(RunSummary) float64) []float64 {
+		values := make([]float64, len(history))
+		for i, h := range history {
+			values[i] = f(h)
+		}
+		return values
+	}
+
+	// Traffic volume is flagged both ways: a sharp drop often means an outage.
+	add("リクエスト数/時間", "", series(func(s RunSummary) float64 { return s.RequestsPerHour }), current.RequestsPerHour, 1, true)
+	add("エラー率 (%)", "", series(func(s RunSummary) float64 { return s.ErrorRate }), current.ErrorRate, baselineScaleErrorRate, false)
+	add("5xx率 (%)", "", series(func(s RunSummary) float64 { return s.ServerErrorRate }), current.ServerErrorRate, baselineScaleErrorRate, false)
+	add("平均レスポンス時間 (秒)", "", series(func(s RunSummary) float64 { return s.AvgResponseTime }), current.AvgResponseTime, baselineScaleLatency, false)
+	add("50パーセンタイル (秒)", "", series(func(s RunSummary) float64 { return s.Percentile50 }), current.Percentile50, baselineScaleLatency, false)
+	add("95パーセンタイル (秒)", "", series(func(s RunSummary) float64 { return s.Percentile95 }), current.Percentile95, baselineScaleLatency, false)
+	add("99パーセンタイル (秒)", "", series(func(s RunSummary) float64 { return s.Percentile99 }), current.Percentile99, baselineScaleLatency, false)
+
+	// Traffic shape: distance of each run's hourly profile from the mean
+	// profile of the history.
+	var mean [24]float64
+	for _, h := range history {
+		for i, v := range h.HourlyShare {
+			mean[i] += v / float64(len(history))
+		}
+	}
+	add("時間別トラフィック形状の乖離", "", series(func(s RunSummary) float64 { return shapeDistance(s.HourlyShare, mean) }),
+		shapeDistance(current.HourlyShare, mean), baselineScaleShape, false)
+
+	for _, route := range current.Routes {
+		if route.Requests < cfg.Thresholds.MinRequestsForErrorRate {
+			continue
+		}
+		var errorRates, latencies, p95s []float64
+		for _, h := range history {
+			for _, r := range h.Routes {
+				if r.Route != route.Route {
+					continue
+				}
+				errorRates = append(errorRates, r.ErrorRate)
+				latencies = append(latencies, r.AvgResponseTime)
+				if r.Percentile95 > 0 {
+					p95s = append(p95s, r.Percentile95)
+				}
+			}
+		}
+		add("エラー率 (%)", route.Route, errorRates, route.ErrorRate, baselineScaleErrorRate, false)
+		add("平均レスポンス時間 (秒)", route.Route, latencies, route.AvgResponseTime, baselineScaleLatency, false)
+		if route.Percentile95 > 0 {
+			add("95パーセンタイル (秒)", route.Route, p95s, route.Percentile95, baselineScaleLatency, false)
+		}
+	}
+
+	return result
+}
+
+// shapeDistance is the total variation distance between two hourly
+// distributions: 0 for identical shapes, 1 for disjoint ones.
+func shapeDistance(a, b [24]float64) float64 {
+	d := 0.0
+	for i := range a {
+		d += math.Abs(a[i] - b[i])
+	}
+	return d / 2
+}
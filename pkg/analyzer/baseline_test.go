@@ -0,0 +1,164 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"kinsta-log-analyzer/pkg/config"
+)
+
+func TestSaveAndLoadBaseline(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "state")
+	if runs, err := LoadBaseline(dir, 10); err != nil || runs != nil {
+		t.Fatalf("LoadBaseline(missing dir) = %v, %v, want nil, nil", runs, err)
+	}
+
+	analyzedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 1; i <= 5; i++ {
+		s := RunSummary{Version: runSummaryVersion, AnalyzedAt: analyzedAt.Add(time.Duration(i) * time.Second), TotalRequests: i}
+		if _, err := SaveRunSummary(dir, s, 3); err != nil {
+			t.Fatalf("SaveRunSummary() error = %v", err)
+		}
+	}
+	// Files that are not run summaries of this version are skipped, and do
+	// not count towards n.
+	for name, data := range map[string]string{
+		"run_99990101_000000.json": `{"version": 999, "total_requests": 99}`,
+		"run_99990102_000000.json": `not json`,
+		"notes.json":               `{"version": 1, "total_requests": 98}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	requests := func(runs []RunSummary) []int {
+		var result []int
+		for _, r := range runs {
+			result = append(result, r.TotalRequests)
+		}
+		return result
+	}
+	tests := []struct {
+		n    int
+		want []int
+	}{
+		{10, []int{3, 4, 5}}, // pruned to the newest 3, oldest first
+		{2, []int{4, 5}},
+		{0, nil},
+	}
+	for _, tt := range tests {
+		runs, err := LoadBaseline(dir, tt.n)
+		if err != nil {
+			t.Fatalf("LoadBaseline(%d) error = %v", tt.n, err)
+		}
+		if got := requests(runs); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("LoadBaseline(%d) = runs %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestBaselineSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		in   config.Baseline
+		want config.Baseline
+	}{
+		{
+			"defaults",
+			config.Baseline{Enabled: true, MinChangePercent: 20},
+			config.Baseline{Enabled: true, StateDir: "./state", Runs: 14, MinRuns: 3, ScoreThreshold: 3.5, MinChangePercent: 20},
+		},
+		{
+			"negative values",
+			config.Baseline{Runs: -1, MinRuns: -1, ScoreThreshold: -1},
+			config.Baseline{StateDir: "./state", Runs: 14, MinRuns: 3, ScoreThreshold: 3.5},
+		},
+		{
+			"set values are kept",
+			config.Baseline{StateDir: "/var/lib/state", Runs: 30, MinRuns: 5, ScoreThreshold: 5},
+			config.Baseline{StateDir: "/var/lib/state", Runs: 30, MinRuns: 5, ScoreThreshold: 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BaselineSettings(&config.Config{Baseline: tt.in}); got != tt.want {
+				t.Errorf("BaselineSettings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareToBaseline(t *testing.T) {
+	// run is a summary with a dynamic route carrying the run's error rate.
+	run := func(perHour, errorRate, latency float64) RunSummary {
+		return RunSummary{
+			RequestsPerHour: perHour,
+			ErrorRate:       errorRate,
+			AvgResponseTime: latency,
+			Routes:          []RouteSummary{{Route: "class:dynamic", Requests: 500, ErrorRate: errorRate, AvgResponseTime: latency}},
+		}
+	}
+	// Requests/hour: median 1000, MAD 5. Error rate: median 1.0, MAD 0.1, so
+	// the 0.5 point floor sets the scale. Latency: flat at 0.2s, so the 0.05s
+	// floor sets the scale.
+	history := []RunSummary{
+		run(1000, 1.0, 0.2),
+		run(1010, 1.2, 0.2),
+		run(990, 0.8, 0.2),
+		run(1005, 1.1, 0.2),
+		run(995, 0.9, 0.2),
+	}
+
+	tests := []struct {
+		name             string
+		current          RunSummary
+		history          []RunSummary
+		minChangePercent float64
+		want             []string // regressions as "name route"
+	}{
+		{"typical run", run(1000, 1.0, 0.2), history, 20, nil},
+		{"error rate spike", run(1000, 5.0, 0.2), history, 20, []string{"エラー率 (%) ", "エラー率 (%) class:dynamic"}},
+		{"error rate rise below the threshold", run(1000, 2.5, 0.2), history, 20, nil},
+		{"error rate drop", run(1000, 0, 0.2), history, 20, nil},
+		{"latency rise", run(1000, 1.0, 0.38), history, 20, []string{"平均レスポンス時間 (秒) ", "平均レスポンス時間 (秒) class:dynamic"}},
+		{"latency rise below the minimum change", run(1000, 1.0, 0.38), history, 100, nil},
+		{"traffic drop", run(500, 1.0, 0.2), history, 20, []string{"リクエスト数/時間 "}},
+		{"traffic surge", run(2000, 1.0, 0.2), history, 20, []string{"リクエスト数/時間 "}},
+		{"too few runs", run(1000, 5.0, 0.2), history[:2], 20, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadTestConfig(t)
+			cfg.Baseline = config.Baseline{MinRuns: 3, ScoreThreshold: 3.5, MinChangePercent: tt.minChangePercent}
+			cfg.Thresholds.MinRequestsForErrorRate = 100
+
+			result := CompareToBaseline(tt.current, tt.history, cfg)
+			var got []string
+			for _, m := range result.Metrics {
+				if m.Regression {
+					got = append(got, m.Name+" "+m.Route)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) || result.Regressions != len(tt.want) {
+				t.Errorf("regressions = %d %v, want %v", result.Regressions, got, tt.want)
+			}
+			if result.Runs != len(tt.history) {
+				t.Errorf("Runs = %d, want %d", result.Runs, len(tt.history))
+			}
+		})
+	}
+
+	// A route with too few requests in the current run is not scored.
+	cfg := loadTestConfig(t)
+	cfg.Baseline = config.Baseline{MinRuns: 3, ScoreThreshold: 3.5, MinChangePercent: 20}
+	cfg.Thresholds.MinRequestsForErrorRate = 1000
+	for _, m := range CompareToBaseline(run(1000, 5.0, 0.2), history, cfg).Metrics {
+		if m.Route != "" {
+			t.Errorf("scored route %q with fewer than min_requests_for_error_rate requests", m.Route)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package analyzer
+
+import (
+	"path"
+	"strings"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/sketch"
+)
+
+// RequestClass is the coarse kind of resource a request targets. Error rates
+// and latency differ by orders of magnitude between classes (cached images vs
+// uncached PHP), so summary metrics are also reported per class.
+type RequestClass string
+
+const (
+	ClassStatic  RequestClass = "static"
+	ClassDynamic RequestClass = "dynamic"
+	ClassREST    RequestClass = "rest"
+	ClassAdmin   RequestClass = "admin"
+	ClassFeed    RequestClass = "feed"
+	ClassSitemap RequestClass = "sitemap"
+)
+
+// requestClasses fixes the display order of classes in reports.
+var requestClasses = []RequestClass{ClassDynamic, ClassStatic, ClassREST, ClassAdmin, ClassFeed, ClassSitemap}
+
+// staticExtensions are served as files by Nginx and never reach PHP.
+var staticExtensions = map[string]bool{
+	".css": true, ".js": true, ".map": true, ".json": true, ".xml": true, ".txt": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".svg": true, ".ico": true, ".avif": true, ".bmp": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".eot": true, ".otf": true,
+	".mp4": true, ".webm": true, ".mov": true, ".mp3": true, ".wav": true, ".ogg": true,
+	".pdf": true, ".zip": true,
+}
+
+// pagePath strips the query string so that /?p=1 and /?p=2 count as one page.
+func pagePath(uri string) string {
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		return uri[:i]
+	}
+	return uri
+}
+
+// classifyRequest assigns entry to a RequestClass by URL. Rules are checked
+// from most to least specific: REST, sitemap, feed, admin, static, dynamic.
+func classifyRequest(entry *parser.LogEntry) RequestClass {
+	p := strings.ToLower(pagePath(entry.URI))
+	query := ""
+	if i := strings.IndexByte(entry.URI, '?'); i >= 0 {
+		query = strings.ToLower(entry.URI[i+1:])
+	}
+
+	switch {
+	case strings.HasPrefix(p, "/wp-json/") || p == "/wp-json" || strings.Contains(query, "rest_route="):
+		return ClassREST
+	case strings.Contains(path.Base(p), "sitemap") && (strings.HasSuffix(p, ".xml") || strings.HasSuffix(p, ".xsl")):
+		return ClassSitemap
+	case strings.HasSuffix(p, "/feed") || strings.HasSuffix(p, "/feed/") || strings.Contains(p, "/feed/") ||
+		strings.HasPrefix(query, "feed=") || strings.Contains(query, "&feed="):
+		return ClassFeed
+	case strings.HasPrefix(p, "/wp-admin") || p == "/wp-login.php":
+		return ClassAdmin
+	case strings.HasPrefix(p, "/wp-content/uploads/") || staticExtensions[path.Ext(p)]:
+		return ClassStatic
+	}
+	return ClassDynamic
+}
+
+// ClassSummary は Summary の主要指標をリクエスト分類ごとに集計したもの。
+type ClassSummary struct {
+	Class           RequestClass
+	TotalRequests   int
+	Share           float64 // 全リクエストに占める割合 (%)
+	ErrorRate       float64
+	ClientErrors    int
+	ServerErrors    int
+	AvgResponseTime float64
+	Percentile50    float64
+	Percentile95    float64
+	Percentile99    float64
+	SlowRequests    int
+	UniqueIPs       UniqueEstimate
+}
+
+type classAccumulator struct {
+	requests           int
+	clientErrors       int
+	serverErrors       int
+	slowRequests       int
+	responseTimeMs     int64
+	responseTimeSample responseSample
+	ips                *sketch.HyperLogLog
+}
+
+func (a *Analyzer) trackClass(entry *parser.LogEntry, sample SampledTime) {
+	class := classifyRequest(entry)
+	acc := a.classStats[class]
+	if acc == nil {
+		acc = &classAccumulator{ips: sketch.NewHyperLogLog(a.config.Uniques.Precision)}
+		a.classStats[class] = acc
+	}
+	acc.requests++
+	if entry.IsClientError() {
+		acc.clientErrors++
+	} else if entry.IsServerError() {
+		acc.serverErrors++
+	}
+	if entry.IsSlowResponse(a.config.Thresholds.SlowRequestTime) {
+		acc.slowRequests++
+	}
+	acc.responseTimeMs += toMillis(entry.ResponseTime)
+	acc.responseTimeSample.add(sample)
+	acc.ips.Add(entry.ClientIP)
+}
+
+// generateClassSummaries returns one ClassSummary per observed class in
+// requestClasses order.
+func (a *Analyzer) generateClassSummaries() []ClassSummary {
+	var result []ClassSummary
+	for _, class := range requestClasses {
+		acc := a.classStats[class]
+		if acc == nil {
+			continue
+		}
+		errs := acc.clientErrors + acc.serverErrors
+		result = append(result, ClassSummary{
+			Class:           class,
+			TotalRequests:   acc.requests,
+			Share:           float64(acc.requests) / float64(a.totalRequests) * 100,
+			ErrorRate:       float64(errs) / float64(acc.requests) * 100,
+			ClientErrors:    acc.clientErrors,
+			ServerErrors:    acc.serverErrors,
+			AvgResponseTime: avgSeconds(acc.responseTimeMs, acc.requests),
+			Percentile50:    percentile(acc.responseTimeSample.values(), 0.50),
+			Percentile95:    percentile(acc.responseTimeSample.values(), 0.95),
+			Percentile99:    percentile(acc.responseTimeSample.values(), 0.99),
+			SlowRequests:    acc.slowRequests,
+			UniqueIPs:       estimate(acc.ips),
+		})
+	}
+	return result
+}
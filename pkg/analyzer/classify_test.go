@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"testing"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+func TestClassifyRequest(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want RequestClass
+	}{
+		{"/", ClassDynamic},
+		{"/blog/hello-world/", ClassDynamic},
+		{"/?p=12", ClassDynamic},
+		{"/wp-cron.php", ClassDynamic},
+		{"/xmlrpc.php", ClassDynamic},
+
+		{"/wp-json/wp/v2/posts", ClassREST},
+		{"/wp-json", ClassREST},
+		{"/WP-JSON/wp/v2/users", ClassREST},
+		{"/?rest_route=/wp/v2/users", ClassREST},
+		{"/wp-json/wp/v2/posts.json", ClassREST}, // REST before static
+
+		{"/sitemap.xml", ClassSitemap},
+		{"/wp-sitemap-posts-post-1.xml", ClassSitemap},
+		{"/sitemap_index.xml?x=1", ClassSitemap},
+		{"/main-sitemap.xsl", ClassSitemap},
+		{"/sitemap/", ClassDynamic},
+		{"/sitemaps/data.xml", ClassStatic}, // only the file name counts
+
+		{"/feed", ClassFeed},
+		{"/feed/", ClassFeed},
+		{"/blog/feed/atom/", ClassFeed},
+		{"/comments/feed", ClassFeed},
+		{"/?feed=rss2", ClassFeed},
+		{"/?cat=3&feed=rss2", ClassFeed},
+		{"/feedback/", ClassDynamic},
+		{"/?feedback=1", ClassDynamic},
+
+		{"/wp-admin/", ClassAdmin},
+		{"/wp-admin/admin-ajax.php", ClassAdmin},
+		{"/wp-admin/css/common.min.css", ClassAdmin}, // admin before static
+		{"/wp-login.php", ClassAdmin},
+		{"/wp-login.php?action=lostpassword", ClassAdmin},
+
+		{"/wp-content/uploads/2024/01/photo", ClassStatic},
+		{"/wp-content/themes/astra/style.css?ver=4.6.1", ClassStatic},
+		{"/wp-includes/js/jquery/jquery.min.js", ClassStatic},
+		{"/favicon.ico", ClassStatic},
+		{"/robots.txt", ClassStatic},
+		{"/files/Report.PDF", ClassStatic},
+		{"/wp-content/plugins/akismet/akismet.php", ClassDynamic},
+	}
+	for _, tt := range tests {
+		if got := classifyRequest(&parser.LogEntry{URI: tt.uri}); got != tt.want {
+			t.Errorf("classifyRequest(%q) = %s, want %s", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestClassSummaries(t *testing.T) {
+	cfg := loadTestConfig(t)
+	cfg.Thresholds.SlowRequestTime = 1
+	a := NewAnalyzer(cfg)
+	for _, e := range []parser.LogEntry{
+		{ClientIP: "192.0.2.1", URI: "/", StatusCode: 200, ResponseTime: 0.5},
+		{ClientIP: "192.0.2.2", URI: "/shop/", StatusCode: 502, ResponseTime: 2},
+		{ClientIP: "192.0.2.1", URI: "/missing/", StatusCode: 404, ResponseTime: 0.1},
+		{ClientIP: "192.0.2.1", URI: "/style.css", StatusCode: 200, ResponseTime: 0},
+	} {
+		a.totalRequests++
+		a.trackClass(&e, sampleOf(&e))
+	}
+
+	got := a.generateClassSummaries()
+	if len(got) != 2 || got[0].Class != ClassDynamic || got[1].Class != ClassStatic {
+		t.Fatalf("classes = %+v, want dynamic then static", got)
+	}
+	d := got[0]
+	if d.TotalRequests != 3 || d.Share != 75 || d.ClientErrors != 1 || d.ServerErrors != 1 || d.SlowRequests != 1 {
+		t.Errorf("dynamic = %+v, want 3 requests, 75%% share, 1 client error, 1 server error, 1 slow", d)
+	}
+	if d.AvgResponseTime != avgSeconds(2600, 3) || d.Percentile50 != 0.5 {
+		t.Errorf("dynamic latency avg %v p50 %v, want %v and 0.5", d.AvgResponseTime, d.Percentile50, avgSeconds(2600, 3))
+	}
+	if d.UniqueIPs.Estimate != 2 {
+		t.Errorf("dynamic unique IPs = %+v, want 2", d.UniqueIPs)
+	}
+}
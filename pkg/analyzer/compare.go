@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Comparison は 2つの解析結果（2ファイル、または1ファイルの2期間）の差分。
+// Before を基準とし、After での変化を表す。
+type Comparison struct {
+	BeforeLabel          string
+	AfterLabel           string
+	Before               Summary
+	After                Summary
+	Requests             MetricDelta
+	RequestsPerHour      MetricDelta
+	ErrorRate            MetricDelta // Change はパーセントポイント
+	AvgResponseTime      MetricDelta
+	Percentile50         MetricDelta
+	Percentile95         MetricDelta
+	Percentile99         MetricDelta
+	StatusShifts         []StatusShift
+	NewErrorURLs         []URLError // After のエラーURL上位にあり Before の上位にないもの
+	DisappearedErrorURLs []URLError // Before のエラーURL上位にあり After の上位にないもの
+	NewAttackingIPs      []SuspiciousIP
+}
+
+type MetricDelta struct {
+	Before        float64
+	After         float64
+	Change        float64 // After - Before
+	ChangePercent float64 // Before が 0 のときは意味を持たない
+}
+
+// StatusShift はステータスコードごとの件数とシェア（%）の変化。
+type StatusShift struct {
+	Code        int
+	Before      int
+	After       int
+	BeforeShare float64
+	AfterShare  float64
+	ShareChange float64 // パーセントポイント
+}
+
+func newMetricDelta(before, after float64) MetricDelta {
+	d := MetricDelta{Before: before, After: after, Change: after - before}
+	if before != 0 {
+		d.ChangePercent = (after - before) / before * 100
+	}
+	return d
+}
+
+// requestsPerHour normalises volume so windows of different length compare
+// fairly. Spans shorter than a minute are treated as one minute.
+func requestsPerHour(s Summary) float64 {
+	if s.TotalRequests == 0 {
+		return 0
+	}
+	span := s.EndTime.Sub(s.StartTime)
+	if span < time.Minute {
+		span = time.Minute
+	}
+	return float64(s.TotalRequests) / span.Hours()
+}
+
+// Compare reports how after differs from before. Error URL changes are judged
+// on the Top-N lists of each result, so a URL that merely moved across the
+// N-th rank also shows up as new or disappeared.
+func Compare(before, after *AnalysisResult) *Comparison {
+	bt, at := before.Statistics.ResponseTimeStats, after.Statistics.ResponseTimeStats
+	return &Comparison{
+		Before:               before.Summary,
+		After:                after.Summary,
+		Requests:             newMetricDelta(float64(before.Summary.TotalRequests), float64(after.Summary.TotalRequests)),
+		RequestsPerHour:      newMetricDelta(requestsPerHour(before.Summary), requestsPerHour(after.Summary)),
+		ErrorRate:            newMetricDelta(before.Summary.ErrorRate, after.Summary.ErrorRate),
+		AvgResponseTime:      newMetricDelta(bt.Average, at.Average),
+		Percentile50:         newMetricDelta(bt.Percentile50, at.Percentile50),
+		Percentile95:         newMetricDelta(bt.Percentile95, at.Percentile95),
+		Percentile99:         newMetricDelta(bt.Percentile99, at.Percentile99),
+		StatusShifts:         compareStatusCodes(before, after),
+		NewErrorURLs:         missingURLs(after.HTTPErrors.TopErrorURLs, before.HTTPErrors.TopErrorURLs),
+		DisappearedErrorURLs: missingURLs(before.HTTPErrors.TopErrorURLs, after.HTTPErrors.TopErrorURLs),
+		NewAttackingIPs:      newAttackingIPs(before, after),
+	}
+}
+
+// compareStatusCodes returns every status code seen on either side, ordered
+// by the absolute change in share so the biggest shifts come first.
+func compareStatusCodes(before, after *AnalysisResult) []StatusShift {
+	codes := make(map[int]bool)
+	for code := range before.Statistics.StatusCodes {
+		codes[code] = true
+	}
+	for code := range after.Statistics.StatusCodes {
+		codes[code] = true
+	}
+
+	share := func(count, total int) float64 {
+		if total == 0 {
+			return 0
+		}
+		return float64(count) / float64(total) * 100
+	}
+
+	var shifts []StatusShift
+	for code := range codes {
+		b := before.Statistics.StatusCodes[code]
+		a := after.Statistics.StatusCodes[code]
+		bs := share(b, before.Summary.TotalRequests)
+		as := share(a, after.Summary.TotalRequests)
+		shifts = append(shifts, StatusShift{
+			Code:        code,
+			Before:      b,
+			After:       a,
+			BeforeShare: bs,
+			AfterShare:  as,
+			ShareChange: as - bs,
+		})
+	}
+	sort.Slice(shifts, func(i, j int) bool {
+		ci, cj := math.Abs(shifts[i].ShareChange), math.Abs(shifts[j].ShareChange)
+		if ci != cj {
+			return ci > cj
+		}
+		return shifts[i].Code < shifts[j].Code
+	})
+	return shifts
+}
+
+// missingURLs returns the entries of urls whose URL does not appear in other.
+func missingURLs(urls, other []URLError) []URLError {
+	seen := make(map[string]bool, len(other))
+	for _, u := range other {
+		seen[u.URL] = true
+	}
+	var result []URLError
+	for _, u := range urls {
+		if !seen[u.URL] {
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// newAttackingIPs returns after's suspicious IPs that made no injection or
+// file inclusion attempt in before, keeping after's attack-score order.
+func newAttackingIPs(before, after *AnalysisResult) []SuspiciousIP {
+	var result []SuspiciousIP
+	for _, ip := range after.SecurityAnalysis.SuspiciousIPs {
+		if prev := before.SecurityAnalysis.AttacksByIP[ip.IP]; prev != nil && prev.attempts() > 0 {
+			continue
+		}
+		result = append(result, ip)
+	}
+	return result
+}
@@ -0,0 +1,168 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRequestsPerHour(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		total int
+		span  time.Duration
+		want  float64
+	}{
+		{"no requests", 0, time.Hour, 0},
+		{"single timestamp counts as a minute", 30, 0, 1800},
+		{"seconds count as a minute", 30, 30 * time.Second, 1800},
+		{"one minute", 10, time.Minute, 600},
+		{"two hours", 100, 2 * time.Hour, 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Summary{StartTime: start, EndTime: start.Add(tt.span), TotalRequests: tt.total}
+			if got := requestsPerHour(s); got != tt.want {
+				t.Errorf("requestsPerHour() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareStatusCodes(t *testing.T) {
+	result := func(codes map[int]int) *AnalysisResult {
+		total := 0
+		for _, n := range codes {
+			total += n
+		}
+		return &AnalysisResult{Summary: Summary{TotalRequests: total}, Statistics: Statistics{StatusCodes: codes}}
+	}
+	tests := []struct {
+		name   string
+		before map[int]int
+		after  map[int]int
+		want   []StatusShift
+	}{
+		{
+			name:   "equal shifts order by code",
+			before: map[int]int{200: 90, 404: 10},
+			after:  map[int]int{500: 10, 404: 10, 200: 80},
+			want: []StatusShift{
+				{Code: 200, Before: 90, After: 80, BeforeShare: 90, AfterShare: 80, ShareChange: -10},
+				{Code: 500, Before: 0, After: 10, BeforeShare: 0, AfterShare: 10, ShareChange: 10},
+				{Code: 404, Before: 10, After: 10, BeforeShare: 10, AfterShare: 10, ShareChange: 0},
+			},
+		},
+		{
+			name:   "codes on one side only",
+			before: map[int]int{200: 50, 301: 50},
+			after:  map[int]int{200: 75, 302: 25},
+			want: []StatusShift{
+				{Code: 301, Before: 50, After: 0, BeforeShare: 50, AfterShare: 0, ShareChange: -50},
+				{Code: 200, Before: 50, After: 75, BeforeShare: 50, AfterShare: 75, ShareChange: 25},
+				{Code: 302, Before: 0, After: 25, BeforeShare: 0, AfterShare: 25, ShareChange: 25},
+			},
+		},
+		{
+			name:   "empty before",
+			before: map[int]int{},
+			after:  map[int]int{200: 4},
+			want:   []StatusShift{{Code: 200, Before: 0, After: 4, BeforeShare: 0, AfterShare: 100, ShareChange: 100}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareStatusCodes(result(tt.before), result(tt.after))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("compareStatusCodes() =\n %+v\nwant\n %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMissingURLs(t *testing.T) {
+	tests := []struct {
+		name  string
+		urls  []URLError
+		other []URLError
+		want  []URLError
+	}{
+		{"all new", []URLError{{URL: "/a", Count: 3}}, nil, []URLError{{URL: "/a", Count: 3}}},
+		{"none new", []URLError{{URL: "/a", Count: 3}}, []URLError{{URL: "/a", Count: 1}}, nil},
+		{
+			"keeps order and counts",
+			[]URLError{{URL: "/c", Count: 9}, {URL: "/a", Count: 5}, {URL: "/b", Count: 2}},
+			[]URLError{{URL: "/a", Count: 100}},
+			[]URLError{{URL: "/c", Count: 9}, {URL: "/b", Count: 2}},
+		},
+		{"no urls", nil, []URLError{{URL: "/a", Count: 1}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := missingURLs(tt.urls, tt.other); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("missingURLs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAttackingIPs(t *testing.T) {
+	before := &AnalysisResult{SecurityAnalysis: SecurityAnalysis{AttacksByIP: map[string]*IPAttacks{
+		"192.0.2.1": {SQLAttempts: 2, TotalRequests: 5},
+		"192.0.2.2": {RFIAttempts: 1, TotalRequests: 1},
+		// Only errors before: no attempt, so attacking after is new.
+		"192.0.2.3": {TotalRequests: 8, ErrorCount: 8},
+	}}}
+	after := &AnalysisResult{SecurityAnalysis: SecurityAnalysis{SuspiciousIPs: []SuspiciousIP{
+		{IP: "198.51.100.1", SQLAttempts: 9},
+		{IP: "192.0.2.1", SQLAttempts: 4},
+		{IP: "192.0.2.3", XSSAttempts: 3},
+		{IP: "192.0.2.2", RFIAttempts: 1},
+		{IP: "198.51.100.2", LFIAttempts: 1},
+	}}}
+
+	var got []string
+	for _, ip := range newAttackingIPs(before, after) {
+		got = append(got, ip.IP)
+	}
+	want := []string{"198.51.100.1", "192.0.2.3", "198.51.100.2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("newAttackingIPs() = %v, want %v", got, want)
+	}
+}
+
+// TestSplitWindow analyzes one file before and after a split time, as
+// --split-at does: every entry lands on exactly one side by its timestamp,
+// late writes included, sequentially and in parallel.
+func TestSplitWindow(t *testing.T) {
+	cfg := loadTestConfig(t)
+	path := writeGeneratedLog(t)
+	total := analyzeFile(t, cfg, path, 1).Summary.TotalRequests
+	split := time.Date(2026, 3, 1, 9, 10, 0, 0, time.UTC)
+
+	for _, workers := range []int{1, 4} {
+		analyze := func(from, to time.Time) Summary {
+			a := NewAnalyzer(cfg)
+			a.SetWorkers(workers)
+			a.SetTimeWindow(from, to)
+			result, err := a.AnalyzeFile(path)
+			if err != nil {
+				t.Fatalf("AnalyzeFile() error = %v", err)
+			}
+			return result.Summary
+		}
+		before := analyze(time.Time{}, split)
+		after := analyze(split, time.Time{})
+
+		if before.TotalRequests == 0 || after.TotalRequests == 0 || before.TotalRequests+after.TotalRequests != total {
+			t.Errorf("workers=%d: before %d + after %d requests, want %d in all", workers, before.TotalRequests, after.TotalRequests, total)
+		}
+		if !before.EndTime.Before(split) {
+			t.Errorf("workers=%d: before ends at %v, want before %v", workers, before.EndTime, split)
+		}
+		if !after.StartTime.Equal(split) {
+			t.Errorf("workers=%d: after starts at %v, want %v", workers, after.StartTime, split)
+		}
+	}
+}
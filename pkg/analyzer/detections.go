@@ -0,0 +1,307 @@
+package analyzer
+
+import (
+	"sort"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/rules"
+	"kinsta-log-analyzer/pkg/sketch"
+)
+
+// maxRuleExampleLength bounds the matched value kept as a rule's example.
+const maxRuleExampleLength = 200
+
+// RuleHit はルール1件の検出結果。ノイズの多いルールの調整に使う。
+type RuleHit struct {
+	ID          string
+	Category    string
+	Severity    rules.Severity
+	Description string
+	CVE         []string
+	Hits        int            // 一致したリクエスト数
+	UniqueIPs   UniqueEstimate // HyperLogLog 推定
+	Example     string         // 最初に一致したフィールドの値
+	Field       string         // Example のフィールド（uri / ua / referer）
+	TopIPs      []IPCount      // CVE を持つルールのみ
+	FirstSeen   time.Time
+	LastSeen    time.Time
+}
+
+// CategoryHit はカテゴリ別の検出結果。1リクエストが同じカテゴリの
+// 複数ルールに一致しても1件と数える。
+type CategoryHit struct {
+	Category  string
+	Severity  rules.Severity // 一致したルールの最大重大度
+	Requests  int
+	Rules     int // 一致したルール数
+	UniqueIPs UniqueEstimate
+	TopIPs    []IPCount
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+type ruleAccumulator struct {
+	hits        int
+	ips         *sketch.HyperLogLog
+	topIPs      *sketch.TopK // nil unless the rule has a CVE
+	seen        seenRange
+	example     string
+	field       string
+	exampleSeen time.Time
+}
+
+// offerExample keeps the example seen earliest, breaking ties on the value,
+// so that the example does not depend on which shard or snapshot saw it first.
+func (acc *ruleAccumulator) offerExample(t time.Time, example, field string) {
+	if acc.example != "" && (t.After(acc.exampleSeen) || (t.Equal(acc.exampleSeen) && example >= acc.example)) {
+		return
+	}
+	acc.example, acc.field, acc.exampleSeen = example, field, t
+}
+
+type categoryAccumulator struct {
+	requests  int
+	ips       *sketch.TopK
+	uniqueIPs *sketch.HyperLogLog
+	seen      seenRange
+}
+
+// seenRange is the time of the first and last request of a detection.
+type seenRange struct {
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+}
+
+func (r *seenRange) add(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if r.First.IsZero() || t.Before(r.First) {
+		r.First = t
+	}
+	if t.After(r.Last) {
+		r.Last = t
+	}
+}
+
+func (r *seenRange) merge(other seenRange) {
+	r.add(other.First)
+	r.add(other.Last)
+}
+
+// isAutomatedClient reports whether a crawler or attack-tool rule matched the
+// User-Agent (config.IsCrawler / IsAttackTool).
+func isAutomatedClient(matches []rules.Match) bool {
+	for _, m := range matches {
+		if m.Field == rules.FieldUserAgent && (m.Rule.Category == rules.CategoryCrawler || m.Rule.Category == rules.CategoryScanner) {
+			return true
+		}
+	}
+	return false
+}
+
+// trackDetections counts the rules entry matched per rule and per category.
+// The sqli/xss/traversal/lfi/rfi/crawler/scanner categories also feed the per-IP attack counts
+// and the User-Agent analysis, and the first exposure rule the sensitive file analysis.
+func (a *Analyzer) trackDetections(entry *parser.LogEntry, matches []rules.Match) {
+	if len(matches) == 0 {
+		return
+	}
+
+	// Matches come in configuration order, so a category can recur after
+	// another one; a request is counted once per category.
+	var seen [8]string
+	categories := seen[:0]
+	var exposure *rules.Rule
+	for _, m := range matches {
+		acc := a.ruleHits[m.Rule.ID]
+		if acc == nil {
+			acc = &ruleAccumulator{ips: sketch.NewHyperLogLog(a.config.Uniques.Precision)}
+			// Source IPs are only reported per rule for CVE rules; other
+			// rules share their category's.
+			if len(m.Rule.CVE) > 0 {
+				acc.topIPs = sketch.NewTopK(a.config.HeavyHitters.Capacity)
+			}
+			a.ruleHits[m.Rule.ID] = acc
+		}
+		acc.hits++
+		acc.ips.Add(entry.ClientIP)
+		if acc.topIPs != nil {
+			acc.topIPs.Add(entry.ClientIP, 1)
+		}
+		acc.seen.add(entry.Timestamp)
+		acc.offerExample(entry.Timestamp, truncateExample(m.Value), m.Field)
+		if !containsString(categories, m.Rule.Category) {
+			categories = append(categories, m.Rule.Category)
+		}
+		if m.Rule.Category == rules.CategoryExposure && exposure == nil {
+			exposure = m.Rule
+		}
+	}
+	if exposure != nil {
+		a.trackExposure(entry, exposure)
+	}
+
+	for _, category := range categories {
+		acc := a.categoryHits[category]
+		if acc == nil {
+			acc = &categoryAccumulator{
+				ips:       sketch.NewTopK(a.config.HeavyHitters.Capacity),
+				uniqueIPs: sketch.NewHyperLogLog(a.config.Uniques.Precision),
+			}
+			a.categoryHits[category] = acc
+		}
+		acc.requests++
+		acc.ips.Add(entry.ClientIP, 1)
+		acc.uniqueIPs.Add(entry.ClientIP)
+		acc.seen.add(entry.Timestamp)
+
+		switch category {
+		case rules.CategorySQLi:
+			a.attacksByIP[entry.ClientIP].SQLAttempts++
+		case rules.CategoryXSS:
+			a.attacksByIP[entry.ClientIP].XSSAttempts++
+		case rules.CategoryTraversal:
+			a.attacksByIP[entry.ClientIP].TraversalAttempts++
+		case rules.CategoryLFI:
+			a.attacksByIP[entry.ClientIP].LFIAttempts++
+		case rules.CategoryRFI:
+			a.attacksByIP[entry.ClientIP].RFIAttempts++
+		case rules.CategoryCrawler:
+			a.crawlers[entry.UserAgent]++
+		case rules.CategoryScanner:
+			a.attackTools[entry.UserAgent]++
+		}
+	}
+}
+
+// attempts is the number of injection and file inclusion attempts, the
+// basis of an IP's attack score.
+func (a *IPAttacks) attempts() int {
+	return a.SQLAttempts + a.XSSAttempts + a.TraversalAttempts + a.LFIAttempts + a.RFIAttempts
+}
+
+func truncateExample(s string) string {
+	if len(s) <= maxRuleExampleLength {
+		return s
+	}
+	return s[:maxRuleExampleLength] + "..."
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// generateRuleHits returns the rules that matched at least once, most severe
+// first, then by hits.
+func (a *Analyzer) generateRuleHits() []RuleHit {
+	var result []RuleHit
+	for _, id := range sortedKeys(a.ruleHits) {
+		acc := a.ruleHits[id]
+		hit := RuleHit{
+			ID:        id,
+			Hits:      acc.hits,
+			UniqueIPs: estimate(acc.ips),
+			FirstSeen: acc.seen.First,
+			LastSeen:  acc.seen.Last,
+			Example:   acc.example,
+			Field:     acc.field,
+		}
+		if acc.topIPs != nil {
+			hit.TopIPs = topIPCounts(acc.topIPs, 5)
+		}
+		// Rules removed from the configuration since a snapshot was taken
+		// are still reported, just without their metadata.
+		if r, ok := a.config.Rules().Rule(id); ok {
+			hit.Category = r.Category
+			hit.Severity = r.Severity
+			hit.Description = r.Description
+			hit.CVE = r.CVE
+		}
+		result = append(result, hit)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if ri, rj := result[i].Severity.Rank(), result[j].Severity.Rank(); ri != rj {
+			return ri > rj
+		}
+		return result[i].Hits > result[j].Hits
+	})
+	return result
+}
+
+func topIPCounts(ips *sketch.TopK, n int) []IPCount {
+	var result []IPCount
+	for _, item := range ips.Top(n) {
+		result = append(result, IPCount{IP: item.Key, Count: item.Count, ErrorBound: item.Error})
+	}
+	return result
+}
+
+// generateCVERules returns every configured rule that names a CVE, with its
+// detections, so the report can also show which CVEs were not probed.
+// Detected rules come first, most recently seen first; the rest keep the
+// configuration order.
+func (a *Analyzer) generateCVERules(ruleHits []RuleHit) []RuleHit {
+	hits := make(map[string]RuleHit, len(ruleHits))
+	for _, h := range ruleHits {
+		hits[h.ID] = h
+	}
+	var result []RuleHit
+	for _, r := range a.config.Rules().Rules() {
+		if len(r.CVE) == 0 {
+			continue
+		}
+		hit, ok := hits[r.ID]
+		if !ok {
+			hit = RuleHit{ID: r.ID, Category: r.Category, Severity: r.Severity, Description: r.Description, CVE: r.CVE}
+		}
+		result = append(result, hit)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if (result[i].Hits > 0) != (result[j].Hits > 0) {
+			return result[i].Hits > 0
+		}
+		return result[i].LastSeen.After(result[j].LastSeen)
+	})
+	return result
+}
+
+// generateCategoryHits summarises the detections per category, most severe
+// first, then by requests.
+func (a *Analyzer) generateCategoryHits(ruleHits []RuleHit) []CategoryHit {
+	var result []CategoryHit
+	for _, category := range sortedKeys(a.categoryHits) {
+		acc := a.categoryHits[category]
+		hit := CategoryHit{
+			Category:  category,
+			Requests:  acc.requests,
+			UniqueIPs: estimate(acc.uniqueIPs),
+			TopIPs:    topIPCounts(acc.ips, 5),
+			FirstSeen: acc.seen.First,
+			LastSeen:  acc.seen.Last,
+		}
+		for _, r := range ruleHits {
+			if r.Category != category {
+				continue
+			}
+			hit.Rules++
+			if r.Severity.Rank() > hit.Severity.Rank() {
+				hit.Severity = r.Severity
+			}
+		}
+		result = append(result, hit)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if ri, rj := result[i].Severity.Rank(), result[j].Severity.Rank(); ri != rj {
+			return ri > rj
+		}
+		return result[i].Requests > result[j].Requests
+	})
+	return result
+}
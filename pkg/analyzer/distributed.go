@@ -0,0 +1,375 @@
+package analyzer
+
+import (
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+// Login endpoints watched for distributed brute force.
+const (
+	LoginEndpointWPLogin     = "wp-login.php"
+	LoginEndpointJWT         = "jwt-auth"
+	LoginEndpointWooCommerce = "my-account"
+)
+
+// Defaults used when the wordpress.distributed_* settings are not set.
+const (
+	defaultDistributedMinIPs           = 10
+	defaultDistributedMaxAttemptsPerIP = 5
+)
+
+// maxWindowClients caps the clients tracked per login window; further
+// clients are folded into otherLoginClient.
+const maxWindowClients = 10000
+
+// maxCampaignIPs caps the participating IPs listed per campaign, and
+// maxCampaignClusters the user agents and subnets.
+const (
+	maxCampaignIPs      = 50
+	maxCampaignClusters = 5
+)
+
+const otherLoginClient = "(その他)"
+
+// DistributedLoginAnalysis は多数のIPがそれぞれ少数回だけログインを試す分散型
+// ブルートフォース（ボットネット）の検出結果。IP ごとの閾値
+// （BruteForceThreshold）を下回るよう分散された攻撃を、時間ウィンドウ単位で捉える。
+type DistributedLoginAnalysis struct {
+	WindowMinutes    int             // 0 なら無効
+	MinIPs           int             // ウィンドウ内で失敗した少数試行IPがこの数以上で検出
+	MaxAttemptsPerIP int             // ウィンドウ内の試行がこの回数以下のIPを少数試行とみなす
+	MinFailureRate   float64         // 少数試行IPの失敗率（%）の下限
+	Attempts         int             // ログイン試行の合計（全エンドポイント）
+	Campaigns        []LoginCampaign // 開始時刻順
+}
+
+// LoginCampaign は連続して検出されたウィンドウをまとめたもの。件数は失敗のあった
+// 少数試行クライアント（IP+UA）の試行のみで、1IPで多数試行したもの（BruteForceIPs 側で扱う）
+// や一度で成功した利用者は含まない。
+type LoginCampaign struct {
+	Endpoint   string
+	Start      time.Time
+	End        time.Time
+	Windows    int
+	Attempts   int
+	Failures   int // 成功以外（拒否を含む）
+	Successes  int
+	IPs        int
+	MaxPerIP   int            // キャンペーン全体での1IPあたり試行数の最大
+	UserAgents []LoginCluster // 参加IP数順
+	Subnets    []LoginCluster // IPv4 は /24、IPv6 は /48
+	TopIPs     []IPCount      // 参加IP（試行数順 上位50）
+	SuccessIPs []string       // 失敗の後にログインに成功した参加IP（アカウント侵害の可能性）
+}
+
+type LoginCluster struct {
+	Key      string
+	IPs      int
+	Attempts int
+}
+
+type loginWindowKey struct {
+	endpoint string
+	start    int64 // unix seconds
+}
+
+type loginWindow struct {
+	clients map[string]*loginClient // IP + "\x00" + UA
+}
+
+type loginClient struct {
+	attempts  int
+	successes int
+}
+
+// loginEndpoint returns the login endpoint a POST targets, or "" when it is
+// not a login. lowerPath is the lowercased path without the query.
+// WooCommerce posts its login form to the my-account page itself; the pages
+// below it (edit-account, edit-address, lost-password ...) are not logins.
+func loginEndpoint(lowerPath, query string) string {
+	switch {
+	case strings.HasSuffix(lowerPath, "/wp-login.php"):
+		if isLoginAction(query) {
+			return LoginEndpointWPLogin
+		}
+		return ""
+	case strings.HasPrefix(lowerPath, "/wp-json/jwt-auth/"):
+		if strings.HasSuffix(strings.TrimSuffix(lowerPath, "/"), "/token") {
+			return LoginEndpointJWT
+		}
+		return ""
+	case strings.HasSuffix(strings.TrimSuffix(lowerPath, "/"), "/my-account"):
+		return LoginEndpointWooCommerce
+	}
+	if strings.Contains(query, "rest_route=") {
+		values, err := url.ParseQuery(query)
+		route := strings.TrimSuffix(strings.ToLower(values.Get("rest_route")), "/")
+		if err == nil && strings.HasPrefix(route, "/jwt-auth/") && strings.HasSuffix(route, "/token") {
+			return LoginEndpointJWT
+		}
+	}
+	return ""
+}
+
+// loginSucceeded reports whether a login POST succeeded: wp-login.php and
+// WooCommerce redirect on success and redisplay the form with 200 on failure,
+// while the JWT plugin answers 200 with a token and 403 otherwise.
+func loginSucceeded(endpoint string, status int) bool {
+	if endpoint == LoginEndpointJWT {
+		return status == 200
+	}
+	return status == 302 || status == 303
+}
+
+// trackDistributedLogin counts a login POST in its time window, per client.
+func (a *Analyzer) trackDistributedLogin(entry *parser.LogEntry, lowerPath, query string) {
+	windowSeconds := int64(a.config.WordPress.DistributedWindowMinutes) * 60
+	if windowSeconds <= 0 {
+		return
+	}
+	endpoint := loginEndpoint(lowerPath, query)
+	if endpoint == "" {
+		return
+	}
+	a.distributedAttempts++
+
+	unix := entry.Timestamp.Unix()
+	key := loginWindowKey{endpoint: endpoint, start: unix - unix%windowSeconds}
+	w := a.loginWindows[key]
+	if w == nil {
+		w = &loginWindow{clients: make(map[string]*loginClient)}
+		a.loginWindows[key] = w
+	}
+	client := entry.ClientIP + "\x00" + entry.UserAgent
+	c := w.clients[client]
+	if c == nil {
+		if len(w.clients) >= maxWindowClients {
+			client = otherLoginClient
+			c = w.clients[client]
+		}
+		if c == nil {
+			c = &loginClient{}
+			w.clients[client] = c
+		}
+	}
+	c.attempts++
+	if loginSucceeded(endpoint, entry.StatusCode) {
+		c.successes++
+	}
+}
+
+func (a *Analyzer) distributedMinIPs() int {
+	if n := a.config.WordPress.DistributedMinIPs; n > 0 {
+		return n
+	}
+	return defaultDistributedMinIPs
+}
+
+func (a *Analyzer) distributedMaxAttemptsPerIP() int {
+	if n := a.config.WordPress.DistributedMaxAttemptsPerIP; n > 0 {
+		return n
+	}
+	return defaultDistributedMaxAttemptsPerIP
+}
+
+// campaignBuilder accumulates the participants of consecutive flagged
+// windows.
+type campaignBuilder struct {
+	campaign LoginCampaign
+	ips      map[string]*loginClient
+	uas      map[string]map[string]int // UA -> IP -> attempts
+	subnets  map[string]map[string]int // subnet -> IP -> attempts
+}
+
+func (a *Analyzer) generateDistributedLogin() DistributedLoginAnalysis {
+	result := DistributedLoginAnalysis{
+		WindowMinutes:    a.config.WordPress.DistributedWindowMinutes,
+		MinIPs:           a.distributedMinIPs(),
+		MaxAttemptsPerIP: a.distributedMaxAttemptsPerIP(),
+		MinFailureRate:   a.config.WordPress.DistributedMinFailureRate,
+		Attempts:         a.distributedAttempts,
+	}
+	for _, b := range a.loginCampaigns() {
+		result.Campaigns = append(result.Campaigns, b.build())
+	}
+	sort.SliceStable(result.Campaigns, func(i, j int) bool { return result.Campaigns[i].Start.Before(result.Campaigns[j].Start) })
+	return result
+}
+
+// loginCampaigns flags windows where at least MinIPs IPs each made at most
+// MaxAttemptsPerIP attempts and mostly failed, and merges consecutive
+// flagged windows of an endpoint into campaigns. Clients of a flagged window
+// that never failed are ordinary users, not participants.
+func (a *Analyzer) loginCampaigns() []*campaignBuilder {
+	windowSeconds := int64(a.config.WordPress.DistributedWindowMinutes) * 60
+	if windowSeconds <= 0 {
+		return nil
+	}
+	minIPs, maxPerIP := a.distributedMinIPs(), a.distributedMaxAttemptsPerIP()
+	minFailureRate := a.config.WordPress.DistributedMinFailureRate
+
+	keys := make([]loginWindowKey, 0, len(a.loginWindows))
+	for key := range a.loginWindows {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].start < keys[j].start
+	})
+
+	var result []*campaignBuilder
+	var current *campaignBuilder
+	var prev loginWindowKey
+	for _, key := range keys {
+		participants := a.loginWindows[key].lowVolumeClients(maxPerIP)
+		if !isDistributedAttack(participants, minIPs, minFailureRate) {
+			continue
+		}
+		if current == nil || key.endpoint != prev.endpoint || key.start != prev.start+windowSeconds {
+			current = &campaignBuilder{
+				campaign: LoginCampaign{Endpoint: key.endpoint, Start: time.Unix(key.start, 0).UTC()},
+				ips:      make(map[string]*loginClient),
+				uas:      make(map[string]map[string]int),
+				subnets:  make(map[string]map[string]int),
+			}
+			result = append(result, current)
+		}
+		current.campaign.End = time.Unix(key.start+windowSeconds, 0).UTC()
+		current.campaign.Windows++
+		for client, c := range participants {
+			if c.failed() {
+				current.add(client, c)
+			}
+		}
+		prev = key
+	}
+	return result
+}
+
+// lowVolumeClients returns the clients whose IP made at most maxPerIP
+// attempts in the window, over all of its user agents.
+func (w *loginWindow) lowVolumeClients(maxPerIP int) map[string]*loginClient {
+	perIP := make(map[string]int)
+	for client, c := range w.clients {
+		ip, _, _ := strings.Cut(client, "\x00")
+		perIP[ip] += c.attempts
+	}
+	result := make(map[string]*loginClient)
+	for client, c := range w.clients {
+		ip, _, _ := strings.Cut(client, "\x00")
+		if client != otherLoginClient && perIP[ip] <= maxPerIP {
+			result[client] = c
+		}
+	}
+	return result
+}
+
+// isDistributedAttack reports whether at least minIPs of the low-volume
+// clients' IPs failed to log in and the failure rate over all of them,
+// legitimate users included, reaches minFailureRate percent.
+func isDistributedAttack(clients map[string]*loginClient, minIPs int, minFailureRate float64) bool {
+	failedIPs := make(map[string]bool)
+	attempts, successes := 0, 0
+	for client, c := range clients {
+		if c.failed() {
+			ip, _, _ := strings.Cut(client, "\x00")
+			failedIPs[ip] = true
+		}
+		attempts += c.attempts
+		successes += c.successes
+	}
+	if len(failedIPs) < minIPs || attempts == 0 {
+		return false
+	}
+	return float64(attempts-successes)/float64(attempts)*100 >= minFailureRate
+}
+
+func (c *loginClient) failed() bool {
+	return c.attempts > c.successes
+}
+
+func (b *campaignBuilder) add(client string, c *loginClient) {
+	ip, ua, _ := strings.Cut(client, "\x00")
+	b.campaign.Attempts += c.attempts
+	b.campaign.Successes += c.successes
+	b.campaign.Failures += c.attempts - c.successes
+
+	acc := b.ips[ip]
+	if acc == nil {
+		acc = &loginClient{}
+		b.ips[ip] = acc
+	}
+	acc.attempts += c.attempts
+	acc.successes += c.successes
+
+	if b.uas[ua] == nil {
+		b.uas[ua] = make(map[string]int)
+	}
+	b.uas[ua][ip] += c.attempts
+	subnet := subnetOf(ip)
+	if b.subnets[subnet] == nil {
+		b.subnets[subnet] = make(map[string]int)
+	}
+	b.subnets[subnet][ip] += c.attempts
+}
+
+func (b *campaignBuilder) build() LoginCampaign {
+	c := b.campaign
+	c.IPs = len(b.ips)
+	for _, ip := range sortedKeys(b.ips) {
+		acc := b.ips[ip]
+		c.MaxPerIP = max(c.MaxPerIP, acc.attempts)
+		c.TopIPs = append(c.TopIPs, IPCount{IP: ip, Count: acc.attempts})
+		if acc.successes > 0 {
+			c.SuccessIPs = append(c.SuccessIPs, ip)
+		}
+	}
+	sort.SliceStable(c.TopIPs, func(i, j int) bool { return c.TopIPs[i].Count > c.TopIPs[j].Count })
+	if len(c.TopIPs) > maxCampaignIPs {
+		c.TopIPs = c.TopIPs[:maxCampaignIPs]
+	}
+	c.UserAgents = loginClusters(b.uas)
+	c.Subnets = loginClusters(b.subnets)
+	return c
+}
+
+func loginClusters(groups map[string]map[string]int) []LoginCluster {
+	var result []LoginCluster
+	for _, key := range sortedKeys(groups) {
+		cluster := LoginCluster{Key: key, IPs: len(groups[key])}
+		for _, attempts := range groups[key] {
+			cluster.Attempts += attempts
+		}
+		result = append(result, cluster)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].IPs > result[j].IPs })
+	if len(result) > maxCampaignClusters {
+		result = result[:maxCampaignClusters]
+	}
+	return result
+}
+
+// subnetOf returns the /24 of an IPv4 address or the /48 of an IPv6 one, or
+// ip itself when it does not parse.
+func subnetOf(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	bits := 48
+	if addr.Is4() || addr.Is4In6() {
+		addr, bits = addr.Unmap(), 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
@@ -0,0 +1,201 @@
+package analyzer
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+func TestLoginEndpoint(t *testing.T) {
+	tests := []struct {
+		path  string
+		query string
+		want  string
+	}{
+		{"/wp-login.php", "", LoginEndpointWPLogin},
+		{"/blog/wp-login.php", "action=login", LoginEndpointWPLogin},
+		{"/wp-login.php", "action=lostpassword", ""},
+		{"/wp-login.php", "action=register", ""},
+		{"/wp-json/jwt-auth/v1/token", "", LoginEndpointJWT},
+		{"/wp-json/jwt-auth/v1/token/", "", LoginEndpointJWT},
+		{"/wp-json/jwt-auth/v1/token/validate", "", ""},
+		{"/", "rest_route=/jwt-auth/v1/token", LoginEndpointJWT},
+		{"/", "rest_route=%2Fjwt-auth%2Fv1%2Ftoken%2F", LoginEndpointJWT},
+		{"/", "rest_route=/wp/v2/users", ""},
+		{"/my-account/", "", LoginEndpointWooCommerce},
+		{"/shop/my-account", "", LoginEndpointWooCommerce},
+		{"/my-account/lost-password/", "", ""},
+		{"/my-account/edit-account/", "", ""},
+		{"/my-account/edit-address/billing/", "", ""},
+		{"/xmlrpc.php", "", ""},
+		{"/contact/", "", ""},
+	}
+	for _, tt := range tests {
+		if got := loginEndpoint(tt.path, tt.query); got != tt.want {
+			t.Errorf("loginEndpoint(%q, %q) = %q, want %q", tt.path, tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestLoginSucceeded(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		status   int
+		want     bool
+	}{
+		{LoginEndpointWPLogin, 302, true},
+		{LoginEndpointWPLogin, 303, true},
+		{LoginEndpointWPLogin, 200, false},
+		{LoginEndpointWPLogin, 403, false},
+		{LoginEndpointWooCommerce, 302, true},
+		{LoginEndpointWooCommerce, 200, false},
+		{LoginEndpointJWT, 200, true},
+		{LoginEndpointJWT, 302, false},
+		{LoginEndpointJWT, 403, false},
+	}
+	for _, tt := range tests {
+		if got := loginSucceeded(tt.endpoint, tt.status); got != tt.want {
+			t.Errorf("loginSucceeded(%q, %d) = %v, want %v", tt.endpoint, tt.status, got, tt.want)
+		}
+	}
+}
+
+// loginClients builds a window's clients, one per IP, from {attempts,
+// successes} pairs.
+func loginClients(counts ...[2]int) map[string]*loginClient {
+	clients := make(map[string]*loginClient)
+	for i, c := range counts {
+		clients[fmt.Sprintf("192.0.2.%d\x00bot", i+1)] = &loginClient{attempts: c[0], successes: c[1]}
+	}
+	return clients
+}
+
+func TestIsDistributedAttack(t *testing.T) {
+	tests := []struct {
+		name           string
+		clients        map[string]*loginClient
+		minIPs         int
+		minFailureRate float64
+		want           bool
+	}{
+		{"enough failing IPs", loginClients([2]int{2, 0}, [2]int{1, 0}, [2]int{3, 0}), 3, 90, true},
+		{"too few failing IPs", loginClients([2]int{2, 0}, [2]int{1, 0}, [2]int{1, 1}), 3, 0, false},
+		{"legitimate users dilute the failure rate", loginClients([2]int{1, 0}, [2]int{1, 0}, [2]int{1, 0}, [2]int{1, 1}, [2]int{1, 1}, [2]int{1, 1}), 3, 60, false},
+		{"failure rate reaches the minimum", loginClients([2]int{1, 0}, [2]int{1, 0}, [2]int{1, 0}, [2]int{1, 1}), 3, 75, true},
+		{"no clients", loginClients(), 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDistributedAttack(tt.clients, tt.minIPs, tt.minFailureRate); got != tt.want {
+				t.Errorf("isDistributedAttack() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Two user agents of one IP count as a single failing IP.
+	clients := map[string]*loginClient{
+		"192.0.2.1\x00a": {attempts: 1},
+		"192.0.2.1\x00b": {attempts: 1},
+		"192.0.2.2\x00a": {attempts: 1},
+	}
+	if isDistributedAttack(clients, 3, 0) {
+		t.Error("isDistributedAttack() counted one IP's user agents as separate IPs")
+	}
+}
+
+func TestLoginCampaigns(t *testing.T) {
+	const window = 10 * time.Minute
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// attack sends one failed login from each of ips IPs in the window at
+	// offset windows after start.
+	attack := func(a *Analyzer, path string, offset, ips int) {
+		at := start.Add(time.Duration(offset) * window)
+		for i := 0; i < ips; i++ {
+			entry := &parser.LogEntry{
+				Timestamp:  at.Add(time.Duration(i) * time.Second),
+				ClientIP:   fmt.Sprintf("198.51.100.%d", i+1),
+				UserAgent:  "bot",
+				Method:     "POST",
+				URI:        path,
+				StatusCode: 200,
+			}
+			a.trackDistributedLogin(entry, path, "")
+		}
+	}
+
+	tests := []struct {
+		name string
+		run  func(a *Analyzer)
+		want []LoginCampaign // Endpoint, Start, Windows and IPs are compared
+	}{
+		{
+			name: "consecutive windows merge",
+			run: func(a *Analyzer) {
+				attack(a, "/wp-login.php", 0, 5)
+				attack(a, "/wp-login.php", 1, 5)
+				attack(a, "/wp-login.php", 2, 5)
+			},
+			want: []LoginCampaign{{Endpoint: LoginEndpointWPLogin, Start: start, Windows: 3, IPs: 5}},
+		},
+		{
+			name: "a quiet window splits campaigns",
+			run: func(a *Analyzer) {
+				attack(a, "/wp-login.php", 0, 5)
+				attack(a, "/wp-login.php", 2, 5)
+			},
+			want: []LoginCampaign{
+				{Endpoint: LoginEndpointWPLogin, Start: start, Windows: 1, IPs: 5},
+				{Endpoint: LoginEndpointWPLogin, Start: start.Add(2 * window), Windows: 1, IPs: 5},
+			},
+		},
+		{
+			name: "a window below the threshold splits campaigns",
+			run: func(a *Analyzer) {
+				attack(a, "/wp-login.php", 0, 5)
+				attack(a, "/wp-login.php", 1, 2)
+				attack(a, "/wp-login.php", 2, 5)
+			},
+			want: []LoginCampaign{
+				{Endpoint: LoginEndpointWPLogin, Start: start, Windows: 1, IPs: 5},
+				{Endpoint: LoginEndpointWPLogin, Start: start.Add(2 * window), Windows: 1, IPs: 5},
+			},
+		},
+		{
+			name: "endpoints stay separate",
+			run: func(a *Analyzer) {
+				attack(a, "/wp-login.php", 0, 5)
+				attack(a, "/my-account/", 1, 5)
+			},
+			want: []LoginCampaign{
+				{Endpoint: LoginEndpointWPLogin, Start: start, Windows: 1, IPs: 5},
+				{Endpoint: LoginEndpointWooCommerce, Start: start.Add(window), Windows: 1, IPs: 5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadTestConfig(t)
+			cfg.WordPress.DistributedWindowMinutes = int(window / time.Minute)
+			cfg.WordPress.DistributedMinIPs = 3
+			cfg.WordPress.DistributedMaxAttemptsPerIP = 5
+			cfg.WordPress.DistributedMinFailureRate = 90
+			a := NewAnalyzer(cfg)
+			tt.run(a)
+
+			got := a.generateDistributedLogin().Campaigns
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d campaigns, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, want := range tt.want {
+				c := got[i]
+				if c.Endpoint != want.Endpoint || !c.Start.Equal(want.Start) || c.Windows != want.Windows || c.IPs != want.IPs {
+					t.Errorf("campaign %d = {%s %v windows=%d ips=%d}, want {%s %v windows=%d ips=%d}",
+						i, c.Endpoint, c.Start, c.Windows, c.IPs, want.Endpoint, want.Start, want.Windows, want.IPs)
+				}
+			}
+		})
+	}
+}
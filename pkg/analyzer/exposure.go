@@ -0,0 +1,152 @@
+package analyzer
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/rules"
+)
+
+// maxExposedPaths caps the sensitive paths tracked individually; further
+// paths are folded into otherExposedPath.
+const maxExposedPaths = 1000
+
+// maxLeakIPs caps the IPs kept per leaked file.
+const maxLeakIPs = 10
+
+const otherExposedPath = "(その他)"
+
+// ExposureAnalysis は .env・.git/・wp-config.php.bak・*.sql・debug.log・バックアップ
+// アーカイブ等、機密ファイル（exposure カテゴリのルール）へのリクエストの集計。
+type ExposureAnalysis struct {
+	Requests  int
+	Paths     int // リクエストされた機密ファイルのパス数
+	UniqueIPs UniqueEstimate
+	TopIPs    []IPCount
+	MinBytes  int64         // 漏洩とみなす応答サイズの下限
+	Leaks     []ExposedFile // 2xx かつ MinBytes 以上の応答があったもの（実際に漏洩した可能性）
+	Rewritten int           // 2xx だが別の PHP スクリプト（WordPress 等）が応答したもの（ファイル本体ではない）
+	Files     []ExposedFile // リクエスト数順 上位20
+}
+
+type ExposedFile struct {
+	Path      string
+	RuleID    string
+	Severity  rules.Severity
+	Requests  int
+	Leaks     int      // 2xx かつ MinBytes 以上の応答
+	MaxBytes  int64    // 漏洩した応答の最大サイズ
+	LeakIPs   []string // 漏洩した応答を受け取ったIP
+	FirstSeen time.Time
+	LastSeen  time.Time
+	FirstLeak time.Time
+}
+
+type exposureAccumulator struct {
+	requests int
+	leaks    int
+	maxBytes int64
+	ruleID   string
+	leakIPs  map[string]int
+	seen     seenRange
+	leakSeen seenRange
+}
+
+// trackExposure counts a request that an exposure rule matched. A 2xx of at
+// least security.exposure_min_bytes means the file was served, unless Nginx
+// handed the request to a different PHP script (WordPress answering a soft
+// 404, say), in which case the body is not the file.
+func (a *Analyzer) trackExposure(entry *parser.LogEntry, rule *rules.Rule) {
+	a.exposureIPs.Add(entry.ClientIP, 1)
+	a.exposureUniqueIPs.Add(entry.ClientIP)
+
+	path := pagePath(entry.URI)
+	acc := a.exposures[path]
+	if acc == nil {
+		if len(a.exposures) >= maxExposedPaths {
+			path = otherExposedPath
+			acc = a.exposures[path]
+		}
+		if acc == nil {
+			acc = &exposureAccumulator{ruleID: rule.ID, leakIPs: make(map[string]int)}
+			a.exposures[path] = acc
+		}
+	}
+	acc.requests++
+	acc.seen.add(entry.Timestamp)
+
+	if entry.StatusCode < 200 || entry.StatusCode >= 300 {
+		return
+	}
+	if servedByOtherScript(entry) {
+		a.exposureRewritten++
+		return
+	}
+	if entry.ResponseSize <= 0 || entry.ResponseSize < a.config.Security.ExposureMinBytes {
+		return
+	}
+	acc.leaks++
+	acc.maxBytes = max(acc.maxBytes, entry.ResponseSize)
+	acc.leakSeen.add(entry.Timestamp)
+	if acc.leakIPs[entry.ClientIP] > 0 || len(acc.leakIPs) < maxLeakIPs {
+		acc.leakIPs[entry.ClientIP]++
+	}
+}
+
+// servedByOtherScript reports whether the request was rewritten to a PHP
+// script other than the requested path.
+func servedByOtherScript(entry *parser.LogEntry) bool {
+	if entry.UpstreamURI == "" || entry.UpstreamURI == "-" {
+		return false
+	}
+	script := pagePath(entry.UpstreamURI)
+	return script != pagePath(entry.URI) && strings.HasSuffix(strings.ToLower(script), ".php")
+}
+
+func (a *Analyzer) generateExposureAnalysis() ExposureAnalysis {
+	result := ExposureAnalysis{
+		Paths:     len(a.exposures),
+		UniqueIPs: estimate(a.exposureUniqueIPs),
+		TopIPs:    topIPCounts(a.exposureIPs, 10),
+		MinBytes:  a.config.Security.ExposureMinBytes,
+		Rewritten: a.exposureRewritten,
+	}
+	var files []ExposedFile
+	for _, path := range sortedKeys(a.exposures) {
+		acc := a.exposures[path]
+		result.Requests += acc.requests
+		f := ExposedFile{
+			Path:      path,
+			RuleID:    acc.ruleID,
+			Requests:  acc.requests,
+			Leaks:     acc.leaks,
+			MaxBytes:  acc.maxBytes,
+			LeakIPs:   sortedKeys(acc.leakIPs),
+			FirstSeen: acc.seen.First,
+			LastSeen:  acc.seen.Last,
+			FirstLeak: acc.leakSeen.First,
+		}
+		if r, ok := a.config.Rules().Rule(acc.ruleID); ok {
+			f.Severity = r.Severity
+		}
+		files = append(files, f)
+		if f.Leaks > 0 {
+			result.Leaks = append(result.Leaks, f)
+		}
+	}
+
+	sort.SliceStable(result.Leaks, func(i, j int) bool {
+		if ri, rj := result.Leaks[i].Severity.Rank(), result.Leaks[j].Severity.Rank(); ri != rj {
+			return ri > rj
+		}
+		return result.Leaks[i].MaxBytes > result.Leaks[j].MaxBytes
+	})
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Requests > files[j].Requests })
+	if len(files) > 20 {
+		files = files[:20]
+	}
+	result.Files = files
+	return result
+}
@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"testing"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+func TestServedByOtherScript(t *testing.T) {
+	tests := []struct {
+		uri      string
+		upstream string
+		want     bool
+	}{
+		{"/.env", "", false},
+		{"/.env", "-", false},
+		{"/.env", "/.env", false},
+		{"/.env", "/index.php", true},
+		{"/.env?x=1", "/index.php?q=/.env", true},
+		{"/wp-config.php.bak", "/INDEX.PHP", true},
+		{"/wp-config.php.bak", "/wp-config.php.bak", false},
+		{"/backup.sql", "/backup.sql?download=1", false},
+		{"/debug.log", "/wp-content/debug.log", false},
+		{"/old.php", "/old.php?x=1", false},
+	}
+	for _, tt := range tests {
+		entry := &parser.LogEntry{URI: tt.uri, UpstreamURI: tt.upstream}
+		if got := servedByOtherScript(entry); got != tt.want {
+			t.Errorf("servedByOtherScript(%q -> %q) = %v, want %v", tt.uri, tt.upstream, got, tt.want)
+		}
+	}
+}
+
+func TestExposureLeaks(t *testing.T) {
+	const minBytes = 50
+	tests := []struct {
+		name          string
+		status        int
+		size          int64
+		upstream      string
+		wantLeak      bool
+		wantRewritten int
+	}{
+		{"served file", 200, 1200, "", true, 0},
+		{"exactly the minimum", 200, minBytes, "", true, 0},
+		{"below the minimum", 200, minBytes - 1, "", false, 0},
+		{"empty body", 200, 0, "", false, 0},
+		{"unknown size", 200, -1, "", false, 0},
+		{"partial content", 206, 4096, "", true, 0},
+		{"not found", 404, 4096, "", false, 0},
+		{"forbidden", 403, 4096, "", false, 0},
+		{"redirect", 301, 4096, "", false, 0},
+		{"soft 404 from WordPress", 200, 30000, "/index.php", false, 1},
+		{"served by the requested path", 200, 1200, "/.env", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadTestConfig(t)
+			cfg.Security.ExposureMinBytes = minBytes
+			a := NewAnalyzer(cfg)
+			a.processEntry(&parser.LogEntry{
+				Timestamp:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+				ClientIP:     "192.0.2.1",
+				Method:       "GET",
+				URI:          "/.env",
+				Protocol:     "HTTP/1.1",
+				StatusCode:   tt.status,
+				ResponseSize: tt.size,
+				UpstreamURI:  tt.upstream,
+				UserAgent:    "curl/8.0",
+			})
+
+			got := a.generateExposureAnalysis()
+			if got.Requests != 1 {
+				t.Fatalf("Requests = %d, want 1 (exposure rule did not match)", got.Requests)
+			}
+			if leaked := len(got.Leaks) > 0; leaked != tt.wantLeak {
+				t.Errorf("leaked = %v, want %v (Leaks %+v)", leaked, tt.wantLeak, got.Leaks)
+			}
+			if got.Rewritten != tt.wantRewritten {
+				t.Errorf("Rewritten = %d, want %d", got.Rewritten, tt.wantRewritten)
+			}
+			if tt.wantLeak && (got.Leaks[0].MaxBytes != tt.size || len(got.Leaks[0].LeakIPs) != 1) {
+				t.Errorf("leak = %+v, want MaxBytes %d from one IP", got.Leaks[0], tt.size)
+			}
+		})
+	}
+}
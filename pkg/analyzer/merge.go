@@ -0,0 +1,500 @@
+package analyzer
+
+import (
+	"cmp"
+	"sort"
+	"strings"
+	"time"
+
+	"kinsta-log-analyzer/pkg/sketch"
+)
+
+// Merge folds other's state into a, so that a's Result covers the entries of
+// both. Counters and distributions are added, Top-N counters and unique
+// sketches are merged with their usual error bounds, and the response-time
+// samples keep the entries with the smallest hash, as a single pass would.
+//
+// Sessions and redirect chains still open in both analyzers for the same
+// client are joined when they are close enough in time to be one; otherwise
+// the earlier one is closed. Both analyzers must use the same configuration,
+// and other must not be used afterwards.
+func (a *Analyzer) Merge(other *Analyzer) error {
+	if other.totalRequests == 0 {
+		return nil
+	}
+	if a.totalRequests == 0 || other.startTime.Before(a.startTime) {
+		a.startTime = other.startTime
+	}
+	if a.totalRequests == 0 || other.endTime.After(a.endTime) {
+		a.endTime = other.endTime
+	}
+
+	a.responseTimeSample.merge(other.responseTimeSample)
+	a.position = max(a.position, other.position)
+	a.totalRequests += other.totalRequests
+	a.errorRequests += other.errorRequests
+	a.responseTimeMs += other.responseTimeMs
+	if other.responseTimeMax > a.responseTimeMax {
+		a.responseTimeMax = other.responseTimeMax
+	}
+	a.responseTimeCount += other.responseTimeCount
+	a.slowRequestCount += other.slowRequestCount
+
+	for i := range a.hourlyPattern {
+		a.hourlyPattern[i] += other.hourlyPattern[i]
+		a.hourlyClientErrors[i] += other.hourlyClientErrors[i]
+		a.hourlyServerErrors[i] += other.hourlyServerErrors[i]
+	}
+	addCounts(a.statusCodes, other.statusCodes)
+	addCounts(a.crawlers, other.crawlers)
+	addCounts(a.attackTools, other.attackTools)
+	for id, o := range other.ruleHits {
+		mine := a.ruleHits[id]
+		if mine == nil {
+			a.ruleHits[id] = o
+			continue
+		}
+		mine.hits += o.hits
+		if err := mine.ips.Merge(o.ips); err != nil {
+			return err
+		}
+		if mine.topIPs == nil {
+			mine.topIPs = o.topIPs
+		} else if o.topIPs != nil {
+			mine.topIPs.Merge(o.topIPs)
+		}
+		mine.seen.merge(o.seen)
+		if o.example != "" {
+			mine.offerExample(o.exampleSeen, o.example, o.field)
+		}
+	}
+	for category, o := range other.categoryHits {
+		mine := a.categoryHits[category]
+		if mine == nil {
+			a.categoryHits[category] = o
+			continue
+		}
+		mine.requests += o.requests
+		mine.ips.Merge(o.ips)
+		if err := mine.uniqueIPs.Merge(o.uniqueIPs); err != nil {
+			return err
+		}
+		mine.seen.merge(o.seen)
+	}
+	for ip, o := range other.attacksByIP {
+		if mine := a.attacksByIP[ip]; mine != nil {
+			mine.SQLAttempts += o.SQLAttempts
+			mine.XSSAttempts += o.XSSAttempts
+			mine.TraversalAttempts += o.TraversalAttempts
+			mine.LFIAttempts += o.LFIAttempts
+			mine.RFIAttempts += o.RFIAttempts
+			mine.TotalRequests += o.TotalRequests
+			mine.ErrorCount += o.ErrorCount
+		} else {
+			a.attacksByIP[ip] = o
+		}
+	}
+	for ip, ts := range other.errorTimestampsByIP {
+		merged := append(a.errorTimestampsByIP[ip], ts...)
+		sort.Slice(merged, func(i, j int) bool { return merged[i].Before(merged[j]) })
+		if len(merged) > maxErrorTimestampsPerIP {
+			merged = merged[:maxErrorTimestampsPerIP]
+		}
+		a.errorTimestampsByIP[ip] = merged
+	}
+
+	for _, pair := range [][2]*sketch.TopK{
+		{a.ipCounts, other.ipCounts},
+		{a.errorURLs, other.errorURLs},
+		{a.userAgents, other.userAgents},
+		{a.errorsByUA, other.errorsByUA},
+		{a.slowURLs, other.slowURLs},
+		{a.refererHosts, other.refererHosts},
+		{a.spamReferers, other.spamReferers},
+		{a.hotlinkHostRequests, other.hotlinkHostRequests},
+		{a.hotlinkHostBytes, other.hotlinkHostBytes},
+		{a.http10Clients, other.http10Clients},
+		{a.postURLs, other.postURLs},
+		{a.redirectURLs, other.redirectURLs},
+		{a.redirectMappings, other.redirectMappings},
+		{a.redirectChainPaths, other.redirectChainPaths},
+		{a.redirectLoops, other.redirectLoops},
+		{a.upstreamRewrites, other.upstreamRewrites},
+		{a.xmlrpcIPs, other.xmlrpcIPs},
+		{a.userEnumIPs, other.userEnumIPs},
+		{a.probeIPs, other.probeIPs},
+		{a.exposureIPs, other.exposureIPs},
+	} {
+		pair[0].Merge(pair[1])
+	}
+	for code, t := range other.urlsByStatus {
+		if mine := a.urlsByStatus[code]; mine != nil {
+			mine.Merge(t)
+		} else {
+			a.urlsByStatus[code] = t
+		}
+	}
+
+	for k, o := range other.minuteBuckets {
+		b := a.minuteBuckets[k]
+		if b == nil {
+			a.minuteBuckets[k] = o
+			continue
+		}
+		b.requests += o.requests
+		b.errors += o.errors
+		b.responseTimeMs += o.responseTimeMs
+		mergeKeyCounts(b.urls, o.urls)
+		mergeKeyCounts(b.ips, o.ips)
+	}
+
+	a.mergeSessions(other)
+
+	if err := a.uniques.merge(other.uniques); err != nil {
+		return err
+	}
+	for _, pair := range [][2]map[int64]*uniqueSketches{
+		{a.hourlyUniques, other.hourlyUniques},
+		{a.dailyUniques, other.dailyUniques},
+	} {
+		for k, o := range pair[1] {
+			if mine := pair[0][k]; mine != nil {
+				if err := mine.merge(o); err != nil {
+					return err
+				}
+			} else {
+				pair[0][k] = o
+			}
+		}
+	}
+
+	a.noReferer += other.noReferer
+	a.internalReferers += other.internalReferers
+	a.externalReferers += other.externalReferers
+	addCounts(a.searchEngines, other.searchEngines)
+	a.hotlinkRequests += other.hotlinkRequests
+	a.hotlinkBytes += other.hotlinkBytes
+
+	for method, byStatus := range other.methodStatus {
+		if a.methodStatus[method] == nil {
+			a.methodStatus[method] = make(map[int]int)
+		}
+		addCounts(a.methodStatus[method], byStatus)
+	}
+	addCounts(a.protocols, other.protocols)
+	addCounts(a.unusualMethods, other.unusualMethods)
+
+	addCounts(a.redirectCodes, other.redirectCodes)
+	a.mergeRedirectChains(other)
+
+	a.upstreamPHP.merge(other.upstreamPHP)
+	a.upstreamStatic.merge(other.upstreamStatic)
+	a.upstreamUnknown += other.upstreamUnknown
+	for _, script := range sortedKeys(other.upstreamScripts) {
+		o := other.upstreamScripts[script]
+		if _, ok := a.upstreamScripts[script]; !ok && len(a.upstreamScripts) >= maxUpstreamScripts {
+			script = otherUpstream
+		}
+		if mine := a.upstreamScripts[script]; mine != nil {
+			mine.merge(*o)
+		} else {
+			a.upstreamScripts[script] = o
+		}
+	}
+
+	if err := a.mergeWordPress(other); err != nil {
+		return err
+	}
+	a.mergeRateClients(other)
+	if err := a.mergeExposures(other); err != nil {
+		return err
+	}
+
+	for class, o := range other.classStats {
+		mine := a.classStats[class]
+		if mine == nil {
+			a.classStats[class] = o
+			continue
+		}
+		mine.responseTimeSample.merge(o.responseTimeSample)
+		mine.requests += o.requests
+		mine.clientErrors += o.clientErrors
+		mine.serverErrors += o.serverErrors
+		mine.slowRequests += o.slowRequests
+		mine.responseTimeMs += o.responseTimeMs
+		if err := mine.ips.Merge(o.ips); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeSessions combines the closed-session aggregates and reconciles
+// sessions open on both sides for the same client.
+func (a *Analyzer) mergeSessions(other *Analyzer) {
+	a.sessionCount += other.sessionCount
+	a.sessionPages += other.sessionPages
+	a.sessionBounces += other.sessionBounces
+	a.sessionDurationSum += other.sessionDurationSum
+	addCounts(a.sessionEntryPages, other.sessionEntryPages)
+	addCounts(a.sessionExitPages, other.sessionExitPages)
+	addCounts(a.sessionPaths, other.sessionPaths)
+
+	timeout := a.sessionTimeout()
+	for _, key := range sortedKeys(other.openSessions) {
+		o := other.openSessions[key]
+		mine := a.openSessions[key]
+		if mine == nil {
+			a.openSessions[key] = o
+			continue
+		}
+		first, second := mine, o
+		if second.start.Before(first.start) {
+			first, second = second, first
+		}
+		if second.start.Sub(first.lastSeen) > timeout {
+			a.closeSession(first)
+			a.openSessions[key] = second
+			continue
+		}
+		first.pages += second.pages
+		first.exitPage = second.exitPage
+		if second.lastSeen.After(first.lastSeen) {
+			first.lastSeen = second.lastSeen
+		}
+		for _, p := range second.path {
+			if len(first.path) >= a.config.Sessions.PathDepth {
+				break
+			}
+			first.path = append(first.path, p)
+		}
+		a.openSessions[key] = first
+	}
+}
+
+// mergeRedirectChains reconciles chains open on both sides for the same
+// client. They are joined when the later chain's last request is within the
+// chain window of the earlier one's (a conservative test, since only the last
+// request time is kept); otherwise the earlier chain is closed.
+func (a *Analyzer) mergeRedirectChains(other *Analyzer) {
+	window := time.Duration(a.config.Redirects.ChainWindowSeconds) * time.Second
+	for _, key := range sortedKeys(other.redirectChains) {
+		o := other.redirectChains[key]
+		mine := a.redirectChains[key]
+		if mine == nil {
+			a.redirectChains[key] = o
+			continue
+		}
+		first, second := mine, o
+		if second.lastSeen.Before(first.lastSeen) {
+			first, second = second, first
+		}
+		if second.lastSeen.Sub(first.lastSeen) > window {
+			a.closeRedirectChain(first, "")
+			a.redirectChains[key] = second
+			continue
+		}
+		for _, u := range second.urls {
+			for _, seen := range first.urls {
+				if seen == u {
+					first.loop = true
+				}
+			}
+			first.urls = append(first.urls, u)
+		}
+		first.lastSeen = second.lastSeen
+		if maxLen := a.config.Redirects.MaxChainLength; first.loop || (maxLen > 0 && len(first.urls) > maxLen) {
+			first.loop = true
+			a.closeRedirectChain(first, "")
+			delete(a.redirectChains, key)
+			continue
+		}
+		a.redirectChains[key] = first
+	}
+}
+
+func (u *uniqueSketches) merge(other *uniqueSketches) error {
+	if err := u.ips.Merge(other.ips); err != nil {
+		return err
+	}
+	if err := u.visitors.Merge(other.visitors); err != nil {
+		return err
+	}
+	return u.urls.Merge(other.urls)
+}
+
+func (u *upstreamAccumulator) merge(other upstreamAccumulator) {
+	u.requests += other.requests
+	u.errors += other.errors
+	u.responseTimeMs += other.responseTimeMs
+	if other.responseTimeMax > u.responseTimeMax {
+		u.responseTimeMax = other.responseTimeMax
+	}
+}
+
+func addCounts[K comparable](dst, src map[K]int) {
+	for k, v := range src {
+		dst[k] += v
+	}
+}
+
+// addCappedCounts adds src into dst without letting dst grow beyond limit keys.
+// Keys are visited in order so the ones kept do not depend on map iteration.
+func addCappedCounts(dst, src map[string]int, limit int) {
+	for _, k := range sortedKeys(src) {
+		if _, ok := dst[k]; ok || len(dst) < limit {
+			dst[k] += src[k]
+		}
+	}
+}
+
+// sortedKeys returns the keys of m in ascending order, for iterations whose
+// outcome would otherwise depend on Go's randomised map order.
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func (a *Analyzer) mergeWordPress(other *Analyzer) error {
+	for ip, o := range other.loginByIP {
+		if mine := a.loginByIP[ip]; mine != nil {
+			mine.merge(o)
+		} else {
+			a.loginByIP[ip] = o
+		}
+	}
+	if limit := a.config.HeavyHitters.Capacity; limit > 0 {
+		a.loginDropped += pruneLoginIPs(a.loginByIP, limit)
+	}
+	a.loginDropped += other.loginDropped
+	a.loginTotals.merge(&other.loginTotals)
+	if err := a.loginUniqueIPs.Merge(other.loginUniqueIPs); err != nil {
+		return err
+	}
+	a.xmlrpcRequests += other.xmlrpcRequests
+	a.xmlrpcPosts += other.xmlrpcPosts
+	a.xmlrpcLargePosts += other.xmlrpcLargePosts
+	a.xmlrpcBlocked += other.xmlrpcBlocked
+	if err := a.xmlrpcUniqueIPs.Merge(other.xmlrpcUniqueIPs); err != nil {
+		return err
+	}
+	a.authorRequests += other.authorRequests
+	a.authorRedirects += other.authorRedirects
+	for _, id := range sortedKeys(other.authorIDs) {
+		if _, ok := a.authorIDs[id]; ok || len(a.authorIDs) < maxAuthorIDs {
+			a.authorIDs[id] += other.authorIDs[id]
+		}
+	}
+	a.restUserRequests += other.restUserRequests
+	a.restUserExposed += other.restUserExposed
+	a.probeRequests += other.probeRequests
+	if err := a.probeUniqueIPs.Merge(other.probeUniqueIPs); err != nil {
+		return err
+	}
+	for _, key := range sortedKeys(other.probedComponents) {
+		o := other.probedComponents[key]
+		if _, ok := a.probedComponents[key]; !ok && len(a.probedComponents) >= maxProbedComponents {
+			kind, _, _ := strings.Cut(key, "\x00")
+			key = kind + "\x00" + otherComponent
+		}
+		if mine := a.probedComponents[key]; mine != nil {
+			mine.requests += o.requests
+			mine.found += o.found
+			addCappedCounts(mine.foundPaths, o.foundPaths, maxFoundPaths)
+		} else {
+			a.probedComponents[key] = o
+		}
+	}
+	for _, key := range sortedKeys(other.componentVersions) {
+		o := other.componentVersions[key]
+		if mine := a.componentVersions[key]; mine != nil {
+			addCappedCounts(mine, o, maxComponentVersions)
+		} else if len(a.componentVersions) < maxProbedComponents {
+			a.componentVersions[key] = o
+		}
+	}
+	a.distributedAttempts += other.distributedAttempts
+	for key, o := range other.loginWindows {
+		mine := a.loginWindows[key]
+		if mine == nil {
+			a.loginWindows[key] = o
+			continue
+		}
+		for _, client := range sortedKeys(o.clients) {
+			c := o.clients[client]
+			if _, ok := mine.clients[client]; !ok && len(mine.clients) >= maxWindowClients {
+				client = otherLoginClient
+			}
+			if m := mine.clients[client]; m != nil {
+				m.attempts += c.attempts
+				m.successes += c.successes
+			} else {
+				mine.clients[client] = c
+			}
+		}
+	}
+	return nil
+}
+
+func (l *loginAccumulator) merge(other *loginAccumulator) {
+	l.attempts += other.attempts
+	l.successes += other.successes
+	l.failures += other.failures
+	l.blocked += other.blocked
+	l.seen.merge(other.seen)
+}
+
+func (a *Analyzer) mergeRateClients(other *Analyzer) {
+	for class, clients := range other.rateClients {
+		mine := a.rateClients[class]
+		if mine == nil {
+			a.rateClients[class] = clients
+			continue
+		}
+		for ip, o := range clients {
+			t := mine[ip]
+			if t == nil {
+				mine[ip] = o
+				continue
+			}
+			t.seconds = append(t.seconds, o.seconds...)
+			t.requests += o.requests
+			t.normalize()
+		}
+	}
+	addCounts(a.rateDropped, other.rateDropped)
+	if limit := a.config.HeavyHitters.Capacity; limit > 0 {
+		for class, clients := range a.rateClients {
+			a.rateDropped[class] += pruneRateClients(clients, limit)
+		}
+	}
+	for ip := range other.rateAbusiveIPs {
+		a.rateAbusiveIPs[ip] = true
+	}
+}
+
+func (a *Analyzer) mergeExposures(other *Analyzer) error {
+	for _, path := range sortedKeys(other.exposures) {
+		o := other.exposures[path]
+		if _, ok := a.exposures[path]; !ok && len(a.exposures) >= maxExposedPaths {
+			path = otherExposedPath
+		}
+		mine := a.exposures[path]
+		if mine == nil {
+			a.exposures[path] = o
+			continue
+		}
+		mine.requests += o.requests
+		mine.leaks += o.leaks
+		mine.maxBytes = max(mine.maxBytes, o.maxBytes)
+		addCappedCounts(mine.leakIPs, o.leakIPs, maxLeakIPs)
+		mine.seen.merge(o.seen)
+		mine.leakSeen.merge(o.leakSeen)
+	}
+	a.exposureRewritten += other.exposureRewritten
+	return a.exposureUniqueIPs.Merge(other.exposureUniqueIPs)
+}
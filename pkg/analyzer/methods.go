@@ -0,0 +1,97 @@
+package analyzer
+
+import (
+	"sort"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+type MethodAnalysis struct {
+	Methods        map[string]int
+	MethodStatus   map[string]map[int]int // method -> status code -> count
+	Protocols      map[string]int         // 例: "HTTP/1.1", "HTTP/2.0"
+	UnusualMethods []UnusualMethodIP
+	HTTP10Clients  []IPCount // HTTP/1.0 を使ったIP（古いボット・スクリプトの兆候）
+	TopPostURLs    []URLError
+}
+
+// UnusualMethodIP は unusual_methods に該当するメソッドを送ったIP。
+type UnusualMethodIP struct {
+	IP     string
+	Method string
+	Count  int
+}
+
+type methodIP struct {
+	method string
+	ip     string
+}
+
+func (a *Analyzer) trackMethod(entry *parser.LogEntry) {
+	method := entry.Method
+	if method == "" {
+		method = "(不明)"
+	}
+	if a.methodStatus[method] == nil {
+		a.methodStatus[method] = make(map[int]int)
+	}
+	a.methodStatus[method][entry.StatusCode]++
+
+	protocol := entry.Protocol
+	if protocol == "" {
+		protocol = "(不明)"
+	}
+	a.protocols[protocol]++
+
+	if entry.Protocol == "HTTP/1.0" {
+		a.http10Clients.Add(entry.ClientIP, 1)
+	}
+	if entry.Method == "POST" {
+		a.postURLs.Add(pagePath(entry.URI), 1)
+	}
+	if entry.Method != "" && a.config.IsUnusualMethod(entry.Method) {
+		a.unusualMethods[methodIP{method: entry.Method, ip: entry.ClientIP}]++
+	}
+}
+
+// generateMethodAnalysis returns method/protocol distributions plus Top 10
+// HTTP/1.0 clients, POST targets and unusual-method senders.
+func (a *Analyzer) generateMethodAnalysis() MethodAnalysis {
+	methods := make(map[string]int)
+	for method, byStatus := range a.methodStatus {
+		for _, c := range byStatus {
+			methods[method] += c
+		}
+	}
+
+	var unusual []UnusualMethodIP
+	for key, c := range a.unusualMethods {
+		unusual = append(unusual, UnusualMethodIP{IP: key.ip, Method: key.method, Count: c})
+	}
+	sort.Slice(unusual, func(i, j int) bool {
+		if unusual[i].Count != unusual[j].Count {
+			return unusual[i].Count > unusual[j].Count
+		}
+		if unusual[i].Method != unusual[j].Method {
+			return unusual[i].Method < unusual[j].Method
+		}
+		return unusual[i].IP < unusual[j].IP
+	})
+	if len(unusual) > 10 {
+		unusual = unusual[:10]
+	}
+
+	var http10 []IPCount
+	for _, item := range a.http10Clients.Top(10) {
+		http10 = append(http10, IPCount{IP: item.Key, Count: item.Count, ErrorBound: item.Error})
+	}
+
+	return MethodAnalysis{
+		Methods:        methods,
+		MethodStatus:   a.methodStatus,
+		Protocols:      a.protocols,
+		UnusualMethods: unusual,
+		HTTP10Clients:  http10,
+		TopPostURLs:    urlErrorsFromTopK(a.postURLs, 10),
+	}
+}
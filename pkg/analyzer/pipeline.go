@@ -0,0 +1,194 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+// pipelineBatchSize is the number of lines handed to a parser worker at once;
+// large enough to amortise channel overhead, small enough to keep every
+// worker busy on modest files.
+const pipelineBatchSize = 1024
+
+// lineBatch holds consecutive lines copied into one buffer; line i is
+// data[ends[i-1]:ends[i]].
+type lineBatch struct {
+	seq  int
+	data []byte
+	ends []int
+}
+
+type entryBatch struct {
+	seq     int
+	entries []*parser.LogEntry
+}
+
+// shardBatch holds the entries routed to one shard with their input
+// positions.
+type shardBatch struct {
+	entries   []*parser.LogEntry
+	positions []int64
+}
+
+// SetWorkers sets the number of parser workers and aggregator shards used by
+// ProcessFile. n <= 1 processes the file sequentially.
+func (a *Analyzer) SetWorkers(n int) {
+	a.workers = n
+}
+
+// processParallel reads r as a pipeline: one reader batches lines, a.workers
+// parsers turn them into entries, and a dispatcher restores file order and
+// routes each entry by client IP to one of a.workers shard analyzers. The
+// shards are merged into a in a fixed order once the input is exhausted.
+//
+// Because an IP always lands on the same shard and every shard sees its
+// entries in file order, per-client state (sessions, redirect chains, error
+// bursts) is built exactly as in a sequential run. The other state merges
+// into what a sequential run builds too: latency sums are integers, the
+// rule example is the earliest one, the response-time samples keep the
+// entries with the smallest hash, and the capped per-minute URL/IP tables
+// keep the keys with the lowest input position, which the dispatcher hands
+// to the shards along with the entries.
+//
+// The exception is the other tables that hold a fixed number of keys: the
+// heavy-hitter Top-N counters, the rate-limit clients per class and the
+// login IPs (all heavy_hitters.capacity), and the tables that keep the first keys they see
+// (upstream scripts, sensitive paths, probed components with their found
+// paths and versions, leak IPs, author IDs and the clients of a login
+// window). Once one of them overflows, which keys it keeps can differ from a
+// sequential run, and with it the counts near the bottom of the tables.
+func (a *Analyzer) processParallel(r io.Reader) error {
+	n := a.workers
+
+	batches := make(chan lineBatch, n*2)
+	var readErr error
+	go func() {
+		defer close(batches)
+		scanner := bufio.NewScanner(r)
+		b := lineBatch{ends: make([]int, 0, pipelineBatchSize)}
+		for scanner.Scan() {
+			b.data = append(b.data, scanner.Bytes()...)
+			b.ends = append(b.ends, len(b.data))
+			if len(b.ends) == pipelineBatchSize {
+				batches <- b
+				b = lineBatch{seq: b.seq + 1, data: make([]byte, 0, len(b.data)), ends: make([]int, 0, pipelineBatchSize)}
+			}
+		}
+		if len(b.ends) > 0 {
+			batches <- b
+		}
+		readErr = scanner.Err()
+	}()
+
+	parsed := make(chan entryBatch, n*2)
+	var parsers sync.WaitGroup
+	for i := 0; i < n; i++ {
+		parsers.Add(1)
+		go func() {
+			defer parsers.Done()
+			for b := range batches {
+				// Entries of one batch share a backing array, and each one
+				// reuses the strings of the entry parsed before it.
+				backing := make([]parser.LogEntry, len(b.ends))
+				entries := make([]*parser.LogEntry, 0, len(b.ends))
+				start := 0
+				for i, end := range b.ends {
+					entry := &backing[i]
+					if i > 0 {
+						*entry = backing[i-1]
+					}
+					line := b.data[start:end]
+					start = end
+					if err := parser.ParseInto(entry, line); err != nil {
+						// Skip invalid lines but continue processing
+						continue
+					}
+					if a.inWindow(entry.Timestamp) {
+						entries = append(entries, entry)
+					}
+				}
+				parsed <- entryBatch{seq: b.seq, entries: entries}
+			}
+		}()
+	}
+	go func() {
+		parsers.Wait()
+		close(parsed)
+	}()
+
+	shards := make([]*Analyzer, n)
+	inputs := make([]chan shardBatch, n)
+	var aggregators sync.WaitGroup
+	for i := range shards {
+		shards[i] = NewAnalyzer(a.config)
+		inputs[i] = make(chan shardBatch, n*2)
+		aggregators.Add(1)
+		go func(shard *Analyzer, in <-chan shardBatch) {
+			defer aggregators.Done()
+			for b := range in {
+				for i, entry := range b.entries {
+					shard.position = b.positions[i]
+					shard.processEntry(entry)
+				}
+			}
+		}(shards[i], inputs[i])
+	}
+
+	// Parsers finish out of order; hold batches back until their
+	// predecessors have been dispatched.
+	pending := make(map[int][]*parser.LogEntry)
+	next := 0
+	position := a.position
+	for b := range parsed {
+		pending[b.seq] = b.entries
+		for {
+			entries, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			routed := make([]shardBatch, n)
+			for _, entry := range entries {
+				position++
+				i := shardOf(entry.ClientIP, n)
+				routed[i].entries = append(routed[i].entries, entry)
+				routed[i].positions = append(routed[i].positions, position)
+			}
+			for i, rs := range routed {
+				if len(rs.entries) > 0 {
+					inputs[i] <- rs
+				}
+			}
+		}
+	}
+	for _, in := range inputs {
+		close(in)
+	}
+	aggregators.Wait()
+
+	if readErr != nil {
+		return fmt.Errorf("error reading file: %v", readErr)
+	}
+	for _, shard := range shards {
+		if err := a.Merge(shard); err != nil {
+			return err
+		}
+	}
+	a.position = position
+	return nil
+}
+
+// shardOf maps ip to one of n shards with FNV-1a.
+func shardOf(ip string, n int) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(ip); i++ {
+		h ^= uint32(ip[i])
+		h *= 16777619
+	}
+	return int(h % uint32(n))
+}
@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParallelMatchesSequential(t *testing.T) {
+	cfg := loadTestConfig(t)
+	want := analyzeFile(t, cfg, sampleLogPath, 1)
+	for _, workers := range []int{2, 4} {
+		got := analyzeFile(t, cfg, sampleLogPath, workers)
+		assertSameResult(t, got, want)
+	}
+}
+
+// writeGeneratedLog writes a log of several pipeline batches: steady traffic
+// with varied response times, a scanner minute with more URLs and IPs than a
+// minute bucket keeps, and an IP with more errors than are timestamped,
+// partly out of time order.
+func writeGeneratedLog(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "access.log")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := bufio.NewWriter(f)
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	seed := uint32(1)
+	rand := func(n int) int {
+		seed = seed*1664525 + 1013904223
+		return int(seed>>8) % n
+	}
+	line := func(at time.Time, ip, method, uri string, status int, ua string, rt float64) {
+		fmt.Fprintf(w, "example.com %s [%s] %s \"%s\" HTTP/1.1 %d \"-\" \"%s\" %s \"%s\" - - %d %.3f %.3f\n",
+			ip, at.Format("02/Jan/2006:15:04:05 -0700"), method, uri, status, ua, ip, uri, 500+rand(5000), rt, rt)
+	}
+	browser := "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+	pages := []string{"/", "/blog/", "/shop/", "/contact/", "/wp-content/themes/astra/style.css?ver=4.6.1", "/wp-json/wp/v2/posts", "/?p=12"}
+	for i := 0; i < 6000; i++ {
+		at := start.Add(time.Duration(i/5) * time.Second)
+		if i%97 == 0 {
+			at = at.Add(-3 * time.Second) // a late write
+		}
+		ip := fmt.Sprintf("203.0.113.%d", rand(120))
+		status := 200
+		if rand(20) == 0 {
+			status = 404
+		} else if rand(50) == 0 {
+			status = 502
+		}
+		line(at, ip, "GET", pages[rand(len(pages))], status, browser, float64(rand(3000))/1000)
+		if i%4 == 0 {
+			// A client failing all the time, out of order at times.
+			line(at.Add(-time.Duration(i%7)*time.Second), "198.51.100.7", "POST", "/wp-login.php", 403, "python-requests/2.31", 0.05)
+		}
+	}
+	scan := start.Add(15 * time.Minute)
+	for i := 0; i < 300; i++ {
+		ip := fmt.Sprintf("192.0.2.%d", i%250)
+		line(scan.Add(time.Duration(i%60)*time.Second), ip, "GET", fmt.Sprintf("/.env.%d", i), 404, "curl/8.0", 0.01)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParallelMatchesSequentialAcrossBatches(t *testing.T) {
+	cfg := loadTestConfig(t)
+	cfg.Anomaly.Enabled = true
+	path := writeGeneratedLog(t)
+	want := analyzeFile(t, cfg, path, 1)
+	if want.Summary.TotalRequests < 3*pipelineBatchSize {
+		t.Fatalf("generated log has %d requests, want several batches", want.Summary.TotalRequests)
+	}
+	for _, workers := range []int{2, 3, 8} {
+		got := analyzeFile(t, cfg, path, workers)
+		assertSameResult(t, got, want)
+	}
+}
@@ -0,0 +1,424 @@
+package analyzer
+
+import (
+	"sort"
+	"strings"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/rules"
+)
+
+// ClassLogin groups login POSTs and xmlrpc.php for rate limiting only;
+// classifyRequest never returns it.
+const ClassLogin RequestClass = "login"
+
+// rateLimitClasses fixes the order of classes in rate limit
+// recommendations. Static assets are served by Nginx without PHP and are not
+// worth limiting.
+var rateLimitClasses = []RequestClass{ClassLogin, ClassAdmin, ClassREST, ClassDynamic, ClassFeed, ClassSitemap}
+
+// defaultRateLimitPercentile is used when rate_limit.percentile is not set.
+const defaultRateLimitPercentile = 99
+
+// maxRateSeconds caps the active seconds kept per client and class; later
+// seconds are counted but not replayed.
+const maxRateSeconds = 3600
+
+// The clients tracked per class are capped at heavy_hitters.capacity (0 =
+// no cap). When a class is full, the half of its clients with the fewest
+// requests so far is dropped to make room: rate limits are decided by the
+// busiest clients, and one that returns after being dropped starts a new
+// timeline. The legitimate percentiles are then taken over busier clients
+// than the log's, which only raises the candidates' lower bounds.
+
+// rateLimitCoverage is the share (%) of the most abusive requests any
+// candidate limits that a recommendation must still limit; within it the
+// most generous setting wins, leaving headroom for legitimate clients the
+// logs did not show.
+const rateLimitCoverage = 90
+
+// rateLimitHeadroom multiplies the legitimate clients' percentile peaks to
+// give the lowest rate and burst considered. Fitting a limit just above the
+// clients that happen to be in the log would throttle the next ordinary
+// visitor who reads a little faster.
+const rateLimitHeadroom = 2
+
+// rateLimitMinRates is the lowest rate (requests per minute) recommended per
+// class however quiet the log is: a person submits a login form a few times a
+// minute at most, but may open a page, admin screen or API call every second.
+var rateLimitMinRates = map[RequestClass]int{
+	ClassLogin:   10,
+	ClassAdmin:   60,
+	ClassREST:    60,
+	ClassDynamic: 60,
+	ClassFeed:    10,
+	ClassSitemap: 10,
+}
+
+// minRateLimitShare is the share (%) of abusive requests a recommendation
+// must limit; below it a per-IP limit does not address the abuse (many
+// clients each sending little) and would only risk legitimate traffic.
+const minRateLimitShare = 10
+
+// Why a class has no recommendation.
+const (
+	RateLimitNoAbuse     = "no-abuse"     // 悪用トラフィックなし
+	RateLimitNoCandidate = "no-candidate" // 下限以上のどの候補も正規クライアントを巻き込む
+	RateLimitIneffective = "ineffective"  // 制限できる悪用リクエストが minRateLimitShare 未満
+)
+
+// Candidate limit_req settings: rates in requests per minute (Nginx accepts
+// r/m and r/s) and bursts. A burst of 0 would reject the second request of
+// any page that fires two at once, so there is none.
+var (
+	rateLimitRates  = []int{1, 2, 5, 10, 20, 30, 60, 120, 300, 600, 1200, 3000, 6000}
+	rateLimitBursts = []int{5, 10, 20, 50, 100, 200}
+)
+
+// RateLimitAnalysis はエンドポイント分類ごとの Nginx limit_req の推奨値。
+// 各クライアント（IP）のリクエストを limit_req（leaky bucket、nodelay）で再生し、
+// 正規クライアントの Percentile % を制限せずに、悪用トラフィックを最も多く制限する
+// rate / burst を候補から選ぶ。候補は正規クライアントのピークに Headroom 倍の余裕を
+// 持たせた値（rate は分類ごとの下限以上）に限る。
+type RateLimitAnalysis struct {
+	Percentile float64 // 制限しない正規クライアントの割合（%）
+	MinShare   float64 // 推奨に必要な、制限できる悪用リクエストの割合（%）
+	Headroom   int     // 正規クライアントのピークに対する rate / burst の下限の倍率
+	Classes    []RateLimitRecommendation
+}
+
+// RateLimitRecommendation は分類1つの推奨値と、それを適用した場合に制限された件数。
+// 悪用クライアントは検出ルール（crawler 以外）に一致したIP、ブルートフォースIP、
+// 分散型ブルートフォースの参加IP。
+type RateLimitRecommendation struct {
+	Class           RequestClass
+	Requests        int
+	Clients         int
+	AbusiveRequests int
+	AbusiveClients  int
+	PeakLegit       int // 正規クライアントの1分あたり最大リクエスト数（Percentile 値）
+	PeakLegitSecond int // 正規クライアントの1秒あたり最大リクエスト数（Percentile 値）
+	MinRate         int // 候補とする rate の下限（回/分）
+	MinBurst        int // 候補とする burst の下限
+	RatePerMinute   int // 0 なら推奨なし（理由は Skipped）
+	Burst           int
+	Skipped         string  // RateLimitNoAbuse / RateLimitNoCandidate / RateLimitIneffective
+	AbusiveShare    float64 // 悪用リクエストのうち制限できる割合（%、推奨なしでも最良の候補の値）
+
+	LimitedRequests        int
+	LimitedClients         int
+	LimitedAbusiveRequests int
+	LimitedAbusiveClients  int
+	LimitedLegitRequests   int
+	LimitedLegitClients    int
+	LimitedLegitIPs        []IPCount // 制限される正規クライアント（制限数順 上位5、許可リスト候補）
+	Truncated              int       // maxRateSeconds を超えて一部しか評価していないクライアント数
+	Dropped                int       // 分類ごとのクライアント数の上限（heavy_hitters.capacity）により評価から外したクライアント数
+}
+
+// rateTimeline is a client's requests of one class, counted per second.
+type rateTimeline struct {
+	requests int
+	seconds  []rateSecond
+}
+
+type rateSecond struct {
+	unix  int64
+	count int
+}
+
+// rateLimitClass returns the class a request is limited under, or false for
+// static assets.
+func rateLimitClass(entry *parser.LogEntry) (RequestClass, bool) {
+	path, query, _ := strings.Cut(entry.URI, "?")
+	lower := strings.ToLower(path)
+	if strings.HasSuffix(lower, "/xmlrpc.php") || entry.Method == "POST" && loginEndpoint(lower, query) != "" {
+		return ClassLogin, true
+	}
+	class := classifyRequest(entry)
+	return class, class != ClassStatic
+}
+
+// isAbusiveRequest reports whether a detection rule other than a crawler
+// rule matched.
+func isAbusiveRequest(matches []rules.Match) bool {
+	for _, m := range matches {
+		if m.Rule.Category != rules.CategoryCrawler {
+			return true
+		}
+	}
+	return false
+}
+
+// trackRate records the request in its client's timeline for the class.
+func (a *Analyzer) trackRate(entry *parser.LogEntry, matches []rules.Match) {
+	if isAbusiveRequest(matches) {
+		a.rateAbusiveIPs[entry.ClientIP] = true
+	}
+	class, ok := rateLimitClass(entry)
+	if !ok {
+		return
+	}
+	clients := a.rateClients[class]
+	if clients == nil {
+		clients = make(map[string]*rateTimeline)
+		a.rateClients[class] = clients
+	}
+	t := clients[entry.ClientIP]
+	if t == nil {
+		if limit := a.config.HeavyHitters.Capacity; limit > 0 && len(clients) >= limit {
+			a.rateDropped[class] += pruneRateClients(clients, max(limit/2, 1))
+		}
+		t = &rateTimeline{}
+		clients[entry.ClientIP] = t
+	}
+	t.add(entry.Timestamp.Unix(), 1)
+}
+
+// pruneRateClients keeps the keep clients with the most requests, ties going
+// to the lower IP, and returns the number dropped.
+func pruneRateClients(clients map[string]*rateTimeline, keep int) int {
+	return keepBusiest(clients, keep, func(t *rateTimeline) int { return t.requests })
+}
+
+func (t *rateTimeline) add(unix int64, count int) {
+	t.requests += count
+	if n := len(t.seconds); n > 0 && t.seconds[n-1].unix == unix {
+		t.seconds[n-1].count += count
+		return
+	}
+	if len(t.seconds) < maxRateSeconds {
+		t.seconds = append(t.seconds, rateSecond{unix: unix, count: count})
+	}
+}
+
+// normalize sorts the seconds and combines duplicates; lines slightly out
+// of order and merged snapshots append out of order.
+func (t *rateTimeline) normalize() {
+	ordered := len(t.seconds) <= maxRateSeconds
+	for i := 1; ordered && i < len(t.seconds); i++ {
+		ordered = t.seconds[i-1].unix < t.seconds[i].unix
+	}
+	if ordered {
+		return
+	}
+	sort.SliceStable(t.seconds, func(i, j int) bool { return t.seconds[i].unix < t.seconds[j].unix })
+	merged := t.seconds[:0]
+	for _, s := range t.seconds {
+		if n := len(merged); n > 0 && merged[n-1].unix == s.unix {
+			merged[n-1].count += s.count
+		} else {
+			merged = append(merged, s)
+		}
+	}
+	if len(merged) > maxRateSeconds {
+		merged = merged[:maxRateSeconds]
+	}
+	t.seconds = merged
+}
+
+// truncated reports whether some requests were not kept in the timeline.
+func (t *rateTimeline) truncated() bool {
+	kept := 0
+	for _, s := range t.seconds {
+		kept += s.count
+	}
+	return kept < t.requests
+}
+
+// limited replays the timeline through Nginx's limit_req with nodelay and
+// returns the rejected requests. Like Nginx, a rejected request leaves the
+// bucket unchanged; the log's one-second resolution makes requests of the
+// same second simultaneous.
+func (t *rateTimeline) limited(perMinute, burst int) int {
+	if t.requests <= burst+1 {
+		return 0
+	}
+	rate := float64(perMinute) / 60
+	rejected := 0
+	excess := 0.0
+	var last int64
+	for i, s := range t.seconds {
+		count := s.count
+		if i == 0 {
+			last = s.unix
+			count-- // the first request creates the bucket and always passes
+		}
+		for ; count > 0; count-- {
+			e := max(excess-rate*float64(s.unix-last)+1, 0)
+			if e > float64(burst) {
+				rejected++
+				continue
+			}
+			excess, last = e, s.unix
+		}
+	}
+	return rejected
+}
+
+// peakPerSecond returns the most requests in one second.
+func (t *rateTimeline) peakPerSecond() int {
+	peak := 0
+	for _, s := range t.seconds {
+		peak = max(peak, s.count)
+	}
+	return peak
+}
+
+// peakPerMinute returns the most requests in one calendar minute.
+func (t *rateTimeline) peakPerMinute() int {
+	peak, current := 0, 0
+	var minute int64 = -1
+	for _, s := range t.seconds {
+		if m := s.unix / 60; m != minute {
+			minute, current = m, 0
+		}
+		current += s.count
+		peak = max(peak, current)
+	}
+	return peak
+}
+
+func (a *Analyzer) rateLimitPercentile() float64 {
+	if p := a.config.RateLimit.Percentile; p > 0 && p <= 100 {
+		return p
+	}
+	return defaultRateLimitPercentile
+}
+
+// abusiveIPs returns the clients treated as abusive when recommending rate
+// limits.
+func (a *Analyzer) abusiveIPs() map[string]bool {
+	result := make(map[string]bool, len(a.rateAbusiveIPs))
+	for ip := range a.rateAbusiveIPs {
+		result[ip] = true
+	}
+	threshold := a.bruteForceThreshold()
+	for ip, acc := range a.loginByIP {
+		if acc.failures >= threshold {
+			result[ip] = true
+		}
+	}
+	for _, campaign := range a.loginCampaigns() {
+		for ip := range campaign.ips {
+			result[ip] = true
+		}
+	}
+	return result
+}
+
+func (a *Analyzer) generateRateLimits() RateLimitAnalysis {
+	result := RateLimitAnalysis{Percentile: a.rateLimitPercentile(), MinShare: minRateLimitShare, Headroom: rateLimitHeadroom}
+	abusive := a.abusiveIPs()
+	for _, class := range rateLimitClasses {
+		if clients := a.rateClients[class]; len(clients) > 0 {
+			rec := recommendRateLimit(class, clients, abusive, result.Percentile)
+			rec.Dropped = a.rateDropped[class]
+			result.Classes = append(result.Classes, rec)
+		}
+	}
+	return result
+}
+
+// recommendRateLimit replays every candidate rate and burst at or above
+// rateLimitHeadroom times the legitimate clients' pct-percentile peaks (and
+// the class's minimum rate), and keeps the ones that limit at most
+// (100 - pct)% of the legitimate clients. Of those that limit at least
+// rateLimitCoverage% of the most abusive requests any candidate limits, it
+// recommends the one limiting the fewest legitimate requests, then the most
+// generous one.
+func recommendRateLimit(class RequestClass, clients map[string]*rateTimeline, abusive map[string]bool, pct float64) RateLimitRecommendation {
+	rec := RateLimitRecommendation{Class: class, Clients: len(clients)}
+	var peaks, secondPeaks []float64
+	for ip, t := range clients {
+		t.normalize()
+		rec.Requests += t.requests
+		if t.truncated() {
+			rec.Truncated++
+		}
+		if abusive[ip] {
+			rec.AbusiveRequests += t.requests
+			rec.AbusiveClients++
+		} else {
+			peaks = append(peaks, float64(t.peakPerMinute()))
+			secondPeaks = append(secondPeaks, float64(t.peakPerSecond()))
+		}
+	}
+	rec.PeakLegit = int(percentile(peaks, pct/100))
+	rec.PeakLegitSecond = int(percentile(secondPeaks, pct/100))
+	rec.MinRate = max(rateLimitMinRates[class], rateLimitHeadroom*rec.PeakLegit)
+	rec.MinBurst = max(rateLimitBursts[0], rateLimitHeadroom*rec.PeakLegitSecond)
+	if rec.AbusiveRequests == 0 {
+		rec.Skipped = RateLimitNoAbuse
+		return rec
+	}
+	allowed := int(float64(len(peaks)) * (100 - pct) / 100)
+
+	ips := sortedKeys(clients)
+	var candidates []RateLimitRecommendation
+	mostAbusive := 0
+	for _, rate := range rateLimitRates {
+		if rate < rec.MinRate {
+			continue
+		}
+		for _, burst := range rateLimitBursts {
+			if burst < rec.MinBurst {
+				continue
+			}
+			c := RateLimitRecommendation{RatePerMinute: rate, Burst: burst}
+			for _, ip := range ips {
+				n := clients[ip].limited(rate, burst)
+				if n == 0 {
+					continue
+				}
+				if abusive[ip] {
+					c.LimitedAbusiveRequests += n
+					c.LimitedAbusiveClients++
+				} else {
+					c.LimitedLegitRequests += n
+					c.LimitedLegitClients++
+				}
+			}
+			if c.LimitedLegitClients <= allowed {
+				candidates = append(candidates, c)
+				mostAbusive = max(mostAbusive, c.LimitedAbusiveRequests)
+			}
+		}
+	}
+
+	var best *RateLimitRecommendation
+	for i, c := range candidates {
+		if c.LimitedAbusiveRequests == 0 || c.LimitedAbusiveRequests*100 < mostAbusive*rateLimitCoverage {
+			continue
+		}
+		// Candidates run from strict to generous, so ties go to the later one.
+		if best == nil || c.LimitedLegitRequests <= best.LimitedLegitRequests {
+			best = &candidates[i]
+		}
+	}
+	switch {
+	case len(candidates) == 0:
+		rec.Skipped = RateLimitNoCandidate
+		return rec
+	case best == nil || mostAbusive*100 < rec.AbusiveRequests*minRateLimitShare:
+		rec.Skipped = RateLimitIneffective
+		rec.AbusiveShare = float64(mostAbusive) / float64(rec.AbusiveRequests) * 100
+		return rec
+	}
+	rec.AbusiveShare = float64(best.LimitedAbusiveRequests) / float64(rec.AbusiveRequests) * 100
+	rec.RatePerMinute, rec.Burst = best.RatePerMinute, best.Burst
+	rec.LimitedAbusiveRequests, rec.LimitedAbusiveClients = best.LimitedAbusiveRequests, best.LimitedAbusiveClients
+	rec.LimitedLegitRequests, rec.LimitedLegitClients = best.LimitedLegitRequests, best.LimitedLegitClients
+	for _, ip := range ips {
+		if n := clients[ip].limited(best.RatePerMinute, best.Burst); n > 0 && !abusive[ip] {
+			rec.LimitedLegitIPs = append(rec.LimitedLegitIPs, IPCount{IP: ip, Count: n})
+		}
+	}
+	sort.SliceStable(rec.LimitedLegitIPs, func(i, j int) bool { return rec.LimitedLegitIPs[i].Count > rec.LimitedLegitIPs[j].Count })
+	if len(rec.LimitedLegitIPs) > 5 {
+		rec.LimitedLegitIPs = rec.LimitedLegitIPs[:5]
+	}
+	rec.LimitedRequests = rec.LimitedAbusiveRequests + rec.LimitedLegitRequests
+	rec.LimitedClients = rec.LimitedAbusiveClients + rec.LimitedLegitClients
+	return rec
+}
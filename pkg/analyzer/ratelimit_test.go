@@ -0,0 +1,216 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+// timeline builds a rateTimeline from {unix, count} pairs.
+func timeline(seconds ...[2]int) *rateTimeline {
+	t := &rateTimeline{}
+	for _, s := range seconds {
+		t.add(int64(s[0]), s[1])
+	}
+	return t
+}
+
+// steady returns a timeline of perSecond requests every step seconds.
+func steady(start, n, step, perSecond int) *rateTimeline {
+	t := &rateTimeline{}
+	for i := 0; i < n; i++ {
+		t.add(int64(start+i*step), perSecond)
+	}
+	return t
+}
+
+func TestRateTimelineLimited(t *testing.T) {
+	tests := []struct {
+		name      string
+		timeline  *rateTimeline
+		perMinute int
+		burst     int
+		want      int
+	}{
+		{"within burst", timeline([2]int{0, 6}), 60, 5, 0},
+		{"burst exceeded in one second", timeline([2]int{0, 10}), 60, 5, 4},
+		{"one per second at 1r/s", steady(0, 10, 1, 1), 60, 0, 0},
+		{"two per second at 1r/s", steady(0, 5, 1, 2), 60, 0, 5},
+		{"two per second with burst", steady(0, 5, 1, 2), 60, 5, 0},
+		// A rejected request leaves the bucket as it was, so the third
+		// request, a full minute after the first, passes again.
+		{"rejected request does not drain", timeline([2]int{0, 1}, [2]int{30, 1}, [2]int{60, 1}), 1, 0, 1},
+		{"r/m rate refills over minutes", steady(0, 10, 60, 1), 1, 0, 0},
+		{"sustained flood", steady(0, 60, 1, 20), 60, 50, 60*20 - 60 - 50},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.timeline.limited(tt.perMinute, tt.burst); got != tt.want {
+				t.Errorf("limited(%d, %d) = %d, want %d", tt.perMinute, tt.burst, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateTimelineNormalize(t *testing.T) {
+	tl := timeline([2]int{5, 1}, [2]int{7, 2})
+	other := timeline([2]int{6, 1}, [2]int{7, 1})
+	tl.seconds = append(tl.seconds, other.seconds...)
+	tl.requests += other.requests
+	tl.normalize()
+
+	want := []rateSecond{{5, 1}, {6, 1}, {7, 3}}
+	if fmt.Sprint(tl.seconds) != fmt.Sprint(want) || tl.requests != 5 || tl.truncated() {
+		t.Errorf("normalize() = %v (%d requests), want %v", tl.seconds, tl.requests, want)
+	}
+}
+
+// browsing returns n legitimate clients opening a page every 10 seconds for
+// ten minutes (6 per minute, 1 per second).
+func browsing(clients map[string]*rateTimeline, n int) {
+	for i := 0; i < n; i++ {
+		clients[fmt.Sprintf("192.0.2.%d", i)] = steady(i, 60, 10, 1)
+	}
+}
+
+func TestRecommendRateLimit(t *testing.T) {
+	flooders := map[string]bool{"203.0.113.1": true, "203.0.113.2": true}
+	tests := []struct {
+		name    string
+		class   RequestClass
+		clients func() map[string]*rateTimeline
+		abusive map[string]bool
+		skipped string
+		minRate int
+	}{
+		{
+			name:  "flood limited above browsing peaks",
+			class: ClassDynamic,
+			clients: func() map[string]*rateTimeline {
+				clients := map[string]*rateTimeline{}
+				browsing(clients, 50)
+				clients["203.0.113.1"] = steady(0, 60, 1, 20)
+				clients["203.0.113.2"] = steady(0, 60, 1, 20)
+				return clients
+			},
+			abusive: flooders,
+			minRate: rateLimitMinRates[ClassDynamic],
+		},
+		{
+			name:  "fast legitimate client raises the minimum rate",
+			class: ClassDynamic,
+			clients: func() map[string]*rateTimeline {
+				clients := map[string]*rateTimeline{}
+				browsing(clients, 9)
+				clients["192.0.2.100"] = steady(0, 120, 1, 2) // 120 per minute
+				clients["203.0.113.1"] = steady(0, 60, 1, 40)
+				return clients
+			},
+			abusive: flooders,
+			minRate: rateLimitHeadroom * 120,
+		},
+		{
+			name:  "no abuse",
+			class: ClassREST,
+			clients: func() map[string]*rateTimeline {
+				clients := map[string]*rateTimeline{}
+				browsing(clients, 10)
+				return clients
+			},
+			abusive: flooders,
+			skipped: RateLimitNoAbuse,
+		},
+		{
+			name:  "abuse spread over many clients",
+			class: ClassLogin,
+			clients: func() map[string]*rateTimeline {
+				clients := map[string]*rateTimeline{}
+				browsing(clients, 10)
+				for i := 0; i < 100; i++ {
+					clients[fmt.Sprintf("198.51.100.%d", i)] = timeline([2]int{i, 1})
+				}
+				return clients
+			},
+			abusive: func() map[string]bool {
+				abusive := map[string]bool{}
+				for i := 0; i < 100; i++ {
+					abusive[fmt.Sprintf("198.51.100.%d", i)] = true
+				}
+				return abusive
+			}(),
+			skipped: RateLimitIneffective,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := recommendRateLimit(tt.class, tt.clients(), tt.abusive, 99)
+			if rec.Skipped != tt.skipped {
+				t.Fatalf("Skipped = %q, want %q (%+v)", rec.Skipped, tt.skipped, rec)
+			}
+			if tt.skipped != "" {
+				if rec.RatePerMinute != 0 {
+					t.Errorf("RatePerMinute = %d, want no recommendation", rec.RatePerMinute)
+				}
+				return
+			}
+			if rec.RatePerMinute < tt.minRate || rec.RatePerMinute < rateLimitHeadroom*rec.PeakLegit {
+				t.Errorf("RatePerMinute = %d, want >= %d and >= %d x peak %d", rec.RatePerMinute, tt.minRate, rateLimitHeadroom, rec.PeakLegit)
+			}
+			if rec.Burst <= 0 || rec.Burst < rateLimitHeadroom*rec.PeakLegitSecond {
+				t.Errorf("Burst = %d, want > 0 and >= %d x peak %d", rec.Burst, rateLimitHeadroom, rec.PeakLegitSecond)
+			}
+			if rec.LimitedLegitClients != 0 || rec.LimitedAbusiveRequests == 0 {
+				t.Errorf("limited legit %d clients, abusive %d requests; want 0 legit and some abuse", rec.LimitedLegitClients, rec.LimitedAbusiveRequests)
+			}
+		})
+	}
+}
+
+func TestRateClientsCapped(t *testing.T) {
+	cfg := loadTestConfig(t)
+	cfg.HeavyHitters.Capacity = 4
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	send := func(a *Analyzer, ip string, n int) {
+		for i := 0; i < n; i++ {
+			a.trackRate(&parser.LogEntry{Timestamp: at.Add(time.Duration(i) * time.Second), ClientIP: ip, Method: "GET", URI: "/", StatusCode: 200}, nil)
+		}
+	}
+	clientIPs := func(a *Analyzer) []string { return sortedKeys(a.rateClients[ClassDynamic]) }
+
+	a := NewAnalyzer(cfg)
+	send(a, "192.0.2.1", 5)
+	send(a, "192.0.2.2", 3)
+	send(a, "192.0.2.3", 1)
+	send(a, "192.0.2.4", 3)
+	// The class is full: the two quietest clients make room, the tie at 3
+	// going to the lower IP.
+	send(a, "192.0.2.5", 1)
+	if got, want := clientIPs(a), []string{"192.0.2.1", "192.0.2.2", "192.0.2.5"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("clients = %v, want %v", got, want)
+	}
+	if a.rateDropped[ClassDynamic] != 2 {
+		t.Errorf("dropped = %d, want 2", a.rateDropped[ClassDynamic])
+	}
+
+	// A merge keeps the busiest clients of both sides.
+	b := NewAnalyzer(cfg)
+	send(b, "192.0.2.1", 2)
+	send(b, "192.0.2.6", 4)
+	send(b, "192.0.2.7", 2)
+	b.totalRequests = 8 // Merge skips an empty analyzer
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := clientIPs(a), []string{"192.0.2.1", "192.0.2.2", "192.0.2.6", "192.0.2.7"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("merged clients = %v, want %v", got, want)
+	}
+	if a.rateClients[ClassDynamic]["192.0.2.1"].requests != 7 || a.rateDropped[ClassDynamic] != 3 {
+		t.Errorf("merged 192.0.2.1 requests = %d, dropped = %d; want 7 and 3", a.rateClients[ClassDynamic]["192.0.2.1"].requests, a.rateDropped[ClassDynamic])
+	}
+	if got := a.generateRateLimits().Classes; len(got) != 1 || got[0].Dropped != 3 || got[0].Clients != 4 {
+		t.Errorf("recommendation = %+v, want 4 clients and 3 dropped", got)
+	}
+}
@@ -0,0 +1,167 @@
+package analyzer
+
+import (
+	"strings"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/sketch"
+)
+
+// redirectSweepInterval is how many entries are processed between sweeps that
+// close stale redirect chains.
+const redirectSweepInterval = 10000
+
+type RedirectAnalysis struct {
+	Total           int
+	ByCode          map[int]int // 301, 302, 303, 307, 308 ...
+	TopRedirectURLs []URLError
+	TopMappings     []UpstreamMapping
+	Chains          []RedirectChain // 2回以上連続したリダイレクト
+	Loops           []RedirectChain // 同じURLに戻る、または max_chain_length を超えたチェーン
+}
+
+type RedirectChain struct {
+	Path       string // 例: "/old → /new ⇒ /new/"（→ はリダイレクト、⇒ は最終的に到達したURL）
+	Redirects  int    // チェーン内の 3xx の数
+	Count      int
+	ErrorBound int
+}
+
+// openRedirectChain is a client's in-progress sequence of redirected requests.
+type openRedirectChain struct {
+	urls     []string
+	lastSeen time.Time
+	loop     bool
+}
+
+func isRedirect(status int) bool {
+	return status >= 300 && status < 400 && status != 304
+}
+
+func (a *Analyzer) trackRedirect(entry *parser.LogEntry) {
+	if a.totalRequests%redirectSweepInterval == 0 {
+		a.sweepRedirectChains(a.endTime)
+	}
+
+	redirect := isRedirect(entry.StatusCode)
+	if redirect {
+		a.redirectCodes[entry.StatusCode]++
+		a.redirectURLs.Add(entry.URI, 1)
+		if entry.UpstreamURI != "" {
+			a.redirectMappings.Add(entry.URI+"\x00"+entry.UpstreamURI, 1)
+		}
+	}
+
+	window := time.Duration(a.config.Redirects.ChainWindowSeconds) * time.Second
+	if window <= 0 {
+		return
+	}
+	key := entry.ClientIP + "\x00" + entry.UserAgent
+	chain := a.redirectChains[key]
+	if chain != nil && entry.Timestamp.Sub(chain.lastSeen) > window {
+		a.closeRedirectChain(chain, "")
+		delete(a.redirectChains, key)
+		chain = nil
+	}
+
+	if !redirect {
+		if chain != nil {
+			a.closeRedirectChain(chain, pagePath(entry.URI))
+			delete(a.redirectChains, key)
+		}
+		return
+	}
+
+	page := pagePath(entry.URI)
+	if chain == nil {
+		chain = &openRedirectChain{}
+		a.redirectChains[key] = chain
+	}
+	for _, u := range chain.urls {
+		if u == page {
+			chain.loop = true
+		}
+	}
+	chain.urls = append(chain.urls, page)
+	chain.lastSeen = entry.Timestamp
+
+	maxLen := a.config.Redirects.MaxChainLength
+	if chain.loop || (maxLen > 0 && len(chain.urls) > maxLen) {
+		chain.loop = true
+		a.closeRedirectChain(chain, "")
+		delete(a.redirectChains, key)
+	}
+}
+
+// closeRedirectChain records chain if it had at least two redirects or looped.
+// destination is the first non-redirect URL requested, if any.
+func (a *Analyzer) closeRedirectChain(chain *openRedirectChain, destination string) {
+	if len(chain.urls) < 2 && !chain.loop {
+		return
+	}
+	path := strings.Join(chain.urls, " → ")
+	if destination != "" {
+		path += " ⇒ " + destination
+	}
+	if chain.loop {
+		a.redirectLoops.Add(path, 1)
+	} else {
+		a.redirectChainPaths.Add(path, 1)
+	}
+}
+
+func (a *Analyzer) sweepRedirectChains(now time.Time) {
+	window := time.Duration(a.config.Redirects.ChainWindowSeconds) * time.Second
+	for _, key := range sortedKeys(a.redirectChains) {
+		chain := a.redirectChains[key]
+		if now.Sub(chain.lastSeen) > window {
+			a.closeRedirectChain(chain, "")
+			delete(a.redirectChains, key)
+		}
+	}
+}
+
+// generateRedirectAnalysis closes all remaining chains and returns Top 10
+// redirecting URLs, URL→upstream mappings, chains and loops.
+// Chains are closed in place, so it must only be called once per analysis.
+func (a *Analyzer) generateRedirectAnalysis() RedirectAnalysis {
+	for _, key := range sortedKeys(a.redirectChains) {
+		a.closeRedirectChain(a.redirectChains[key], "")
+		delete(a.redirectChains, key)
+	}
+
+	total := 0
+	for _, c := range a.redirectCodes {
+		total += c
+	}
+
+	var mappings []UpstreamMapping
+	for _, item := range a.redirectMappings.Top(10) {
+		url, upstream, _ := strings.Cut(item.Key, "\x00")
+		mappings = append(mappings, UpstreamMapping{URL: url, UpstreamURI: upstream, Count: item.Count, ErrorBound: item.Error})
+	}
+
+	chains := func(items []sketch.Item) []RedirectChain {
+		var result []RedirectChain
+		for _, item := range items {
+			redirects, _, _ := strings.Cut(item.Key, " ⇒ ")
+			result = append(result, RedirectChain{
+				Path:       item.Key,
+				Redirects:  strings.Count(redirects, " → ") + 1,
+				Count:      item.Count,
+				ErrorBound: item.Error,
+			})
+		}
+		return result
+	}
+
+	return RedirectAnalysis{
+		Total:           total,
+		ByCode:          a.redirectCodes,
+		TopRedirectURLs: urlErrorsFromTopK(a.redirectURLs, 10),
+		TopMappings:     mappings,
+		Chains:          chains(a.redirectChainPaths.Top(10)),
+		Loops:           chains(a.redirectLoops.Top(10)),
+	}
+}
@@ -0,0 +1,146 @@
+package analyzer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+func TestRedirectChains(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	type request struct {
+		second int
+		ip     string
+		uri    string
+		status int
+	}
+	tests := []struct {
+		name           string
+		maxChainLength int
+		requests       []request
+		wantChains     []string
+		wantLoops      []string
+	}{
+		{
+			name:     "single redirect is not a chain",
+			requests: []request{{0, "192.0.2.1", "/old", 301}, {1, "192.0.2.1", "/new", 200}},
+		},
+		{
+			name: "chain with its destination",
+			requests: []request{
+				{0, "192.0.2.1", "/old", 301},
+				{1, "192.0.2.1", "/new", 301},
+				{2, "192.0.2.1", "/new/", 200},
+			},
+			wantChains: []string{"/old → /new ⇒ /new/"},
+		},
+		{
+			name:       "chain still open at the end",
+			requests:   []request{{0, "192.0.2.1", "/a", 302}, {1, "192.0.2.1", "/b", 307}},
+			wantChains: []string{"/a → /b"},
+		},
+		{
+			name:     "gap longer than the window breaks the chain",
+			requests: []request{{0, "192.0.2.1", "/a", 301}, {10, "192.0.2.1", "/b", 301}, {11, "192.0.2.1", "/c", 200}},
+		},
+		{
+			name:     "304 ends a chain like any other response",
+			requests: []request{{0, "192.0.2.1", "/a", 301}, {1, "192.0.2.1", "/b", 304}, {2, "192.0.2.1", "/c", 301}},
+		},
+		{
+			name: "clients are followed separately",
+			requests: []request{
+				{0, "192.0.2.1", "/a", 301},
+				{0, "192.0.2.2", "/x", 301},
+				{1, "192.0.2.1", "/b", 301},
+				{1, "192.0.2.2", "/y", 200},
+				{2, "192.0.2.1", "/c", 200},
+			},
+			wantChains: []string{"/a → /b ⇒ /c"},
+		},
+		{
+			name: "returning to a URL is a loop",
+			requests: []request{
+				{0, "192.0.2.1", "/a", 302},
+				{1, "192.0.2.1", "/b", 302},
+				{2, "192.0.2.1", "/a", 302},
+				{3, "192.0.2.1", "/b", 302},
+			},
+			wantLoops: []string{"/a → /b → /a"},
+		},
+		{
+			name:      "query strings are ignored",
+			requests:  []request{{0, "192.0.2.1", "/a?lang=en", 301}, {1, "192.0.2.1", "/a?lang=ja", 301}},
+			wantLoops: []string{"/a → /a"},
+		},
+		{
+			name:           "chain longer than max_chain_length is a loop",
+			maxChainLength: 3,
+			requests: []request{
+				{0, "192.0.2.1", "/1", 301},
+				{1, "192.0.2.1", "/2", 301},
+				{2, "192.0.2.1", "/3", 301},
+				{3, "192.0.2.1", "/4", 301},
+			},
+			wantLoops: []string{"/1 → /2 → /3 → /4"},
+		},
+	}
+	paths := func(chains []RedirectChain) []string {
+		var result []string
+		for _, c := range chains {
+			result = append(result, c.Path)
+		}
+		return result
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadTestConfig(t)
+			cfg.Redirects.ChainWindowSeconds = 5
+			cfg.Redirects.MaxChainLength = 10
+			if tt.maxChainLength > 0 {
+				cfg.Redirects.MaxChainLength = tt.maxChainLength
+			}
+			a := NewAnalyzer(cfg)
+			for _, r := range tt.requests {
+				a.trackRedirect(&parser.LogEntry{
+					Timestamp:  start.Add(time.Duration(r.second) * time.Second),
+					ClientIP:   r.ip,
+					UserAgent:  "Mozilla/5.0",
+					URI:        r.uri,
+					StatusCode: r.status,
+				})
+			}
+
+			got := a.generateRedirectAnalysis()
+			if chains := paths(got.Chains); !reflect.DeepEqual(chains, tt.wantChains) {
+				t.Errorf("Chains = %q, want %q", chains, tt.wantChains)
+			}
+			if loops := paths(got.Loops); !reflect.DeepEqual(loops, tt.wantLoops) {
+				t.Errorf("Loops = %q, want %q", loops, tt.wantLoops)
+			}
+		})
+	}
+}
+
+func TestRedirectCounts(t *testing.T) {
+	a := NewAnalyzer(loadTestConfig(t))
+	for _, r := range []struct {
+		uri    string
+		status int
+	}{{"/old", 301}, {"/old", 301}, {"/go", 302}, {"/cached", 304}, {"/", 200}} {
+		a.trackRedirect(&parser.LogEntry{ClientIP: "192.0.2.1", URI: r.uri, UpstreamURI: "/index.php", StatusCode: r.status})
+	}
+
+	got := a.generateRedirectAnalysis()
+	if got.Total != 3 || !reflect.DeepEqual(got.ByCode, map[int]int{301: 2, 302: 1}) {
+		t.Errorf("Total = %d, ByCode = %v, want 3, map[301:2 302:1]", got.Total, got.ByCode)
+	}
+	if len(got.TopRedirectURLs) != 2 || got.TopRedirectURLs[0].URL != "/old" || got.TopRedirectURLs[0].Count != 2 {
+		t.Errorf("TopRedirectURLs = %+v, want /old twice first", got.TopRedirectURLs)
+	}
+	if len(got.TopMappings) == 0 || got.TopMappings[0] != (UpstreamMapping{URL: "/old", UpstreamURI: "/index.php", Count: 2}) {
+		t.Errorf("TopMappings = %+v, want /old → /index.php twice first", got.TopMappings)
+	}
+}
@@ -0,0 +1,118 @@
+package analyzer
+
+import (
+	"net/url"
+	"path"
+	"strings"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/sketch"
+)
+
+// mediaExtensions are the assets counted as hotlinked when fetched with an
+// external referer.
+var mediaExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true, ".avif": true, ".svg": true, ".bmp": true,
+	".mp4": true, ".webm": true, ".mov": true, ".mp3": true, ".wav": true, ".ogg": true,
+}
+
+type RefererAnalysis struct {
+	NoReferer         int // "-" または空
+	Internal          int
+	External          int
+	SearchEngines     map[string]int // 検索エンジンのパターン -> リクエスト数
+	TopReferringHosts []HostCount    // 外部リファラーのホスト
+	SpamReferers      []HostCount
+	HotlinkRequests   int
+	HotlinkBytes      int64
+	HotlinkHosts      []HotlinkHost
+}
+
+type HostCount struct {
+	Host       string
+	Count      int
+	ErrorBound int // Count の過大計上の上限（0 なら正確）
+}
+
+// HotlinkHost は外部サイトから画像・メディアを直接参照しているホスト。
+type HotlinkHost struct {
+	Host     string
+	Requests int
+	Bytes    int64
+}
+
+// refererHost extracts the lowercased host (without port) from a Referer
+// header value, or "" if it is absent or unparsable.
+func refererHost(referer string) string {
+	if referer == "" || referer == "-" {
+		return ""
+	}
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func (a *Analyzer) trackReferer(entry *parser.LogEntry) {
+	host := refererHost(entry.Referer)
+	if host == "" {
+		a.noReferer++
+		return
+	}
+	if a.config.IsInternalHost(host, entry.Domain) {
+		a.internalReferers++
+		return
+	}
+
+	a.externalReferers++
+	a.refererHosts.Add(host, 1)
+	if engine := a.config.SearchEngine(host); engine != "" {
+		a.searchEngines[engine]++
+		return
+	}
+	if a.config.IsRefererSpam(host) {
+		a.spamReferers.Add(host, 1)
+	}
+	if mediaExtensions[strings.ToLower(path.Ext(pagePath(entry.URI)))] {
+		a.hotlinkRequests++
+		a.hotlinkBytes += entry.ResponseSize
+		a.hotlinkHostRequests.Add(host, 1)
+		a.hotlinkHostBytes.Add(host, int(entry.ResponseSize))
+	}
+}
+
+func hostCountsFromTopK(t *sketch.TopK, n int) []HostCount {
+	var result []HostCount
+	for _, item := range t.Top(n) {
+		result = append(result, HostCount{Host: item.Key, Count: item.Count, ErrorBound: item.Error})
+	}
+	return result
+}
+
+// generateRefererAnalysis returns referer totals plus Top 10 external hosts,
+// spam hosts and hotlinking hosts (by bytes). Search engines are excluded
+// from hotlinking since image search legitimately embeds thumbnails.
+func (a *Analyzer) generateRefererAnalysis() RefererAnalysis {
+	var hotlinkers []HotlinkHost
+	for _, item := range a.hotlinkHostBytes.Top(10) {
+		requests, _ := a.hotlinkHostRequests.Get(item.Key)
+		hotlinkers = append(hotlinkers, HotlinkHost{
+			Host:     item.Key,
+			Requests: requests.Count,
+			Bytes:    int64(item.Count),
+		})
+	}
+
+	return RefererAnalysis{
+		NoReferer:         a.noReferer,
+		Internal:          a.internalReferers,
+		External:          a.externalReferers,
+		SearchEngines:     a.searchEngines,
+		TopReferringHosts: hostCountsFromTopK(a.refererHosts, 10),
+		SpamReferers:      hostCountsFromTopK(a.spamReferers, 10),
+		HotlinkRequests:   a.hotlinkRequests,
+		HotlinkBytes:      a.hotlinkBytes,
+		HotlinkHosts:      hotlinkers,
+	}
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+// SampledTime is a response time in a responseSample, ranked by the hash of
+// the entry it came from.
+type SampledTime struct {
+	Hash  uint64  `json:"hash"`
+	Value float64 `json:"value"`
+}
+
+func (t SampledTime) before(u SampledTime) bool {
+	return t.Hash < u.Hash || t.Hash == u.Hash && t.Value < u.Value
+}
+
+// responseSample is a bottom-k sample of response times: of all entries added
+// it keeps the maxResponseTimeSamples with the smallest entry hash. Which
+// entries those are depends only on the entries, not on the order they were
+// added in, so samples built by shards or from snapshots and then merged are
+// the sample a single pass over the same entries builds.
+//
+// It is kept as a max-heap, so the entry to evict is at the root.
+type responseSample []SampledTime
+
+func (s responseSample) Len() int           { return len(s) }
+func (s responseSample) Less(i, j int) bool { return s[j].before(s[i]) }
+func (s responseSample) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s *responseSample) Push(x any)        { *s = append(*s, x.(SampledTime)) }
+func (s *responseSample) Pop() any {
+	old := *s
+	t := old[len(old)-1]
+	*s = old[:len(old)-1]
+	return t
+}
+
+func (s *responseSample) add(t SampledTime) {
+	if len(*s) < maxResponseTimeSamples {
+		heap.Push(s, t)
+		return
+	}
+	if t.before((*s)[0]) {
+		(*s)[0] = t
+		heap.Fix(s, 0)
+	}
+}
+
+func (s *responseSample) merge(other responseSample) {
+	for _, t := range other {
+		s.add(t)
+	}
+}
+
+// values returns the sampled response times.
+func (s responseSample) values() []float64 {
+	result := make([]float64, len(s))
+	for i, t := range s {
+		result[i] = t.Value
+	}
+	return result
+}
+
+// sampleOf returns the sample entry of e: its response time, ranked by an
+// FNV-1a hash of the fields that tell log lines apart.
+func sampleOf(e *parser.LogEntry) SampledTime {
+	h := uint64(14695981039346656037)
+	mix := func(s string) {
+		for i := 0; i < len(s); i++ {
+			h ^= uint64(s[i])
+			h *= 1099511628211
+		}
+		h ^= 0xff // field separator
+		h *= 1099511628211
+	}
+	mixInt := func(v uint64) {
+		for i := 0; i < 8; i++ {
+			h ^= v & 0xff
+			h *= 1099511628211
+			v >>= 8
+		}
+	}
+	mixInt(uint64(e.Timestamp.UnixNano()))
+	mix(e.ClientIP)
+	mix(e.Method)
+	mix(e.URI)
+	mix(e.UserAgent)
+	mixInt(uint64(e.StatusCode))
+	mixInt(uint64(e.ResponseSize))
+	mixInt(math.Float64bits(e.ResponseTime))
+	return SampledTime{Hash: h, Value: e.ResponseTime}
+}
+
+// keepBusiest keeps the keep entries of m with the highest count, ties going
+// to the lower key, and returns the number dropped.
+func keepBusiest[V any](m map[string]V, keep int, count func(V) int) int {
+	if len(m) <= keep {
+		return 0
+	}
+	keys := sortedKeys(m)
+	sort.SliceStable(keys, func(i, j int) bool { return count(m[keys[i]]) > count(m[keys[j]]) })
+	for _, k := range keys[keep:] {
+		delete(m, k)
+	}
+	return len(keys) - keep
+}
+
+// keepEarliest adds t to the ascending timestamps ts, keeping at most
+// maxErrorTimestampsPerIP of the earliest ones. In a log written in time
+// order every t lands at the end, so the common case costs O(1).
+func keepEarliest(ts []time.Time, t time.Time) []time.Time {
+	if len(ts) >= maxErrorTimestampsPerIP && !t.Before(ts[len(ts)-1]) {
+		return ts
+	}
+	i := sort.Search(len(ts), func(i int) bool { return t.Before(ts[i]) })
+	if len(ts) < maxErrorTimestampsPerIP {
+		ts = append(ts, time.Time{})
+	}
+	copy(ts[i+1:], ts[i:])
+	ts[i] = t
+	return ts
+}
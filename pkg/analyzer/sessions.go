@@ -0,0 +1,146 @@
+package analyzer
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+// sessionSweepInterval is how many entries are processed between sweeps that
+// close idle sessions, keeping the set of open sessions bounded on long logs.
+const sessionSweepInterval = 10000
+
+type openSession struct {
+	start     time.Time
+	lastSeen  time.Time
+	pages     int
+	entryPage string
+	exitPage  string
+	path      []string
+}
+
+type SessionAnalysis struct {
+	TotalSessions      int
+	AvgPagesPerSession float64
+	AvgDuration        float64 // seconds
+	BounceRate         float64 // 1ページのみで終了したセッションの割合 (%)
+	TopEntryPages      []URLError
+	TopExitPages       []URLError
+	TopPaths           []PathCount
+}
+
+type PathCount struct {
+	Path  string // 例: "/ → /shop/ → /cart/"
+	Count int
+}
+
+// isPageView reports whether entry looks like a human page view: a successful
+// or redirected GET for a dynamic page (not an asset, REST/AJAX, admin, feed
+// or sitemap request).
+func isPageView(entry *parser.LogEntry) bool {
+	if entry.Method != "GET" || entry.StatusCode >= 400 {
+		return false
+	}
+	return classifyRequest(entry) == ClassDynamic
+}
+
+// trackSession adds entry to its visitor's session. automated entries
+// (crawlers and attack tools) are not counted as visits.
+func (a *Analyzer) trackSession(entry *parser.LogEntry, automated bool) {
+	timeout := a.sessionTimeout()
+	if timeout <= 0 {
+		return
+	}
+	if a.totalRequests%sessionSweepInterval == 0 {
+		a.sweepSessions(a.endTime, timeout)
+	}
+	if automated || !isPageView(entry) {
+		return
+	}
+
+	key := entry.ClientIP + "\x00" + entry.UserAgent
+	page := pagePath(entry.URI)
+	s := a.openSessions[key]
+	if s != nil && entry.Timestamp.Sub(s.lastSeen) > timeout {
+		a.closeSession(s)
+		s = nil
+	}
+	if s == nil {
+		s = &openSession{start: entry.Timestamp, entryPage: page}
+		a.openSessions[key] = s
+	}
+	if entry.Timestamp.After(s.lastSeen) {
+		s.lastSeen = entry.Timestamp
+	}
+	s.pages++
+	s.exitPage = page
+	if len(s.path) < a.config.Sessions.PathDepth {
+		s.path = append(s.path, page)
+	}
+}
+
+func (a *Analyzer) sessionTimeout() time.Duration {
+	return time.Duration(a.config.Sessions.InactivityTimeoutMinutes) * time.Minute
+}
+
+// sweepSessions closes every open session idle for longer than timeout as of now.
+func (a *Analyzer) sweepSessions(now time.Time, timeout time.Duration) {
+	for key, s := range a.openSessions {
+		if now.Sub(s.lastSeen) > timeout {
+			a.closeSession(s)
+			delete(a.openSessions, key)
+		}
+	}
+}
+
+func (a *Analyzer) closeSession(s *openSession) {
+	a.sessionCount++
+	a.sessionPages += s.pages
+	a.sessionDurationSum += s.lastSeen.Sub(s.start).Seconds()
+	if s.pages == 1 {
+		a.sessionBounces++
+	}
+	a.sessionEntryPages[s.entryPage]++
+	a.sessionExitPages[s.exitPage]++
+	if len(s.path) > 1 {
+		a.sessionPaths[strings.Join(s.path, " → ")]++
+	}
+}
+
+// generateSessions closes all remaining open sessions and summarises them.
+// Sessions are closed in place, so it must only be called once per analysis.
+func (a *Analyzer) generateSessions() SessionAnalysis {
+	for key, s := range a.openSessions {
+		a.closeSession(s)
+		delete(a.openSessions, key)
+	}
+	if a.sessionCount == 0 {
+		return SessionAnalysis{}
+	}
+
+	var paths []PathCount
+	for p, c := range a.sessionPaths {
+		paths = append(paths, PathCount{Path: p, Count: c})
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if paths[i].Count != paths[j].Count {
+			return paths[i].Count > paths[j].Count
+		}
+		return paths[i].Path < paths[j].Path
+	})
+	if len(paths) > 10 {
+		paths = paths[:10]
+	}
+
+	return SessionAnalysis{
+		TotalSessions:      a.sessionCount,
+		AvgPagesPerSession: float64(a.sessionPages) / float64(a.sessionCount),
+		AvgDuration:        a.sessionDurationSum / float64(a.sessionCount),
+		BounceRate:         float64(a.sessionBounces) / float64(a.sessionCount) * 100,
+		TopEntryPages:      topURLs(a.sessionEntryPages, 10),
+		TopExitPages:       topURLs(a.sessionExitPages, 10),
+		TopPaths:           paths,
+	}
+}
@@ -0,0 +1,779 @@
+package analyzer
+
+import (
+	"container/heap"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"kinsta-log-analyzer/pkg/config"
+	"kinsta-log-analyzer/pkg/sketch"
+)
+
+// snapshotVersion is bumped whenever Snapshot changes incompatibly, including
+// when sections are added: an older snapshot would restore them as empty and
+// silently under-report after a merge.
+const snapshotVersion = 4
+
+// Snapshot is the complete aggregation state of an Analyzer in serialisable
+// form. Snapshots of separate files, days or machines can be restored and
+// merged, then turned into a single AnalysisResult, e.g. to build weekly
+// reports from daily pre-aggregates. All analyzers involved must use the same
+// configuration.
+type Snapshot struct {
+	Version             int                                            `json:"version"`
+	HeavyHitterCapacity int                                            `json:"heavy_hitter_capacity"`
+	UniquePrecision     int                                            `json:"unique_precision"`
+	Position            int64                                          `json:"position"`
+	TotalRequests       int                                            `json:"total_requests"`
+	ErrorRequests       int                                            `json:"error_requests"`
+	ResponseTimeMs      int64                                          `json:"response_time_ms"`
+	ResponseTimeMax     float64                                        `json:"response_time_max"`
+	ResponseTimeCount   int                                            `json:"response_time_count"`
+	ResponseTimeSample  []SampledTime                                  `json:"response_time_sample"`
+	SlowRequestCount    int                                            `json:"slow_request_count"`
+	IPCounts            *sketch.TopK                                   `json:"ip_counts"`
+	ErrorURLs           *sketch.TopK                                   `json:"error_urls"`
+	HourlyPattern       [24]int                                        `json:"hourly_pattern"`
+	HourlyClientErrors  [24]int                                        `json:"hourly_client_errors"`
+	HourlyServerErrors  [24]int                                        `json:"hourly_server_errors"`
+	StatusCodes         map[int]int                                    `json:"status_codes"`
+	UserAgents          *sketch.TopK                                   `json:"user_agents"`
+	AttacksByIP         map[string]*IPAttacks                          `json:"attacks_by_ip"`
+	Crawlers            map[string]int                                 `json:"crawlers"`
+	AttackTools         map[string]int                                 `json:"attack_tools"`
+	RuleHits            map[string]*RuleHitState                       `json:"rule_hits"`
+	CategoryHits        map[string]*CategoryHitState                   `json:"category_hits"`
+	ErrorsByUA          *sketch.TopK                                   `json:"errors_by_ua"`
+	URLsByStatus        map[int]*sketch.TopK                           `json:"urls_by_status"`
+	SlowURLs            *sketch.TopK                                   `json:"slow_urls"`
+	ErrorTimestampsByIP map[string][]time.Time                         `json:"error_timestamps_by_ip"`
+	MinuteBuckets       map[int64]*MinuteBucketState                   `json:"minute_buckets"`
+	OpenSessions        map[string]*SessionState                       `json:"open_sessions"`
+	SessionCount        int                                            `json:"session_count"`
+	SessionPages        int                                            `json:"session_pages"`
+	SessionBounces      int                                            `json:"session_bounces"`
+	SessionDurationSum  float64                                        `json:"session_duration_sum"`
+	SessionEntryPages   map[string]int                                 `json:"session_entry_pages"`
+	SessionExitPages    map[string]int                                 `json:"session_exit_pages"`
+	SessionPaths        map[string]int                                 `json:"session_paths"`
+	Uniques             *UniqueSketchesState                           `json:"uniques"`
+	HourlyUniques       map[int64]*UniqueSketchesState                 `json:"hourly_uniques"`
+	DailyUniques        map[int64]*UniqueSketchesState                 `json:"daily_uniques"`
+	NoReferer           int                                            `json:"no_referer"`
+	InternalReferers    int                                            `json:"internal_referers"`
+	ExternalReferers    int                                            `json:"external_referers"`
+	RefererHosts        *sketch.TopK                                   `json:"referer_hosts"`
+	SearchEngines       map[string]int                                 `json:"search_engines"`
+	SpamReferers        *sketch.TopK                                   `json:"spam_referers"`
+	HotlinkRequests     int                                            `json:"hotlink_requests"`
+	HotlinkBytes        int64                                          `json:"hotlink_bytes"`
+	HotlinkHostRequests *sketch.TopK                                   `json:"hotlink_host_requests"`
+	HotlinkHostBytes    *sketch.TopK                                   `json:"hotlink_host_bytes"`
+	MethodStatus        map[string]map[int]int                         `json:"method_status"`
+	Protocols           map[string]int                                 `json:"protocols"`
+	HTTP10Clients       *sketch.TopK                                   `json:"http10_clients"`
+	PostURLs            *sketch.TopK                                   `json:"post_urls"`
+	UnusualMethods      []UnusualMethodIP                              `json:"unusual_methods"`
+	RedirectCodes       map[int]int                                    `json:"redirect_codes"`
+	RedirectURLs        *sketch.TopK                                   `json:"redirect_urls"`
+	RedirectMappings    *sketch.TopK                                   `json:"redirect_mappings"`
+	RedirectChains      map[string]*RedirectChainState                 `json:"redirect_chains"`
+	RedirectChainPaths  *sketch.TopK                                   `json:"redirect_chain_paths"`
+	RedirectLoops       *sketch.TopK                                   `json:"redirect_loops"`
+	UpstreamPHP         UpstreamState                                  `json:"upstream_php"`
+	UpstreamStatic      UpstreamState                                  `json:"upstream_static"`
+	UpstreamUnknown     int                                            `json:"upstream_unknown"`
+	UpstreamScripts     map[string]*UpstreamState                      `json:"upstream_scripts"`
+	UpstreamRewrites    *sketch.TopK                                   `json:"upstream_rewrites"`
+	LoginByIP           map[string]*LoginState                         `json:"login_by_ip"`
+	LoginTotals         LoginState                                     `json:"login_totals"`
+	LoginUniqueIPs      *sketch.HyperLogLog                            `json:"login_unique_ips"`
+	LoginDropped        int                                            `json:"login_dropped"`
+	XMLRPCRequests      int                                            `json:"xmlrpc_requests"`
+	XMLRPCPosts         int                                            `json:"xmlrpc_posts"`
+	XMLRPCLargePosts    int                                            `json:"xmlrpc_large_posts"`
+	XMLRPCBlocked       int                                            `json:"xmlrpc_blocked"`
+	XMLRPCIPs           *sketch.TopK                                   `json:"xmlrpc_ips"`
+	XMLRPCUniqueIPs     *sketch.HyperLogLog                            `json:"xmlrpc_unique_ips"`
+	AuthorRequests      int                                            `json:"author_requests"`
+	AuthorRedirects     int                                            `json:"author_redirects"`
+	AuthorIDs           map[int]int                                    `json:"author_ids"`
+	RESTUserRequests    int                                            `json:"rest_user_requests"`
+	RESTUserExposed     int                                            `json:"rest_user_exposed"`
+	UserEnumIPs         *sketch.TopK                                   `json:"user_enum_ips"`
+	ProbeRequests       int                                            `json:"probe_requests"`
+	ProbeIPs            *sketch.TopK                                   `json:"probe_ips"`
+	ProbeUniqueIPs      *sketch.HyperLogLog                            `json:"probe_unique_ips"`
+	ProbedComponents    map[string]*ComponentState                     `json:"probed_components"`
+	ComponentVersions   map[string]map[string]int                      `json:"component_versions"`
+	DistributedAttempts int                                            `json:"distributed_attempts"`
+	LoginWindows        []LoginWindowState                             `json:"login_windows"`
+	RateClients         map[RequestClass]map[string]*RateTimelineState `json:"rate_clients"`
+	RateAbusiveIPs      map[string]bool                                `json:"rate_abusive_ips"`
+	RateDropped         map[RequestClass]int                           `json:"rate_dropped"`
+	Exposures           map[string]*ExposureState                      `json:"exposures"`
+	ExposureIPs         *sketch.TopK                                   `json:"exposure_ips"`
+	ExposureUniqueIPs   *sketch.HyperLogLog                            `json:"exposure_unique_ips"`
+	ExposureRewritten   int                                            `json:"exposure_rewritten"`
+	ClassStats          map[RequestClass]*ClassState                   `json:"class_stats"`
+	StartTime           time.Time                                      `json:"start_time"`
+	EndTime             time.Time                                      `json:"end_time"`
+}
+
+// MinuteBucketState は異常検知用の分単位バケット。
+type MinuteBucketState struct {
+	Requests       int                      `json:"requests"`
+	Errors         int                      `json:"errors"`
+	ResponseTimeMs int64                    `json:"response_time_ms"`
+	URLs           map[string]KeyCountState `json:"urls"`
+	IPs            map[string]KeyCountState `json:"ips"`
+}
+
+// KeyCountState は分単位バケットの URL / IP の件数と、最初に現れた入力上の位置。
+type KeyCountState struct {
+	Count int   `json:"count"`
+	First int64 `json:"first"`
+}
+
+// SessionState はスナップショット時点で継続中のセッション。
+type SessionState struct {
+	Start     time.Time `json:"start"`
+	LastSeen  time.Time `json:"last_seen"`
+	Pages     int       `json:"pages"`
+	EntryPage string    `json:"entry_page"`
+	ExitPage  string    `json:"exit_page"`
+	Path      []string  `json:"path"`
+}
+
+type RuleHitState struct {
+	Hits        int                 `json:"hits"`
+	IPs         *sketch.HyperLogLog `json:"ips"`
+	TopIPs      *sketch.TopK        `json:"top_ips,omitempty"`
+	Seen        seenRange           `json:"seen"`
+	Example     string              `json:"example"`
+	Field       string              `json:"field"`
+	ExampleSeen time.Time           `json:"example_seen"`
+}
+
+type CategoryHitState struct {
+	Requests  int                 `json:"requests"`
+	IPs       *sketch.TopK        `json:"ips"`
+	UniqueIPs *sketch.HyperLogLog `json:"unique_ips"`
+	Seen      seenRange           `json:"seen"`
+}
+
+type UniqueSketchesState struct {
+	IPs      *sketch.HyperLogLog `json:"ips"`
+	Visitors *sketch.HyperLogLog `json:"visitors"`
+	URLs     *sketch.HyperLogLog `json:"urls"`
+}
+
+// RedirectChainState はスナップショット時点で継続中のリダイレクトチェーン。
+type RedirectChainState struct {
+	URLs     []string  `json:"urls"`
+	LastSeen time.Time `json:"last_seen"`
+	Loop     bool      `json:"loop,omitempty"`
+}
+
+type UpstreamState struct {
+	Requests        int     `json:"requests"`
+	Errors          int     `json:"errors"`
+	ResponseTimeMs  int64   `json:"response_time_ms"`
+	ResponseTimeMax float64 `json:"response_time_max"`
+}
+
+// LoginState は IP ごとの wp-login.php ログイン試行。
+type LoginState struct {
+	Attempts  int       `json:"attempts"`
+	Successes int       `json:"successes"`
+	Failures  int       `json:"failures"`
+	Blocked   int       `json:"blocked"`
+	Seen      seenRange `json:"seen"`
+}
+
+// LoginWindowState はログインエンドポイント・時間ウィンドウごとのクライアント別試行。
+type LoginWindowState struct {
+	Endpoint string                       `json:"endpoint"`
+	Start    int64                        `json:"start"`
+	Clients  map[string]*LoginClientState `json:"clients"`
+}
+
+type LoginClientState struct {
+	Attempts  int `json:"attempts"`
+	Successes int `json:"successes"`
+}
+
+// RateTimelineState はクライアントの秒ごとのリクエスト数（[unix 秒, 件数]）。
+type RateTimelineState struct {
+	Requests int        `json:"requests"`
+	Seconds  [][2]int64 `json:"seconds"`
+}
+
+type ComponentState struct {
+	Requests   int            `json:"requests"`
+	Found      int            `json:"found"`
+	FoundPaths map[string]int `json:"found_paths,omitempty"`
+}
+
+// ExposureState は機密ファイルのパスごとの集計。
+type ExposureState struct {
+	Requests int            `json:"requests"`
+	Leaks    int            `json:"leaks"`
+	MaxBytes int64          `json:"max_bytes"`
+	RuleID   string         `json:"rule_id"`
+	LeakIPs  map[string]int `json:"leak_ips,omitempty"`
+	Seen     seenRange      `json:"seen"`
+	LeakSeen seenRange      `json:"leak_seen"`
+}
+
+type ClassState struct {
+	Requests           int                 `json:"requests"`
+	ClientErrors       int                 `json:"client_errors"`
+	ServerErrors       int                 `json:"server_errors"`
+	SlowRequests       int                 `json:"slow_requests"`
+	ResponseTimeMs     int64               `json:"response_time_ms"`
+	ResponseTimeSample []SampledTime       `json:"response_time_sample"`
+	IPs                *sketch.HyperLogLog `json:"ips"`
+}
+
+// Snapshot returns the analyzer's current state. The snapshot shares maps and
+// sketches with a, so it should be written out (or restored) before a
+// processes more entries or produces its Result.
+func (a *Analyzer) Snapshot() *Snapshot {
+	s := &Snapshot{
+		Version:             snapshotVersion,
+		HeavyHitterCapacity: a.ipCounts.Capacity(),
+		UniquePrecision:     a.uniques.ips.Precision(),
+		Position:            a.position,
+		TotalRequests:       a.totalRequests,
+		ErrorRequests:       a.errorRequests,
+		ResponseTimeMs:      a.responseTimeMs,
+		ResponseTimeMax:     a.responseTimeMax,
+		ResponseTimeCount:   a.responseTimeCount,
+		ResponseTimeSample:  a.responseTimeSample,
+		SlowRequestCount:    a.slowRequestCount,
+		IPCounts:            a.ipCounts,
+		ErrorURLs:           a.errorURLs,
+		HourlyPattern:       a.hourlyPattern,
+		HourlyClientErrors:  a.hourlyClientErrors,
+		HourlyServerErrors:  a.hourlyServerErrors,
+		StatusCodes:         a.statusCodes,
+		UserAgents:          a.userAgents,
+		AttacksByIP:         a.attacksByIP,
+		Crawlers:            a.crawlers,
+		AttackTools:         a.attackTools,
+		RuleHits:            make(map[string]*RuleHitState, len(a.ruleHits)),
+		CategoryHits:        make(map[string]*CategoryHitState, len(a.categoryHits)),
+		ErrorsByUA:          a.errorsByUA,
+		URLsByStatus:        a.urlsByStatus,
+		SlowURLs:            a.slowURLs,
+		ErrorTimestampsByIP: a.errorTimestampsByIP,
+		MinuteBuckets:       make(map[int64]*MinuteBucketState, len(a.minuteBuckets)),
+		OpenSessions:        make(map[string]*SessionState, len(a.openSessions)),
+		SessionCount:        a.sessionCount,
+		SessionPages:        a.sessionPages,
+		SessionBounces:      a.sessionBounces,
+		SessionDurationSum:  a.sessionDurationSum,
+		SessionEntryPages:   a.sessionEntryPages,
+		SessionExitPages:    a.sessionExitPages,
+		SessionPaths:        a.sessionPaths,
+		Uniques:             a.uniques.state(),
+		HourlyUniques:       make(map[int64]*UniqueSketchesState, len(a.hourlyUniques)),
+		DailyUniques:        make(map[int64]*UniqueSketchesState, len(a.dailyUniques)),
+		NoReferer:           a.noReferer,
+		InternalReferers:    a.internalReferers,
+		ExternalReferers:    a.externalReferers,
+		RefererHosts:        a.refererHosts,
+		SearchEngines:       a.searchEngines,
+		SpamReferers:        a.spamReferers,
+		HotlinkRequests:     a.hotlinkRequests,
+		HotlinkBytes:        a.hotlinkBytes,
+		HotlinkHostRequests: a.hotlinkHostRequests,
+		HotlinkHostBytes:    a.hotlinkHostBytes,
+		MethodStatus:        a.methodStatus,
+		Protocols:           a.protocols,
+		HTTP10Clients:       a.http10Clients,
+		PostURLs:            a.postURLs,
+		RedirectCodes:       a.redirectCodes,
+		RedirectURLs:        a.redirectURLs,
+		RedirectMappings:    a.redirectMappings,
+		RedirectChains:      make(map[string]*RedirectChainState, len(a.redirectChains)),
+		RedirectChainPaths:  a.redirectChainPaths,
+		RedirectLoops:       a.redirectLoops,
+		UpstreamPHP:         a.upstreamPHP.state(),
+		UpstreamStatic:      a.upstreamStatic.state(),
+		UpstreamUnknown:     a.upstreamUnknown,
+		UpstreamScripts:     make(map[string]*UpstreamState, len(a.upstreamScripts)),
+		UpstreamRewrites:    a.upstreamRewrites,
+		LoginByIP:           make(map[string]*LoginState, len(a.loginByIP)),
+		LoginTotals:         a.loginTotals.state(),
+		LoginUniqueIPs:      a.loginUniqueIPs,
+		LoginDropped:        a.loginDropped,
+		XMLRPCRequests:      a.xmlrpcRequests,
+		XMLRPCPosts:         a.xmlrpcPosts,
+		XMLRPCLargePosts:    a.xmlrpcLargePosts,
+		XMLRPCBlocked:       a.xmlrpcBlocked,
+		XMLRPCIPs:           a.xmlrpcIPs,
+		XMLRPCUniqueIPs:     a.xmlrpcUniqueIPs,
+		AuthorRequests:      a.authorRequests,
+		AuthorRedirects:     a.authorRedirects,
+		AuthorIDs:           a.authorIDs,
+		RESTUserRequests:    a.restUserRequests,
+		RESTUserExposed:     a.restUserExposed,
+		UserEnumIPs:         a.userEnumIPs,
+		ProbeRequests:       a.probeRequests,
+		ProbeIPs:            a.probeIPs,
+		ProbeUniqueIPs:      a.probeUniqueIPs,
+		ProbedComponents:    make(map[string]*ComponentState, len(a.probedComponents)),
+		ComponentVersions:   a.componentVersions,
+		DistributedAttempts: a.distributedAttempts,
+		RateClients:         make(map[RequestClass]map[string]*RateTimelineState, len(a.rateClients)),
+		RateAbusiveIPs:      a.rateAbusiveIPs,
+		RateDropped:         a.rateDropped,
+		Exposures:           make(map[string]*ExposureState, len(a.exposures)),
+		ExposureIPs:         a.exposureIPs,
+		ExposureUniqueIPs:   a.exposureUniqueIPs,
+		ExposureRewritten:   a.exposureRewritten,
+		ClassStats:          make(map[RequestClass]*ClassState, len(a.classStats)),
+		StartTime:           a.startTime,
+		EndTime:             a.endTime,
+	}
+	for k, b := range a.minuteBuckets {
+		s.MinuteBuckets[k] = &MinuteBucketState{
+			Requests:       b.requests,
+			Errors:         b.errors,
+			ResponseTimeMs: b.responseTimeMs,
+			URLs:           keyCountStates(b.urls),
+			IPs:            keyCountStates(b.ips),
+		}
+	}
+	for key, sess := range a.openSessions {
+		s.OpenSessions[key] = &SessionState{
+			Start:     sess.start,
+			LastSeen:  sess.lastSeen,
+			Pages:     sess.pages,
+			EntryPage: sess.entryPage,
+			ExitPage:  sess.exitPage,
+			Path:      sess.path,
+		}
+	}
+	for id, acc := range a.ruleHits {
+		s.RuleHits[id] = &RuleHitState{Hits: acc.hits, IPs: acc.ips, TopIPs: acc.topIPs, Seen: acc.seen, Example: acc.example, Field: acc.field, ExampleSeen: acc.exampleSeen}
+	}
+	for category, acc := range a.categoryHits {
+		s.CategoryHits[category] = &CategoryHitState{Requests: acc.requests, IPs: acc.ips, UniqueIPs: acc.uniqueIPs, Seen: acc.seen}
+	}
+	for k, u := range a.hourlyUniques {
+		s.HourlyUniques[k] = u.state()
+	}
+	for k, u := range a.dailyUniques {
+		s.DailyUniques[k] = u.state()
+	}
+	for key, c := range a.unusualMethods {
+		s.UnusualMethods = append(s.UnusualMethods, UnusualMethodIP{IP: key.ip, Method: key.method, Count: c})
+	}
+	sort.Slice(s.UnusualMethods, func(i, j int) bool {
+		if s.UnusualMethods[i].Method != s.UnusualMethods[j].Method {
+			return s.UnusualMethods[i].Method < s.UnusualMethods[j].Method
+		}
+		return s.UnusualMethods[i].IP < s.UnusualMethods[j].IP
+	})
+	for key, chain := range a.redirectChains {
+		s.RedirectChains[key] = &RedirectChainState{URLs: chain.urls, LastSeen: chain.lastSeen, Loop: chain.loop}
+	}
+	for script, acc := range a.upstreamScripts {
+		st := acc.state()
+		s.UpstreamScripts[script] = &st
+	}
+	for ip, acc := range a.loginByIP {
+		st := acc.state()
+		s.LoginByIP[ip] = &st
+	}
+	for key, acc := range a.probedComponents {
+		s.ProbedComponents[key] = &ComponentState{Requests: acc.requests, Found: acc.found, FoundPaths: acc.foundPaths}
+	}
+	for key, w := range a.loginWindows {
+		st := LoginWindowState{Endpoint: key.endpoint, Start: key.start, Clients: make(map[string]*LoginClientState, len(w.clients))}
+		for client, c := range w.clients {
+			st.Clients[client] = &LoginClientState{Attempts: c.attempts, Successes: c.successes}
+		}
+		s.LoginWindows = append(s.LoginWindows, st)
+	}
+	sort.Slice(s.LoginWindows, func(i, j int) bool {
+		if s.LoginWindows[i].Endpoint != s.LoginWindows[j].Endpoint {
+			return s.LoginWindows[i].Endpoint < s.LoginWindows[j].Endpoint
+		}
+		return s.LoginWindows[i].Start < s.LoginWindows[j].Start
+	})
+	for class, clients := range a.rateClients {
+		s.RateClients[class] = make(map[string]*RateTimelineState, len(clients))
+		for ip, t := range clients {
+			st := t.state()
+			s.RateClients[class][ip] = &st
+		}
+	}
+	for path, acc := range a.exposures {
+		st := acc.state()
+		s.Exposures[path] = &st
+	}
+	for class, acc := range a.classStats {
+		s.ClassStats[class] = &ClassState{
+			Requests:           acc.requests,
+			ClientErrors:       acc.clientErrors,
+			ServerErrors:       acc.serverErrors,
+			SlowRequests:       acc.slowRequests,
+			ResponseTimeMs:     acc.responseTimeMs,
+			ResponseTimeSample: acc.responseTimeSample,
+			IPs:                acc.ips,
+		}
+	}
+	return s
+}
+
+// NewAnalyzerFromSnapshot restores an Analyzer from s. The restored analyzer
+// can process further entries, be merged with others, or produce a Result.
+func NewAnalyzerFromSnapshot(cfg *config.Config, s *Snapshot) (*Analyzer, error) {
+	if s.Version != snapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d (want %d)", s.Version, snapshotVersion)
+	}
+	a := NewAnalyzer(cfg)
+	// Sketches of different sizes cannot be merged meaningfully (and a
+	// bounded Top-N cannot become exact again), so the configuration that
+	// restores a snapshot must match the one that wrote it.
+	if s.HeavyHitterCapacity != a.ipCounts.Capacity() {
+		return nil, fmt.Errorf("snapshot heavy_hitters.capacity %d does not match config (%d)", s.HeavyHitterCapacity, a.ipCounts.Capacity())
+	}
+	if s.UniquePrecision != a.uniques.ips.Precision() {
+		return nil, fmt.Errorf("snapshot uniques.precision %d does not match config (%d)", s.UniquePrecision, a.uniques.ips.Precision())
+	}
+	capacity := cfg.HeavyHitters.Capacity
+
+	a.position = s.Position
+	a.totalRequests = s.TotalRequests
+	a.errorRequests = s.ErrorRequests
+	a.responseTimeMs = s.ResponseTimeMs
+	a.responseTimeMax = s.ResponseTimeMax
+	a.responseTimeCount = s.ResponseTimeCount
+	a.responseTimeSample = sampleFromState(s.ResponseTimeSample)
+	a.slowRequestCount = s.SlowRequestCount
+	a.ipCounts = topKOrNew(s.IPCounts, capacity)
+	a.errorURLs = topKOrNew(s.ErrorURLs, capacity)
+	a.hourlyPattern = s.HourlyPattern
+	a.hourlyClientErrors = s.HourlyClientErrors
+	a.hourlyServerErrors = s.HourlyServerErrors
+	copyCounts(a.statusCodes, s.StatusCodes)
+	a.userAgents = topKOrNew(s.UserAgents, capacity)
+	for ip, attacks := range s.AttacksByIP {
+		a.attacksByIP[ip] = attacks
+	}
+	copyCounts(a.crawlers, s.Crawlers)
+	copyCounts(a.attackTools, s.AttackTools)
+	for id, st := range s.RuleHits {
+		a.ruleHits[id] = &ruleAccumulator{
+			hits:        st.Hits,
+			ips:         hllOrNew(st.IPs, cfg.Uniques.Precision),
+			topIPs:      st.TopIPs,
+			seen:        st.Seen,
+			example:     st.Example,
+			field:       st.Field,
+			exampleSeen: st.ExampleSeen,
+		}
+	}
+	for category, st := range s.CategoryHits {
+		a.categoryHits[category] = &categoryAccumulator{
+			requests:  st.Requests,
+			ips:       topKOrNew(st.IPs, capacity),
+			uniqueIPs: hllOrNew(st.UniqueIPs, cfg.Uniques.Precision),
+			seen:      st.Seen,
+		}
+	}
+	a.errorsByUA = topKOrNew(s.ErrorsByUA, capacity)
+	for code, t := range s.URLsByStatus {
+		a.urlsByStatus[code] = topKOrNew(t, capacity)
+	}
+	a.slowURLs = topKOrNew(s.SlowURLs, capacity)
+	for ip, ts := range s.ErrorTimestampsByIP {
+		a.errorTimestampsByIP[ip] = ts
+	}
+	for k, b := range s.MinuteBuckets {
+		a.minuteBuckets[k] = &minuteBucket{
+			requests:       b.Requests,
+			errors:         b.Errors,
+			responseTimeMs: b.ResponseTimeMs,
+			urls:           keyCountsFromState(b.URLs),
+			ips:            keyCountsFromState(b.IPs),
+		}
+	}
+	for key, sess := range s.OpenSessions {
+		a.openSessions[key] = &openSession{
+			start:     sess.Start,
+			lastSeen:  sess.LastSeen,
+			pages:     sess.Pages,
+			entryPage: sess.EntryPage,
+			exitPage:  sess.ExitPage,
+			path:      sess.Path,
+		}
+	}
+	a.sessionCount = s.SessionCount
+	a.sessionPages = s.SessionPages
+	a.sessionBounces = s.SessionBounces
+	a.sessionDurationSum = s.SessionDurationSum
+	copyCounts(a.sessionEntryPages, s.SessionEntryPages)
+	copyCounts(a.sessionExitPages, s.SessionExitPages)
+	copyCounts(a.sessionPaths, s.SessionPaths)
+	precision := cfg.Uniques.Precision
+	a.uniques = s.Uniques.sketches(precision)
+	for k, u := range s.HourlyUniques {
+		a.hourlyUniques[k] = u.sketches(precision)
+	}
+	for k, u := range s.DailyUniques {
+		a.dailyUniques[k] = u.sketches(precision)
+	}
+	a.noReferer = s.NoReferer
+	a.internalReferers = s.InternalReferers
+	a.externalReferers = s.ExternalReferers
+	a.refererHosts = topKOrNew(s.RefererHosts, capacity)
+	copyCounts(a.searchEngines, s.SearchEngines)
+	a.spamReferers = topKOrNew(s.SpamReferers, capacity)
+	a.hotlinkRequests = s.HotlinkRequests
+	a.hotlinkBytes = s.HotlinkBytes
+	a.hotlinkHostRequests = topKOrNew(s.HotlinkHostRequests, capacity)
+	a.hotlinkHostBytes = topKOrNew(s.HotlinkHostBytes, capacity)
+	for method, byStatus := range s.MethodStatus {
+		a.methodStatus[method] = mapOrNew(byStatus)
+	}
+	copyCounts(a.protocols, s.Protocols)
+	a.http10Clients = topKOrNew(s.HTTP10Clients, capacity)
+	a.postURLs = topKOrNew(s.PostURLs, capacity)
+	for _, u := range s.UnusualMethods {
+		a.unusualMethods[methodIP{method: u.Method, ip: u.IP}] += u.Count
+	}
+	copyCounts(a.redirectCodes, s.RedirectCodes)
+	a.redirectURLs = topKOrNew(s.RedirectURLs, capacity)
+	a.redirectMappings = topKOrNew(s.RedirectMappings, capacity)
+	for key, chain := range s.RedirectChains {
+		a.redirectChains[key] = &openRedirectChain{urls: chain.URLs, lastSeen: chain.LastSeen, loop: chain.Loop}
+	}
+	a.redirectChainPaths = topKOrNew(s.RedirectChainPaths, capacity)
+	a.redirectLoops = topKOrNew(s.RedirectLoops, capacity)
+	a.upstreamPHP = s.UpstreamPHP.accumulator()
+	a.upstreamStatic = s.UpstreamStatic.accumulator()
+	a.upstreamUnknown = s.UpstreamUnknown
+	for script, st := range s.UpstreamScripts {
+		acc := st.accumulator()
+		a.upstreamScripts[script] = &acc
+	}
+	a.upstreamRewrites = topKOrNew(s.UpstreamRewrites, capacity)
+	for ip, st := range s.LoginByIP {
+		acc := st.accumulator()
+		a.loginByIP[ip] = &acc
+	}
+	a.loginTotals = s.LoginTotals.accumulator()
+	a.loginUniqueIPs = hllOrNew(s.LoginUniqueIPs, precision)
+	a.loginDropped = s.LoginDropped
+	a.xmlrpcRequests = s.XMLRPCRequests
+	a.xmlrpcPosts = s.XMLRPCPosts
+	a.xmlrpcLargePosts = s.XMLRPCLargePosts
+	a.xmlrpcBlocked = s.XMLRPCBlocked
+	a.xmlrpcIPs = topKOrNew(s.XMLRPCIPs, capacity)
+	a.xmlrpcUniqueIPs = hllOrNew(s.XMLRPCUniqueIPs, precision)
+	a.authorRequests = s.AuthorRequests
+	a.authorRedirects = s.AuthorRedirects
+	copyCounts(a.authorIDs, s.AuthorIDs)
+	a.restUserRequests = s.RESTUserRequests
+	a.restUserExposed = s.RESTUserExposed
+	a.userEnumIPs = topKOrNew(s.UserEnumIPs, capacity)
+	a.probeRequests = s.ProbeRequests
+	a.probeIPs = topKOrNew(s.ProbeIPs, capacity)
+	a.probeUniqueIPs = hllOrNew(s.ProbeUniqueIPs, precision)
+	for key, st := range s.ProbedComponents {
+		a.probedComponents[key] = &componentAccumulator{requests: st.Requests, found: st.Found, foundPaths: mapOrNew(st.FoundPaths)}
+	}
+	for key, versions := range s.ComponentVersions {
+		a.componentVersions[key] = mapOrNew(versions)
+	}
+	a.distributedAttempts = s.DistributedAttempts
+	for _, st := range s.LoginWindows {
+		w := &loginWindow{clients: make(map[string]*loginClient, len(st.Clients))}
+		for client, c := range st.Clients {
+			w.clients[client] = &loginClient{attempts: c.Attempts, successes: c.Successes}
+		}
+		a.loginWindows[loginWindowKey{endpoint: st.Endpoint, start: st.Start}] = w
+	}
+	for class, clients := range s.RateClients {
+		a.rateClients[class] = make(map[string]*rateTimeline, len(clients))
+		for ip, st := range clients {
+			t := st.timeline()
+			a.rateClients[class][ip] = &t
+		}
+	}
+	for ip := range s.RateAbusiveIPs {
+		a.rateAbusiveIPs[ip] = true
+	}
+	copyCounts(a.rateDropped, s.RateDropped)
+	for path, st := range s.Exposures {
+		acc := st.accumulator()
+		a.exposures[path] = &acc
+	}
+	a.exposureIPs = topKOrNew(s.ExposureIPs, capacity)
+	a.exposureUniqueIPs = hllOrNew(s.ExposureUniqueIPs, precision)
+	a.exposureRewritten = s.ExposureRewritten
+	for class, st := range s.ClassStats {
+		ips := st.IPs
+		if ips == nil {
+			ips = sketch.NewHyperLogLog(precision)
+		}
+		a.classStats[class] = &classAccumulator{
+			requests:           st.Requests,
+			clientErrors:       st.ClientErrors,
+			serverErrors:       st.ServerErrors,
+			slowRequests:       st.SlowRequests,
+			responseTimeMs:     st.ResponseTimeMs,
+			responseTimeSample: sampleFromState(st.ResponseTimeSample),
+			ips:                ips,
+		}
+	}
+	a.startTime = s.StartTime
+	a.endTime = s.EndTime
+	return a, nil
+}
+
+// WriteSnapshot saves s as JSON to path.
+func WriteSnapshot(path string, s *Snapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	return nil
+}
+
+// ReadSnapshot loads a snapshot written by WriteSnapshot.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot: %v", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot %s: %v", path, err)
+	}
+	return &s, nil
+}
+
+func (u *uniqueSketches) state() *UniqueSketchesState {
+	return &UniqueSketchesState{IPs: u.ips, Visitors: u.visitors, URLs: u.urls}
+}
+
+func (s *UniqueSketchesState) sketches(precision int) *uniqueSketches {
+	u := newUniqueSketches(precision)
+	if s == nil {
+		return u
+	}
+	if s.IPs != nil {
+		u.ips = s.IPs
+	}
+	if s.Visitors != nil {
+		u.visitors = s.Visitors
+	}
+	if s.URLs != nil {
+		u.urls = s.URLs
+	}
+	return u
+}
+
+func (u *upstreamAccumulator) state() UpstreamState {
+	return UpstreamState{Requests: u.requests, Errors: u.errors, ResponseTimeMs: u.responseTimeMs, ResponseTimeMax: u.responseTimeMax}
+}
+
+func (s UpstreamState) accumulator() upstreamAccumulator {
+	return upstreamAccumulator{requests: s.Requests, errors: s.Errors, responseTimeMs: s.ResponseTimeMs, responseTimeMax: s.ResponseTimeMax}
+}
+
+func (l *loginAccumulator) state() LoginState {
+	return LoginState{Attempts: l.attempts, Successes: l.successes, Failures: l.failures, Blocked: l.blocked, Seen: l.seen}
+}
+
+func (s LoginState) accumulator() loginAccumulator {
+	return loginAccumulator{attempts: s.Attempts, successes: s.Successes, failures: s.Failures, blocked: s.Blocked, seen: s.Seen}
+}
+
+func (t *rateTimeline) state() RateTimelineState {
+	seconds := make([][2]int64, len(t.seconds))
+	for i, sec := range t.seconds {
+		seconds[i] = [2]int64{sec.unix, int64(sec.count)}
+	}
+	return RateTimelineState{Requests: t.requests, Seconds: seconds}
+}
+
+func (s RateTimelineState) timeline() rateTimeline {
+	t := rateTimeline{requests: s.Requests, seconds: make([]rateSecond, len(s.Seconds))}
+	for i, sec := range s.Seconds {
+		t.seconds[i] = rateSecond{unix: sec[0], count: int(sec[1])}
+	}
+	return t
+}
+
+func (e *exposureAccumulator) state() ExposureState {
+	return ExposureState{Requests: e.requests, Leaks: e.leaks, MaxBytes: e.maxBytes, RuleID: e.ruleID, LeakIPs: e.leakIPs, Seen: e.seen, LeakSeen: e.leakSeen}
+}
+
+func (s ExposureState) accumulator() exposureAccumulator {
+	return exposureAccumulator{requests: s.Requests, leaks: s.Leaks, maxBytes: s.MaxBytes, ruleID: s.RuleID, leakIPs: mapOrNew(s.LeakIPs), seen: s.Seen, leakSeen: s.LeakSeen}
+}
+
+func topKOrNew(t *sketch.TopK, capacity int) *sketch.TopK {
+	if t == nil {
+		return sketch.NewTopK(capacity)
+	}
+	return t
+}
+
+func hllOrNew(h *sketch.HyperLogLog, precision int) *sketch.HyperLogLog {
+	if h == nil {
+		return sketch.NewHyperLogLog(precision)
+	}
+	return h
+}
+
+func keyCountStates(m map[string]keyCount) map[string]KeyCountState {
+	result := make(map[string]KeyCountState, len(m))
+	for k, c := range m {
+		result[k] = KeyCountState{Count: c.count, First: c.first}
+	}
+	return result
+}
+
+func keyCountsFromState(m map[string]KeyCountState) map[string]keyCount {
+	result := make(map[string]keyCount, len(m))
+	for k, c := range m {
+		result[k] = keyCount{count: c.Count, first: c.First}
+	}
+	return result
+}
+
+// sampleFromState restores a response-time sample, re-establishing its heap
+// order in case the snapshot was edited by hand.
+func sampleFromState(s []SampledTime) responseSample {
+	sample := responseSample(s)
+	heap.Init(&sample)
+	return sample
+}
+
+func mapOrNew[K comparable](m map[K]int) map[K]int {
+	if m == nil {
+		return make(map[K]int)
+	}
+	return m
+}
+
+func copyCounts[K comparable](dst, src map[K]int) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
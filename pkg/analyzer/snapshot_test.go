@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	cfg := loadTestConfig(t)
+	want := analyzeFile(t, cfg, sampleLogPath, 1)
+
+	a := NewAnalyzer(cfg)
+	if err := a.ProcessFile(sampleLogPath); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	data, err := json.Marshal(a.Snapshot())
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	restored, err := NewAnalyzerFromSnapshot(cfg, &s)
+	if err != nil {
+		t.Fatalf("NewAnalyzerFromSnapshot() error = %v", err)
+	}
+	assertSameResult(t, restored.Result(), want)
+}
+
+func TestMergeHalvesMatchesWholeFile(t *testing.T) {
+	cfg := loadTestConfig(t)
+	want := analyzeFile(t, cfg, sampleLogPath, 1)
+
+	data, err := os.ReadFile(sampleLogPath)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.log"), filepath.Join(dir, "second.log")
+	half := len(lines) / 2
+	if err := os.WriteFile(first, []byte(strings.Join(lines[:half], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte(strings.Join(lines[half:], "")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := NewAnalyzer(cfg), NewAnalyzer(cfg)
+	if err := a.ProcessFile(first); err != nil {
+		t.Fatalf("ProcessFile(first) error = %v", err)
+	}
+	if err := b.ProcessFile(second); err != nil {
+		t.Fatalf("ProcessFile(second) error = %v", err)
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	assertSameResult(t, a.Result(), want)
+}
+
+func TestSnapshotRejectsMismatchedSketches(t *testing.T) {
+	cfg := loadTestConfig(t)
+	a := NewAnalyzer(cfg)
+	if err := a.ProcessFile(sampleLogPath); err != nil {
+		t.Fatalf("ProcessFile() error = %v", err)
+	}
+	s := a.Snapshot()
+
+	tests := []struct {
+		name   string
+		modify func(*Snapshot)
+	}{
+		{"older version", func(s *Snapshot) { s.Version = snapshotVersion - 1 }},
+		{"heavy hitter capacity", func(s *Snapshot) { s.HeavyHitterCapacity++ }},
+		{"unique precision", func(s *Snapshot) { s.UniquePrecision++ }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified := *s
+			tt.modify(&modified)
+			if _, err := NewAnalyzerFromSnapshot(cfg, &modified); err == nil {
+				t.Error("NewAnalyzerFromSnapshot() error = nil, want mismatch error")
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package analyzer
 import (
 	"fmt"
 	"sort"
+
+	"kinsta-log-analyzer/pkg/sketch"
 )
 
 func (a *Analyzer) generateSummary() Summary {
@@ -13,15 +15,18 @@ func (a *Analyzer) generateSummary() Summary {
 
 	avgResponseTime := 0.0
 	if a.responseTimeCount > 0 {
-		avgResponseTime = a.responseTimeSum / float64(a.responseTimeCount)
+		avgResponseTime = avgSeconds(a.responseTimeMs, a.responseTimeCount)
 	}
 
 	return Summary{
 		StartTime:       a.startTime,
 		EndTime:         a.endTime,
 		TotalRequests:   a.totalRequests,
+		StatusClasses:   a.generateStatusClasses(),
 		ErrorRate:       errorRate,
 		AvgResponseTime: avgResponseTime,
+		Uniques:         a.uniques.counts(),
+		ByClass:         a.generateClassSummaries(),
 	}
 }
 
@@ -38,47 +43,41 @@ func (a *Analyzer) generateHTTPErrors() HTTPErrors {
 	}
 
 	// Top error URLs
-	var urlErrors []URLError
-	for url, count := range a.errorURLs {
-		urlErrors = append(urlErrors, URLError{
-			URL:   url,
-			Count: count,
-		})
-	}
-
-	sort.Slice(urlErrors, func(i, j int) bool {
-		return urlErrors[i].Count > urlErrors[j].Count
-	})
-
-	// Limit to top N
-	if len(urlErrors) > a.config.Output.TopErrorsCount {
-		urlErrors = urlErrors[:a.config.Output.TopErrorsCount]
-	}
+	urlErrors := urlErrorsFromTopK(a.errorURLs, a.config.Output.TopErrorsCount)
 
 	return HTTPErrors{
 		ClientErrors:      clientErrors,
 		ServerErrors:      serverErrors,
 		TopErrorURLs:      urlErrors,
-		ErrorURLsByStatus: a.generateErrorURLsByStatus(),
+		URLsByStatus:      a.generateURLsByStatus(),
 	}
 }
 
 func (a *Analyzer) generateSecurityAnalysis() SecurityAnalysis {
 	sqlAttempts := 0
 	xssAttempts := 0
+	traversalAttempts := 0
+	lfiAttempts := 0
+	rfiAttempts := 0
 	var suspiciousIPs []SuspiciousIP
 
 	for ip, attacks := range a.attacksByIP {
 		sqlAttempts += attacks.SQLAttempts
 		xssAttempts += attacks.XSSAttempts
+		traversalAttempts += attacks.TraversalAttempts
+		lfiAttempts += attacks.LFIAttempts
+		rfiAttempts += attacks.RFIAttempts
 
 		// Consider IP suspicious if it has any attack attempts
-		if attacks.SQLAttempts > 0 || attacks.XSSAttempts > 0 {
-			score := attacks.SQLAttempts*2 + attacks.XSSAttempts*2 // Weight can be adjusted
+		if attacks.attempts() > 0 {
+			score := attacks.attempts() * 2 // Weight can be adjusted
 			suspiciousIPs = append(suspiciousIPs, SuspiciousIP{
-				IP:            ip,
-				SQLAttempts:   attacks.SQLAttempts,
-				XSSAttempts:   attacks.XSSAttempts,
+				IP:                ip,
+				SQLAttempts:       attacks.SQLAttempts,
+				XSSAttempts:       attacks.XSSAttempts,
+				TraversalAttempts: attacks.TraversalAttempts,
+				LFIAttempts:       attacks.LFIAttempts,
+				RFIAttempts:       attacks.RFIAttempts,
 				TotalRequests: attacks.TotalRequests,
 				AttackScore:   score,
 			})
@@ -87,20 +86,31 @@ func (a *Analyzer) generateSecurityAnalysis() SecurityAnalysis {
 
 	// Sort suspicious IPs by attack score
 	sort.Slice(suspiciousIPs, func(i, j int) bool {
-		return suspiciousIPs[i].AttackScore > suspiciousIPs[j].AttackScore
+		if suspiciousIPs[i].AttackScore != suspiciousIPs[j].AttackScore {
+			return suspiciousIPs[i].AttackScore > suspiciousIPs[j].AttackScore
+		}
+		return suspiciousIPs[i].IP < suspiciousIPs[j].IP
 	})
 
 	errorProneIPs := a.generateErrorProneIPs()
 	burstIPs := a.generateBurstIPs()
+	ruleHits := a.generateRuleHits()
 
 	return SecurityAnalysis{
 		SQLInjectionAttempts: sqlAttempts,
 		XSSAttempts:         xssAttempts,
+		PathTraversalAttempts: traversalAttempts,
+		LFIAttempts:         lfiAttempts,
+		RFIAttempts:         rfiAttempts,
 		SuspiciousIPs:       suspiciousIPs,
 		AttacksByIP:         a.attacksByIP,
 		ErrorProneIPs:       errorProneIPs,
 		BurstIPs:            burstIPs,
 		ErrorSuspiciousIPs:  mergeErrorSuspiciousIPs(errorProneIPs, burstIPs),
+		Categories:          a.generateCategoryHits(ruleHits),
+		Rules:               ruleHits,
+		CVERules:            a.generateCVERules(ruleHits),
+		CRSImport:           a.config.CRSImport(),
 	}
 }
 
@@ -149,8 +159,11 @@ func mergeErrorSuspiciousIPs(errorProneIPs []IPErrorRate, burstIPs []BurstIP) []
 		if result[i].ErrorRate != result[j].ErrorRate {
 			return result[i].ErrorRate > result[j].ErrorRate
 		}
-		// 最後に最大バースト
-		return result[i].MaxBurst > result[j].MaxBurst
+		// 次に最大バースト
+		if result[i].MaxBurst != result[j].MaxBurst {
+			return result[i].MaxBurst > result[j].MaxBurst
+		}
+		return result[i].IP < result[j].IP
 	})
 
 	if len(result) > 10 {
@@ -162,21 +175,14 @@ func mergeErrorSuspiciousIPs(errorProneIPs []IPErrorRate, burstIPs []BurstIP) []
 func (a *Analyzer) generateStatistics() Statistics {
 	// Top IPs
 	var ipCounts []IPCount
-	for ip, count := range a.ipCounts {
+	for _, item := range a.ipCounts.Top(a.config.Output.TopIPsCount) {
 		ipCounts = append(ipCounts, IPCount{
-			IP:    ip,
-			Count: count,
+			IP:         item.Key,
+			Count:      item.Count,
+			ErrorBound: item.Error,
 		})
 	}
 
-	sort.Slice(ipCounts, func(i, j int) bool {
-		return ipCounts[i].Count > ipCounts[j].Count
-	})
-
-	if len(ipCounts) > a.config.Output.TopIPsCount {
-		ipCounts = ipCounts[:a.config.Output.TopIPsCount]
-	}
-
 	// Response time statistics
 	responseTimeStats := a.calculateResponseTimeStats()
 
@@ -188,28 +194,64 @@ func (a *Analyzer) generateStatistics() Statistics {
 		ResponseTimeStats:  responseTimeStats,
 		StatusCodes:        a.statusCodes,
 		SlowURLs:           a.generateSlowURLs(),
+		TopNAccuracy:       a.generateTopNAccuracy(),
+	}
+}
+
+// generateTopNAccuracy reports the worst-case overcount across all Top-N
+// counters so the report can say whether the tables are exact.
+func (a *Analyzer) generateTopNAccuracy() TopNAccuracy {
+	counters := []*sketch.TopK{a.ipCounts, a.errorURLs, a.userAgents, a.errorsByUA, a.slowURLs}
+	for _, t := range a.urlsByStatus {
+		counters = append(counters, t)
+	}
+	maxErr := 0
+	for _, t := range counters {
+		if e := t.MaxError(); e > maxErr {
+			maxErr = e
+		}
+	}
+	return TopNAccuracy{
+		Capacity: a.config.HeavyHitters.Capacity,
+		MaxError: maxErr,
+	}
+}
+
+// urlErrorsFromTopK converts the top n items of a counter to URLErrors.
+func urlErrorsFromTopK(t *sketch.TopK, n int) []URLError {
+	var result []URLError
+	for _, item := range t.Top(n) {
+		result = append(result, URLError{URL: item.Key, Count: item.Count, ErrorBound: item.Error})
 	}
+	return result
 }
 
 func (a *Analyzer) generateUserAgentAnalysis() UserAgentAnalysis {
 	// Find suspicious user agents (not crawlers or attack tools)
 	var suspiciousUAs []UACount
 	
-	for ua, count := range a.userAgents {
+	// With a bounded counter only monitored UAs are considered; rare UAs may
+	// have been evicted by heavier ones.
+	for _, item := range a.userAgents.Items() {
+		ua, count := item.Key, item.Count
 		// Skip known crawlers and attack tools
 		if !a.config.IsCrawler(ua) && !a.config.IsAttackTool(ua) {
 			// Consider suspicious if very few requests or unusual patterns
 			if count < 5 || len(ua) < 10 || len(ua) > 200 {
 				suspiciousUAs = append(suspiciousUAs, UACount{
-					UserAgent: ua,
-					Count:     count,
+					UserAgent:  ua,
+					Count:      count,
+					ErrorBound: item.Error,
 				})
 			}
 		}
 	}
 
 	sort.Slice(suspiciousUAs, func(i, j int) bool {
-		return suspiciousUAs[i].Count > suspiciousUAs[j].Count
+		if suspiciousUAs[i].Count != suspiciousUAs[j].Count {
+			return suspiciousUAs[i].Count > suspiciousUAs[j].Count
+		}
+		return suspiciousUAs[i].UserAgent < suspiciousUAs[j].UserAgent
 	})
 
 	// Limit results
@@ -231,14 +273,20 @@ func (a *Analyzer) generateUserAgentAnalysis() UserAgentAnalysis {
 func (a *Analyzer) generateErrorProneUAs() []UAErrorRate {
 	minReq := a.config.Thresholds.MinRequestsForErrorRate
 	var result []UAErrorRate
-	for ua, total := range a.userAgents {
+	for _, item := range a.userAgents.Items() {
+		ua, total := item.Key, item.Count
 		if total < minReq {
 			continue
 		}
-		errs := a.errorsByUA[ua]
+		errItem, _ := a.errorsByUA.Get(ua)
+		errs := errItem.Count
 		if errs == 0 {
 			continue
 		}
+		if errs > total {
+			// Both counters may overestimate independently.
+			errs = total
+		}
 		result = append(result, UAErrorRate{
 			UserAgent:     ua,
 			TotalRequests: total,
@@ -247,7 +295,10 @@ func (a *Analyzer) generateErrorProneUAs() []UAErrorRate {
 		})
 	}
 	sort.Slice(result, func(i, j int) bool {
-		return result[i].ErrorRate > result[j].ErrorRate
+		if result[i].ErrorRate != result[j].ErrorRate {
+			return result[i].ErrorRate > result[j].ErrorRate
+		}
+		return result[i].UserAgent < result[j].UserAgent
 	})
 	if len(result) > 10 {
 		result = result[:10]
@@ -275,7 +326,10 @@ func (a *Analyzer) generateErrorProneIPs() []IPErrorRate {
 		})
 	}
 	sort.Slice(result, func(i, j int) bool {
-		return result[i].ErrorRate > result[j].ErrorRate
+		if result[i].ErrorRate != result[j].ErrorRate {
+			return result[i].ErrorRate > result[j].ErrorRate
+		}
+		return result[i].IP < result[j].IP
 	})
 	if len(result) > 10 {
 		result = result[:10]
@@ -283,31 +337,41 @@ func (a *Analyzer) generateErrorProneIPs() []IPErrorRate {
 	return result
 }
 
-// generateErrorURLsByStatus returns Top 10 URLs per target status code (404, 500, 502, 503, 504).
+// generateURLsByStatus returns Top 10 URLs per tracked status code
+// (Output.StatusURLCodes, or every observed code when unset).
 // Codes with no recorded URLs are omitted from the returned map.
-func (a *Analyzer) generateErrorURLsByStatus() map[int][]URLError {
-	targetCodes := []int{404, 500, 502, 503, 504}
+func (a *Analyzer) generateURLsByStatus() map[int][]URLError {
 	result := make(map[int][]URLError)
-	for _, code := range targetCodes {
-		urls, ok := a.errorURLsByStatus[code]
-		if !ok || len(urls) == 0 {
+	for code, urls := range a.urlsByStatus {
+		if urls.Len() == 0 {
 			continue
 		}
-		var list []URLError
-		for u, c := range urls {
-			list = append(list, URLError{URL: u, Count: c})
-		}
-		sort.Slice(list, func(i, j int) bool {
-			return list[i].Count > list[j].Count
-		})
-		if len(list) > 10 {
-			list = list[:10]
-		}
-		result[code] = list
+		result[code] = urlErrorsFromTopK(urls, 10)
 	}
 	return result
 }
 
+func (a *Analyzer) generateStatusClasses() StatusClassCounts {
+	var counts StatusClassCounts
+	for status, c := range a.statusCodes {
+		switch status / 100 {
+		case 1:
+			counts.Informational += c
+		case 2:
+			counts.Success += c
+		case 3:
+			counts.Redirection += c
+		case 4:
+			counts.ClientError += c
+		case 5:
+			counts.ServerError += c
+		default:
+			counts.Other += c
+		}
+	}
+	return counts
+}
+
 // generateBurstIPs scans each IP's error timestamps with a sliding window.
 // For every error, count errors within the next BurstWindowSeconds; if it exceeds
 // BurstThreshold, count one burst. Returns Top 10 IPs by burst count.
@@ -364,7 +428,10 @@ func (a *Analyzer) generateBurstIPs() []BurstIP {
 		if result[i].MaxBurst != result[j].MaxBurst {
 			return result[i].MaxBurst > result[j].MaxBurst
 		}
-		return result[i].BurstCount > result[j].BurstCount
+		if result[i].BurstCount != result[j].BurstCount {
+			return result[i].BurstCount > result[j].BurstCount
+		}
+		return result[i].IP < result[j].IP
 	})
 	if len(result) > 10 {
 		result = result[:10]
@@ -374,17 +441,7 @@ func (a *Analyzer) generateBurstIPs() []BurstIP {
 
 // generateSlowURLs returns Top 10 URLs by slow-request count.
 func (a *Analyzer) generateSlowURLs() []URLError {
-	var result []URLError
-	for u, c := range a.slowURLs {
-		result = append(result, URLError{URL: u, Count: c})
-	}
-	sort.Slice(result, func(i, j int) bool {
-		return result[i].Count > result[j].Count
-	})
-	if len(result) > 10 {
-		result = result[:10]
-	}
-	return result
+	return urlErrorsFromTopK(a.slowURLs, 10)
 }
 
 func (a *Analyzer) calculateResponseTimeStats() ResponseTimeStats {
@@ -393,28 +450,30 @@ func (a *Analyzer) calculateResponseTimeStats() ResponseTimeStats {
 	}
 
 	// Calculate average from pre-computed sum
-	average := a.responseTimeSum / float64(a.responseTimeCount)
-
-	// Calculate 95th percentile from sample
-	percentile95 := 0.0
-	if len(a.responseTimeSample) > 0 {
-		// Sort the sample for percentile calculation
-		sortedSample := make([]float64, len(a.responseTimeSample))
-		copy(sortedSample, a.responseTimeSample)
-		sort.Float64s(sortedSample)
-
-		// Calculate 95th percentile from sample
-		p95Index := int(0.95 * float64(len(sortedSample)))
-		if p95Index >= len(sortedSample) {
-			p95Index = len(sortedSample) - 1
-		}
-		percentile95 = sortedSample[p95Index]
-	}
+	average := avgSeconds(a.responseTimeMs, a.responseTimeCount)
 
 	return ResponseTimeStats{
 		Average:      average,
 		Maximum:      a.responseTimeMax,
-		Percentile95: percentile95,
+		Percentile50: percentile(a.responseTimeSample.values(), 0.50),
+		Percentile95: percentile(a.responseTimeSample.values(), 0.95),
+		Percentile99: percentile(a.responseTimeSample.values(), 0.99),
 		SlowRequests: a.slowRequestCount,
 	}
+}
+
+// percentile returns the p-quantile (0..1) of sample without modifying it.
+func percentile(sample []float64, p float64) float64 {
+	if len(sample) == 0 {
+		return 0
+	}
+	sortedSample := make([]float64, len(sample))
+	copy(sortedSample, sample)
+	sort.Float64s(sortedSample)
+
+	index := int(p * float64(len(sortedSample)))
+	if index >= len(sortedSample) {
+		index = len(sortedSample) - 1
+	}
+	return sortedSample[index]
 }
\ No newline at end of file
@@ -0,0 +1,63 @@
+package analyzer
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+func TestURLsByStatus(t *testing.T) {
+	requests := []struct {
+		uri    string
+		status int
+	}{
+		{"/", 200},
+		{"/", 200},
+		{"/about/", 200},
+		{"/old", 301},
+		{"/missing", 404},
+		{"/missing", 404},
+		{"/gone", 404},
+		{"/api", 502},
+	}
+	tests := []struct {
+		name      string
+		codes     []int
+		wantCodes []int
+	}{
+		{"empty list tracks every code", nil, []int{200, 301, 404, 502}},
+		{"listed codes only", []int{404, 502, 503}, []int{404, 502}},
+		{"success codes can be listed", []int{200}, []int{200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := loadTestConfig(t)
+			cfg.Output.StatusURLCodes = tt.codes
+			a := NewAnalyzer(cfg)
+			for _, r := range requests {
+				a.processEntry(&parser.LogEntry{ClientIP: "192.0.2.1", Method: "GET", URI: r.uri, StatusCode: r.status})
+			}
+
+			got := a.generateURLsByStatus()
+			var codes []int
+			for code := range got {
+				codes = append(codes, code)
+			}
+			sort.Ints(codes)
+			if !reflect.DeepEqual(codes, tt.wantCodes) {
+				t.Fatalf("codes = %v, want %v", codes, tt.wantCodes)
+			}
+			if urls, ok := got[404]; ok {
+				want := []URLError{{URL: "/missing", Count: 2}, {URL: "/gone", Count: 1}}
+				if !reflect.DeepEqual(urls, want) {
+					t.Errorf("404 URLs = %+v, want %+v", urls, want)
+				}
+			}
+			if urls, ok := got[200]; ok && (len(urls) != 2 || urls[0] != (URLError{URL: "/", Count: 2})) {
+				t.Errorf("200 URLs = %+v, want / twice first", urls)
+			}
+		})
+	}
+}
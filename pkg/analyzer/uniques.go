@@ -0,0 +1,108 @@
+package analyzer
+
+import (
+	"sort"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/sketch"
+	"kinsta-log-analyzer/pkg/utils"
+)
+
+// uniqueSketches holds one HyperLogLog per counted dimension.
+type uniqueSketches struct {
+	ips      *sketch.HyperLogLog
+	visitors *sketch.HyperLogLog // IP + UA
+	urls     *sketch.HyperLogLog
+}
+
+func newUniqueSketches(precision int) *uniqueSketches {
+	return &uniqueSketches{
+		ips:      sketch.NewHyperLogLog(precision),
+		visitors: sketch.NewHyperLogLog(precision),
+		urls:     sketch.NewHyperLogLog(precision),
+	}
+}
+
+func (u *uniqueSketches) add(entry *parser.LogEntry) {
+	u.ips.Add(entry.ClientIP)
+	u.visitors.Add(entry.ClientIP + "\x00" + entry.UserAgent)
+	u.urls.Add(entry.URI)
+}
+
+// UniqueEstimate は HyperLogLog による推定ユニーク数と、その誤差範囲
+// （標準誤差の2倍 ≒ 95%信頼区間の半幅）。
+type UniqueEstimate struct {
+	Estimate    int
+	ErrorMargin int
+}
+
+type UniqueCounts struct {
+	IPs      UniqueEstimate
+	Visitors UniqueEstimate // IP + UA
+	URLs     UniqueEstimate
+}
+
+type UniquePeriod struct {
+	Start  time.Time
+	Counts UniqueCounts
+}
+
+type UniqueAnalysis struct {
+	RelativeError float64 // 1スケッチあたりの相対標準誤差
+	Hourly        []UniquePeriod
+	Daily         []UniquePeriod // JST の日付単位
+}
+
+func (a *Analyzer) trackUniques(entry *parser.LogEntry) {
+	precision := a.config.Uniques.Precision
+	a.uniques.add(entry)
+
+	hourKey := entry.Timestamp.Truncate(time.Hour).Unix()
+	if a.hourlyUniques[hourKey] == nil {
+		a.hourlyUniques[hourKey] = newUniqueSketches(precision)
+	}
+	a.hourlyUniques[hourKey].add(entry)
+
+	jst := entry.Timestamp.In(utils.JST)
+	dayKey := time.Date(jst.Year(), jst.Month(), jst.Day(), 0, 0, 0, 0, utils.JST).Unix()
+	if a.dailyUniques[dayKey] == nil {
+		a.dailyUniques[dayKey] = newUniqueSketches(precision)
+	}
+	a.dailyUniques[dayKey].add(entry)
+}
+
+func estimate(h *sketch.HyperLogLog) UniqueEstimate {
+	n := h.Count()
+	return UniqueEstimate{
+		Estimate:    n,
+		ErrorMargin: int(2*h.RelativeError()*float64(n) + 0.5),
+	}
+}
+
+func (u *uniqueSketches) counts() UniqueCounts {
+	return UniqueCounts{
+		IPs:      estimate(u.ips),
+		Visitors: estimate(u.visitors),
+		URLs:     estimate(u.urls),
+	}
+}
+
+func periods(byStart map[int64]*uniqueSketches) []UniquePeriod {
+	result := make([]UniquePeriod, 0, len(byStart))
+	for start, u := range byStart {
+		result = append(result, UniquePeriod{Start: time.Unix(start, 0).UTC(), Counts: u.counts()})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Start.Before(result[j].Start)
+	})
+	return result
+}
+
+func (a *Analyzer) generateUniques() UniqueAnalysis {
+	return UniqueAnalysis{
+		RelativeError: a.uniques.ips.RelativeError(),
+		Hourly:        periods(a.hourlyUniques),
+		Daily:         periods(a.dailyUniques),
+	}
+}
@@ -0,0 +1,147 @@
+package analyzer
+
+import (
+	"sort"
+	"strings"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+// maxUpstreamScripts caps the distinct upstream scripts tracked individually;
+// further scripts (e.g. random *.php probes) are folded into otherUpstream.
+const maxUpstreamScripts = 1000
+
+const otherUpstream = "(その他)"
+
+type UpstreamAnalysis struct {
+	PHP         UpstreamStats // upstream が *.php（PHP まで到達）
+	Static      UpstreamStats // upstream が *.php 以外（Nginx が直接配信）
+	Unknown     int           // upstream URI が記録されていない
+	Scripts     []UpstreamScript
+	TopRewrites []UpstreamMapping // リクエストURLと upstream URI が異なるもの
+}
+
+type UpstreamStats struct {
+	Requests        int
+	Errors          int
+	AvgResponseTime float64
+}
+
+// UpstreamScript は upstream の PHP スクリプト（index.php、wp-login.php 等）ごとの集計。
+type UpstreamScript struct {
+	Script          string
+	Requests        int
+	Share           float64 // PHP 到達リクエストに占める割合 (%)
+	Errors          int
+	ErrorRate       float64
+	AvgResponseTime float64
+	MaxResponseTime float64
+}
+
+// UpstreamMapping はリクエストURLと、Nginx がルーティングした upstream URI の組。
+type UpstreamMapping struct {
+	URL         string
+	UpstreamURI string
+	Count       int
+	ErrorBound  int
+}
+
+type upstreamAccumulator struct {
+	requests        int
+	errors          int
+	responseTimeMs  int64
+	responseTimeMax float64
+}
+
+func (u *upstreamAccumulator) add(entry *parser.LogEntry) {
+	u.requests++
+	if entry.IsError() {
+		u.errors++
+	}
+	u.responseTimeMs += toMillis(entry.ResponseTime)
+	if entry.ResponseTime > u.responseTimeMax {
+		u.responseTimeMax = entry.ResponseTime
+	}
+}
+
+func (u *upstreamAccumulator) stats() UpstreamStats {
+	s := UpstreamStats{Requests: u.requests, Errors: u.errors}
+	if u.requests > 0 {
+		s.AvgResponseTime = avgSeconds(u.responseTimeMs, u.requests)
+	}
+	return s
+}
+
+func (a *Analyzer) trackUpstream(entry *parser.LogEntry) {
+	upstream := entry.UpstreamURI
+	if upstream == "" || upstream == "-" {
+		a.upstreamUnknown++
+		return
+	}
+	script := pagePath(upstream)
+	if reqPath := pagePath(entry.URI); reqPath != script {
+		a.upstreamRewrites.Add(reqPath+"\x00"+script, 1)
+	}
+
+	if !strings.HasSuffix(strings.ToLower(script), ".php") {
+		a.upstreamStatic.add(entry)
+		return
+	}
+	a.upstreamPHP.add(entry)
+
+	acc := a.upstreamScripts[script]
+	if acc == nil {
+		if len(a.upstreamScripts) >= maxUpstreamScripts {
+			script = otherUpstream
+			acc = a.upstreamScripts[script]
+		}
+		if acc == nil {
+			acc = &upstreamAccumulator{}
+			a.upstreamScripts[script] = acc
+		}
+	}
+	acc.add(entry)
+}
+
+// generateUpstreamAnalysis returns PHP vs static totals, Top 10 upstream
+// scripts by request count and Top 10 URL→upstream rewrites.
+func (a *Analyzer) generateUpstreamAnalysis() UpstreamAnalysis {
+	var scripts []UpstreamScript
+	for script, acc := range a.upstreamScripts {
+		s := UpstreamScript{
+			Script:          script,
+			Requests:        acc.requests,
+			Errors:          acc.errors,
+			ErrorRate:       float64(acc.errors) / float64(acc.requests) * 100,
+			AvgResponseTime: avgSeconds(acc.responseTimeMs, acc.requests),
+			MaxResponseTime: acc.responseTimeMax,
+		}
+		if a.upstreamPHP.requests > 0 {
+			s.Share = float64(acc.requests) / float64(a.upstreamPHP.requests) * 100
+		}
+		scripts = append(scripts, s)
+	}
+	sort.Slice(scripts, func(i, j int) bool {
+		if scripts[i].Requests != scripts[j].Requests {
+			return scripts[i].Requests > scripts[j].Requests
+		}
+		return scripts[i].Script < scripts[j].Script
+	})
+	if len(scripts) > 10 {
+		scripts = scripts[:10]
+	}
+
+	var rewrites []UpstreamMapping
+	for _, item := range a.upstreamRewrites.Top(10) {
+		url, upstream, _ := strings.Cut(item.Key, "\x00")
+		rewrites = append(rewrites, UpstreamMapping{URL: url, UpstreamURI: upstream, Count: item.Count, ErrorBound: item.Error})
+	}
+
+	return UpstreamAnalysis{
+		PHP:         a.upstreamPHP.stats(),
+		Static:      a.upstreamStatic.stats(),
+		Unknown:     a.upstreamUnknown,
+		Scripts:     scripts,
+		TopRewrites: rewrites,
+	}
+}
@@ -0,0 +1,113 @@
+package analyzer
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"kinsta-log-analyzer/pkg/parser"
+)
+
+func TestTrackUpstream(t *testing.T) {
+	tests := []struct {
+		name        string
+		uri         string
+		upstream    string
+		wantRoute   string // "php", "static" or "unknown"
+		wantScript  string // upstreamScripts key, "" if none
+		wantRewrite string // upstreamRewrites key, "" if none
+	}{
+		{"pretty permalink", "/blog/hello/", "/index.php", "php", "/index.php", "/blog/hello/\x00/index.php"},
+		{"pretty permalink with query", "/shop/?page=2", "/index.php?page=2", "php", "/index.php", "/shop/\x00/index.php"},
+		{"direct script", "/wp-login.php", "/wp-login.php", "php", "/wp-login.php", ""},
+		{"query only differs", "/xmlrpc.php?rsd", "/xmlrpc.php", "php", "/xmlrpc.php", ""},
+		{"upper case extension", "/Legacy.PHP", "/Legacy.PHP", "php", "/Legacy.PHP", ""},
+		{"admin ajax", "/wp-admin/admin-ajax.php?action=x", "/wp-admin/admin-ajax.php?action=x", "php", "/wp-admin/admin-ajax.php", ""},
+		{"static file", "/wp-content/uploads/a.jpg", "/wp-content/uploads/a.jpg", "static", "", ""},
+		{"static rewrite", "/favicon.ico", "/wp-content/uploads/favicon.ico", "static", "", "/favicon.ico\x00/wp-content/uploads/favicon.ico"},
+		{"no upstream", "/", "", "unknown", "", ""},
+		{"dash upstream", "/", "-", "unknown", "", ""},
+	}
+	// only returns the single key want, or none for "".
+	only := func(want string) []string {
+		if want == "" {
+			return nil
+		}
+		return []string{want}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnalyzer(loadTestConfig(t))
+			a.trackUpstream(&parser.LogEntry{URI: tt.uri, UpstreamURI: tt.upstream, StatusCode: 200, ResponseTime: 0.1})
+
+			route := map[string]int{"php": a.upstreamPHP.requests, "static": a.upstreamStatic.requests, "unknown": a.upstreamUnknown}
+			for name, n := range route {
+				want := 0
+				if name == tt.wantRoute {
+					want = 1
+				}
+				if n != want {
+					t.Errorf("%s requests = %d, want %d", name, n, want)
+				}
+			}
+			var scripts []string
+			for s := range a.upstreamScripts {
+				scripts = append(scripts, s)
+			}
+			if !reflect.DeepEqual(scripts, only(tt.wantScript)) {
+				t.Errorf("scripts = %q, want %q", scripts, tt.wantScript)
+			}
+			var rewrites []string
+			for _, item := range a.upstreamRewrites.Top(10) {
+				rewrites = append(rewrites, item.Key)
+			}
+			if !reflect.DeepEqual(rewrites, only(tt.wantRewrite)) {
+				t.Errorf("rewrites = %q, want %q", rewrites, tt.wantRewrite)
+			}
+		})
+	}
+}
+
+func TestUpstreamScripts(t *testing.T) {
+	a := NewAnalyzer(loadTestConfig(t))
+	add := func(upstream string, status int, rt float64) {
+		a.trackUpstream(&parser.LogEntry{URI: upstream, UpstreamURI: upstream, StatusCode: status, ResponseTime: rt})
+	}
+	add("/index.php", 200, 0.2)
+	add("/index.php", 200, 0.4)
+	add("/index.php", 502, 3.0)
+	add("/wp-login.php", 200, 0.1)
+	add("/style.css", 200, 0.001)
+
+	got := a.generateUpstreamAnalysis()
+	want := []UpstreamScript{
+		{Script: "/index.php", Requests: 3, Share: 75, Errors: 1, ErrorRate: float64(1) / 3 * 100, AvgResponseTime: 1.2, MaxResponseTime: 3.0},
+		{Script: "/wp-login.php", Requests: 1, Share: 25, AvgResponseTime: 0.1, MaxResponseTime: 0.1},
+	}
+	if !reflect.DeepEqual(got.Scripts, want) {
+		t.Errorf("Scripts = %+v, want %+v", got.Scripts, want)
+	}
+	if got.PHP.Requests != 4 || got.PHP.Errors != 1 || got.Static.Requests != 1 {
+		t.Errorf("PHP = %+v, Static = %+v, want 4 requests with 1 error and 1 request", got.PHP, got.Static)
+	}
+}
+
+func TestUpstreamScriptsCapped(t *testing.T) {
+	a := NewAnalyzer(loadTestConfig(t))
+	for i := 0; i < maxUpstreamScripts+5; i++ {
+		script := fmt.Sprintf("/probe-%d.php", i)
+		a.trackUpstream(&parser.LogEntry{URI: script, UpstreamURI: script, StatusCode: 404})
+	}
+	// A script seen before the cap keeps being counted on its own.
+	a.trackUpstream(&parser.LogEntry{URI: "/probe-0.php", UpstreamURI: "/probe-0.php", StatusCode: 404})
+
+	if len(a.upstreamScripts) != maxUpstreamScripts+1 {
+		t.Errorf("tracked %d scripts, want %d", len(a.upstreamScripts), maxUpstreamScripts+1)
+	}
+	if other := a.upstreamScripts[otherUpstream]; other == nil || other.requests != 5 {
+		t.Errorf("%s = %+v, want 5 requests", otherUpstream, other)
+	}
+	if a.upstreamScripts["/probe-0.php"].requests != 2 {
+		t.Errorf("/probe-0.php requests = %d, want 2", a.upstreamScripts["/probe-0.php"].requests)
+	}
+}
@@ -0,0 +1,526 @@
+package analyzer
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/vulndb"
+)
+
+// defaultBruteForceThreshold is used when wordpress.brute_force_threshold is
+// not set.
+const defaultBruteForceThreshold = 10
+
+// maxProbedComponents caps the plugin/theme slugs tracked individually;
+// further slugs are folded into otherComponent.
+const maxProbedComponents = 1000
+
+// maxAuthorIDs caps the distinct ?author=N values tracked.
+const maxAuthorIDs = 1000
+
+// maxFoundPaths caps the 2xx probe paths kept per component, and
+// maxComponentVersions the versions kept per component.
+const (
+	maxFoundPaths        = 5
+	maxComponentVersions = 5
+)
+
+const otherComponent = "(その他)"
+
+// Component types of ProbedComponent.
+const (
+	ComponentPlugin = vulndb.TypePlugin
+	ComponentTheme  = vulndb.TypeTheme
+)
+
+// Whether a vulnerable component's installed version is affected.
+const (
+	VersionAffected    = "affected"
+	VersionNotAffected = "not-affected"
+	VersionUnknown     = "unknown"
+)
+
+// componentProbeFiles are files scanners request to detect a plugin or theme
+// and its version.
+var componentProbeFiles = map[string]bool{
+	"readme.txt":    true,
+	"readme.md":     true,
+	"changelog.txt": true,
+	"changelog.md":  true,
+	"license.txt":   true,
+}
+
+type WordPressAnalysis struct {
+	Login       LoginAnalysis
+	Distributed DistributedLoginAnalysis
+	XMLRPC      XMLRPCAnalysis
+	UserEnum    UserEnumAnalysis
+	Probes      ComponentProbeAnalysis
+}
+
+// LoginAnalysis は wp-login.php への POST（ログイン試行）の集計。
+// 成功は 302（管理画面等へのリダイレクト）、失敗は 200（ログインフォームの再表示）で判定する。
+// action=lostpassword などログイン以外のフォーム送信は含まない。
+type LoginAnalysis struct {
+	Attempts            int
+	Successes           int
+	Failures            int
+	Blocked             int            // 403 / 429 / 503 等で拒否
+	IPs                 UniqueEstimate // 試行したIP数
+	BruteForceThreshold int
+	BruteForceIPs       []LoginIP // 失敗が閾値以上のIP（失敗数順）
+	TopIPs              []LoginIP // 試行数順 上位10
+	Dropped             int       // IP別の集計の上限（heavy_hitters.capacity）により外した試行の少ないIP数
+}
+
+type LoginIP struct {
+	IP        string
+	Attempts  int
+	Successes int
+	Failures  int
+	Blocked   int
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// XMLRPCAnalysis は xmlrpc.php へのリクエストの集計。リクエストボディは
+// アクセスログに残らないため、system.multicall は応答サイズの大きい POST で推定する。
+type XMLRPCAnalysis struct {
+	Requests          int
+	Posts             int
+	LargePosts        int   // 応答が MulticallMinBytes 以上の POST（system.multicall の可能性）
+	MulticallMinBytes int64 // 0 なら推定しない
+	Blocked           int
+	UniqueIPs         UniqueEstimate
+	TopIPs            []IPCount // POST 数順
+}
+
+// UserEnumAnalysis は ?author=N と REST API（/wp-json/wp/v2/users）による
+// ユーザー列挙の集計。
+type UserEnumAnalysis struct {
+	AuthorRequests  int
+	AuthorRedirects int // 3xx（/author/<ユーザー名>/ へのリダイレクトでユーザー名が判明）
+	AuthorIDs       int // 試された author ID の種類数
+	MaxAuthorID     int
+	RESTRequests    int
+	RESTExposed     int // 200（ユーザー一覧を取得できた）
+	TopIPs          []IPCount
+}
+
+// ComponentProbeAnalysis は /wp-content/plugins/<slug>/ と /wp-content/themes/<slug>/
+// に対する探索（readme.txt 等の取得、または 404）の集計。
+type ComponentProbeAnalysis struct {
+	Requests   int
+	Slugs      int // 探索されたスラッグ数
+	UniqueIPs  UniqueEstimate
+	Components []ProbedComponent // リクエスト数順 上位20
+	TopIPs     []IPCount
+	VulnDB     int                   // 読み込んだ脆弱性リストの件数（0 なら照合しない）
+	Vulnerable []VulnerableComponent // 脆弱性リストに載っている探索対象（2xx あり優先、探索数順）
+}
+
+type ProbedComponent struct {
+	Type     string // plugin / theme
+	Slug     string
+	Requests int
+	Found    int // 2xx（存在が判明）
+}
+
+type loginAccumulator struct {
+	attempts  int
+	successes int
+	failures  int
+	blocked   int
+	seen      seenRange
+}
+
+// VulnerableComponent は脆弱性リストに載っているプラグイン・テーマへの探索。
+type VulnerableComponent struct {
+	ProbedComponent
+	FoundPaths      []string // 2xx を返した探索パス
+	Versions        []string // ?ver= から推定したサイト上のバージョン
+	Vulnerabilities []ComponentVulnerability
+}
+
+type ComponentVulnerability struct {
+	CVE      string
+	Title    string
+	Versions string // 影響を受けるバージョン
+	Status   string // VersionAffected / VersionNotAffected / VersionUnknown
+}
+
+type componentAccumulator struct {
+	requests   int
+	found      int
+	foundPaths map[string]int
+}
+
+func isBlockedStatus(status int) bool {
+	return status == 401 || status == 403 || status == 429 || status == 444 || status == 503
+}
+
+func (a *Analyzer) trackWordPress(entry *parser.LogEntry) {
+	path, query, _ := strings.Cut(entry.URI, "?")
+	lower := strings.ToLower(path)
+	if entry.Method == "POST" {
+		a.trackDistributedLogin(entry, lower, query)
+	}
+
+	switch {
+	case strings.HasSuffix(lower, "/wp-login.php"):
+		if entry.Method == "POST" && isLoginAction(query) {
+			a.trackLogin(entry)
+		}
+	case strings.HasSuffix(lower, "/xmlrpc.php"):
+		a.trackXMLRPC(entry)
+	case strings.HasPrefix(lower, "/wp-json/wp/v2/users"):
+		a.trackRESTUsers(entry)
+	case strings.HasPrefix(lower, "/wp-content/plugins/"):
+		a.trackComponentProbe(entry, ComponentPlugin, path[len("/wp-content/plugins/"):], query)
+	case strings.HasPrefix(lower, "/wp-content/themes/"):
+		a.trackComponentProbe(entry, ComponentTheme, path[len("/wp-content/themes/"):], query)
+	}
+
+	if query == "" {
+		return
+	}
+	if strings.Contains(query, "author=") {
+		a.trackAuthorEnum(entry, query)
+	}
+	if strings.Contains(query, "rest_route=") {
+		if values, err := url.ParseQuery(query); err == nil && strings.HasPrefix(strings.ToLower(values.Get("rest_route")), "/wp/v2/users") {
+			a.trackRESTUsers(entry)
+		}
+	}
+}
+
+// isLoginAction reports whether a wp-login.php query is the login form
+// rather than lost password, registration and the like.
+func isLoginAction(query string) bool {
+	if !strings.Contains(query, "action=") {
+		return true
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return true
+	}
+	action := values.Get("action")
+	return action == "" || action == "login"
+}
+
+// trackLogin counts a login attempt in the totals and per IP. The IPs are
+// capped at heavy_hitters.capacity: when full, the half with the fewest
+// attempts so far is dropped, which brute-force IPs never are for long.
+func (a *Analyzer) trackLogin(entry *parser.LogEntry) {
+	a.loginTotals.add(entry)
+	a.loginUniqueIPs.Add(entry.ClientIP)
+	acc := a.loginByIP[entry.ClientIP]
+	if acc == nil {
+		if limit := a.config.HeavyHitters.Capacity; limit > 0 && len(a.loginByIP) >= limit {
+			a.loginDropped += pruneLoginIPs(a.loginByIP, max(limit/2, 1))
+		}
+		acc = &loginAccumulator{}
+		a.loginByIP[entry.ClientIP] = acc
+	}
+	acc.add(entry)
+}
+
+func (l *loginAccumulator) add(entry *parser.LogEntry) {
+	l.attempts++
+	l.seen.add(entry.Timestamp)
+	switch status := entry.StatusCode; {
+	case status == 302 || status == 303:
+		l.successes++
+	case status == 200:
+		l.failures++
+	case isBlockedStatus(status):
+		l.blocked++
+	}
+}
+
+// pruneLoginIPs keeps the keep IPs with the most attempts and returns the
+// number dropped.
+func pruneLoginIPs(ips map[string]*loginAccumulator, keep int) int {
+	return keepBusiest(ips, keep, func(l *loginAccumulator) int { return l.attempts })
+}
+
+func (a *Analyzer) trackXMLRPC(entry *parser.LogEntry) {
+	a.xmlrpcRequests++
+	a.xmlrpcUniqueIPs.Add(entry.ClientIP)
+	if isBlockedStatus(entry.StatusCode) {
+		a.xmlrpcBlocked++
+	}
+	if entry.Method != "POST" {
+		return
+	}
+	a.xmlrpcPosts++
+	a.xmlrpcIPs.Add(entry.ClientIP, 1)
+	if minBytes := a.config.WordPress.MulticallMinBytes; minBytes > 0 && entry.ResponseSize >= minBytes {
+		a.xmlrpcLargePosts++
+	}
+}
+
+func (a *Analyzer) trackAuthorEnum(entry *parser.LogEntry, query string) {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return
+	}
+	id, err := strconv.Atoi(values.Get("author"))
+	if err != nil || id < 0 {
+		return
+	}
+	a.authorRequests++
+	if isRedirect(entry.StatusCode) {
+		a.authorRedirects++
+	}
+	if _, ok := a.authorIDs[id]; ok || len(a.authorIDs) < maxAuthorIDs {
+		a.authorIDs[id]++
+	}
+	a.userEnumIPs.Add(entry.ClientIP, 1)
+}
+
+func (a *Analyzer) trackRESTUsers(entry *parser.LogEntry) {
+	a.restUserRequests++
+	if entry.StatusCode == 200 {
+		a.restUserExposed++
+	}
+	a.userEnumIPs.Add(entry.ClientIP, 1)
+}
+
+// trackComponentProbe counts a request under a plugin or theme directory
+// (rest is the path after ".../plugins/") as a probe when it asks for a file
+// scanners use to fingerprint components, the directory itself, or a .php
+// entry point. Assets loaded by pages are not probes even when they 404 (a
+// stale cached page asks for the old ones), but their ?ver= tells which
+// version the site runs.
+func (a *Analyzer) trackComponentProbe(entry *parser.LogEntry, kind, rest, query string) {
+	slug, file, _ := strings.Cut(rest, "/")
+	if slug == "" {
+		return
+	}
+	success := entry.StatusCode >= 200 && entry.StatusCode < 300
+	if success && strings.Contains(query, "ver=") {
+		a.trackComponentVersion(kind+"\x00"+strings.ToLower(slug), query)
+	}
+	if !isProbeTarget(file) {
+		return
+	}
+	a.probeRequests++
+	a.probeIPs.Add(entry.ClientIP, 1)
+	a.probeUniqueIPs.Add(entry.ClientIP)
+
+	key := kind + "\x00" + strings.ToLower(slug)
+	acc := a.probedComponents[key]
+	if acc == nil {
+		if len(a.probedComponents) >= maxProbedComponents {
+			key = kind + "\x00" + otherComponent
+			acc = a.probedComponents[key]
+		}
+		if acc == nil {
+			acc = &componentAccumulator{foundPaths: make(map[string]int)}
+			a.probedComponents[key] = acc
+		}
+	}
+	acc.requests++
+	if success {
+		acc.found++
+		if path := pagePath(entry.URI); acc.foundPaths[path] > 0 || len(acc.foundPaths) < maxFoundPaths {
+			acc.foundPaths[path]++
+		}
+	}
+}
+
+// isProbeTarget reports whether file, the path below a plugin or theme
+// directory, is something scanners request to find or attack a component.
+func isProbeTarget(file string) bool {
+	file = strings.ToLower(file)
+	return file == "" || componentProbeFiles[file] || strings.HasSuffix(file, ".php")
+}
+
+func (a *Analyzer) trackComponentVersion(key, query string) {
+	values, err := url.ParseQuery(query)
+	if err != nil || !isVersion(values.Get("ver")) {
+		return
+	}
+	versions := a.componentVersions[key]
+	if versions == nil {
+		if len(a.componentVersions) >= maxProbedComponents {
+			return
+		}
+		versions = make(map[string]int)
+		a.componentVersions[key] = versions
+	}
+	if version := values.Get("ver"); versions[version] > 0 || len(versions) < maxComponentVersions {
+		versions[version]++
+	}
+}
+
+// isVersion reports whether s looks like a dotted version ("5.3.2"); cache
+// busters such as timestamps or hashes are not versions.
+func isVersion(s string) bool {
+	if s == "" || s[0] < '0' || s[0] > '9' || !strings.Contains(s, ".") {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= '0' && r <= '9' || r == '.' || r == '-' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Analyzer) generateWordPressAnalysis() WordPressAnalysis {
+	return WordPressAnalysis{
+		Login:       a.generateLoginAnalysis(),
+		Distributed: a.generateDistributedLogin(),
+		XMLRPC:      a.generateXMLRPCAnalysis(),
+		UserEnum:    a.generateUserEnumAnalysis(),
+		Probes:      a.generateComponentProbes(),
+	}
+}
+
+func (a *Analyzer) bruteForceThreshold() int {
+	if t := a.config.WordPress.BruteForceThreshold; t > 0 {
+		return t
+	}
+	return defaultBruteForceThreshold
+}
+
+func (a *Analyzer) generateLoginAnalysis() LoginAnalysis {
+	result := LoginAnalysis{
+		Attempts:            a.loginTotals.attempts,
+		Successes:           a.loginTotals.successes,
+		Failures:            a.loginTotals.failures,
+		Blocked:             a.loginTotals.blocked,
+		IPs:                 estimate(a.loginUniqueIPs),
+		BruteForceThreshold: a.bruteForceThreshold(),
+		Dropped:             a.loginDropped,
+	}
+	var ips []LoginIP
+	for _, ip := range sortedKeys(a.loginByIP) {
+		acc := a.loginByIP[ip]
+		ips = append(ips, LoginIP{
+			IP:        ip,
+			Attempts:  acc.attempts,
+			Successes: acc.successes,
+			Failures:  acc.failures,
+			Blocked:   acc.blocked,
+			FirstSeen: acc.seen.First,
+			LastSeen:  acc.seen.Last,
+		})
+	}
+
+	sort.SliceStable(ips, func(i, j int) bool { return ips[i].Failures > ips[j].Failures })
+	for _, ip := range ips {
+		if ip.Failures < result.BruteForceThreshold {
+			break
+		}
+		result.BruteForceIPs = append(result.BruteForceIPs, ip)
+	}
+
+	sort.SliceStable(ips, func(i, j int) bool { return ips[i].Attempts > ips[j].Attempts })
+	if len(ips) > 10 {
+		ips = ips[:10]
+	}
+	result.TopIPs = ips
+	return result
+}
+
+func (a *Analyzer) generateXMLRPCAnalysis() XMLRPCAnalysis {
+	return XMLRPCAnalysis{
+		Requests:          a.xmlrpcRequests,
+		Posts:             a.xmlrpcPosts,
+		LargePosts:        a.xmlrpcLargePosts,
+		MulticallMinBytes: a.config.WordPress.MulticallMinBytes,
+		Blocked:           a.xmlrpcBlocked,
+		UniqueIPs:         estimate(a.xmlrpcUniqueIPs),
+		TopIPs:            topIPCounts(a.xmlrpcIPs, 10),
+	}
+}
+
+func (a *Analyzer) generateUserEnumAnalysis() UserEnumAnalysis {
+	result := UserEnumAnalysis{
+		AuthorRequests:  a.authorRequests,
+		AuthorRedirects: a.authorRedirects,
+		AuthorIDs:       len(a.authorIDs),
+		RESTRequests:    a.restUserRequests,
+		RESTExposed:     a.restUserExposed,
+		TopIPs:          topIPCounts(a.userEnumIPs, 10),
+	}
+	for id := range a.authorIDs {
+		result.MaxAuthorID = max(result.MaxAuthorID, id)
+	}
+	return result
+}
+
+func (a *Analyzer) generateComponentProbes() ComponentProbeAnalysis {
+	result := ComponentProbeAnalysis{
+		Requests:  a.probeRequests,
+		Slugs:     len(a.probedComponents),
+		UniqueIPs: estimate(a.probeUniqueIPs),
+		TopIPs:    topIPCounts(a.probeIPs, 10),
+		VulnDB:    a.config.Vulnerabilities().Len(),
+	}
+	for _, key := range sortedKeys(a.probedComponents) {
+		acc := a.probedComponents[key]
+		kind, slug, _ := strings.Cut(key, "\x00")
+		result.Components = append(result.Components, ProbedComponent{Type: kind, Slug: slug, Requests: acc.requests, Found: acc.found})
+	}
+	sort.SliceStable(result.Components, func(i, j int) bool {
+		return result.Components[i].Requests > result.Components[j].Requests
+	})
+	result.Vulnerable = a.vulnerableComponents(result.Components)
+	if len(result.Components) > 20 {
+		result.Components = result.Components[:20]
+	}
+	return result
+}
+
+// vulnerableComponents matches the probed components against the configured
+// vulnerability database. Components that answered 2xx come first.
+func (a *Analyzer) vulnerableComponents(components []ProbedComponent) []VulnerableComponent {
+	db := a.config.Vulnerabilities()
+	var result []VulnerableComponent
+	for _, c := range components {
+		vulns := db.Lookup(c.Type, c.Slug)
+		if len(vulns) == 0 {
+			continue
+		}
+		key := c.Type + "\x00" + c.Slug
+		vc := VulnerableComponent{
+			ProbedComponent: c,
+			FoundPaths:      sortedKeys(a.probedComponents[key].foundPaths),
+			Versions:        sortedKeys(a.componentVersions[key]),
+		}
+		sort.Slice(vc.Versions, func(i, j int) bool { return vulndb.CompareVersions(vc.Versions[i], vc.Versions[j]) < 0 })
+		for _, v := range vulns {
+			vc.Vulnerabilities = append(vc.Vulnerabilities, ComponentVulnerability{
+				CVE:      v.CVE,
+				Title:    v.Title,
+				Versions: v.Versions,
+				Status:   versionStatus(v, vc.Versions),
+			})
+		}
+		result = append(result, vc)
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Found > 0 && result[j].Found == 0 })
+	return result
+}
+
+// versionStatus says whether any of the versions seen on the site is
+// affected by v.
+func versionStatus(v vulndb.Vulnerability, versions []string) string {
+	if len(versions) == 0 {
+		return VersionUnknown
+	}
+	for _, version := range versions {
+		if v.Affects(version) {
+			return VersionAffected
+		}
+	}
+	return VersionNotAffected
+}
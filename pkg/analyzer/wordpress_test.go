@@ -0,0 +1,155 @@
+package analyzer
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"kinsta-log-analyzer/pkg/parser"
+	"kinsta-log-analyzer/pkg/vulndb"
+)
+
+func TestTrackComponentProbe(t *testing.T) {
+	tests := []struct {
+		name        string
+		uri         string
+		status      int
+		wantKey     string // probedComponents key, "" if not a probe
+		wantFound   bool
+		wantVersion string // componentVersions entry, "" if none
+	}{
+		{"plugin directory", "/wp-content/plugins/contact-form-7/", 403, ComponentPlugin + "\x00contact-form-7", false, ""},
+		{"plugin readme found", "/wp-content/plugins/Akismet/README.txt", 200, ComponentPlugin + "\x00akismet", true, ""},
+		{"theme changelog missing", "/wp-content/themes/astra/changelog.md", 404, ComponentTheme + "\x00astra", false, ""},
+		{"missing asset", "/wp-content/plugins/revslider/js/app.js", 404, "", false, ""},
+		{"php entry point", "/wp-content/plugins/wp-file-manager/lib/php/connector.minimal.php", 404, ComponentPlugin + "\x00wp-file-manager", false, ""},
+		{"php entry point found", "/wp-content/themes/Divi/includes/Upload.PHP", 200, ComponentTheme + "\x00divi", true, ""},
+		{"asset with version", "/wp-content/plugins/woocommerce/assets/app.js?ver=8.5.2", 200, "", false, "8.5.2"},
+		{"asset with cache buster", "/wp-content/themes/astra/style.css?ver=1700000000", 200, "", false, ""},
+		{"asset with hash", "/wp-content/themes/astra/style.css?ver=abc.def", 200, "", false, ""},
+		{"version of a missing asset", "/wp-content/plugins/woocommerce/assets/app.js?ver=8.5.2", 404, "", false, ""},
+		{"readme with version", "/wp-content/plugins/elementor/readme.txt?ver=3.20.1", 200, ComponentPlugin + "\x00elementor", true, "3.20.1"},
+		{"plugins index", "/wp-content/plugins/", 200, "", false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAnalyzer(loadTestConfig(t))
+			a.trackWordPress(&parser.LogEntry{ClientIP: "192.0.2.1", Method: "GET", URI: tt.uri, StatusCode: tt.status})
+
+			if tt.wantKey == "" {
+				if len(a.probedComponents) != 0 || a.probeRequests != 0 {
+					t.Errorf("counted as a probe: %v", a.probedComponents)
+				}
+			} else {
+				acc := a.probedComponents[tt.wantKey]
+				if acc == nil || acc.requests != 1 || a.probeRequests != 1 {
+					t.Fatalf("probe %q not counted once: %v", tt.wantKey, a.probedComponents)
+				}
+				if found := acc.found > 0; found != tt.wantFound {
+					t.Errorf("found = %v, want %v", found, tt.wantFound)
+				}
+			}
+
+			var versions []string
+			for key, vs := range a.componentVersions {
+				for v := range vs {
+					versions = append(versions, strings.ReplaceAll(key, "\x00", "/")+"@"+v)
+				}
+			}
+			switch {
+			case tt.wantVersion == "" && len(versions) > 0:
+				t.Errorf("recorded versions %v, want none", versions)
+			case tt.wantVersion != "" && (len(versions) != 1 || !strings.HasSuffix(versions[0], "@"+tt.wantVersion)):
+				t.Errorf("recorded versions %v, want %s", versions, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestIsVersion(t *testing.T) {
+	tests := []struct {
+		s    string
+		want bool
+	}{
+		{"5.3.2", true},
+		{"6.4", true},
+		{"1.0.0-beta1", true},
+		{"", false},
+		{"1700000000", false},
+		{"v1.2", false},
+		{"1.2 ", false},
+		{"1.2;", false},
+	}
+	for _, tt := range tests {
+		if got := isVersion(tt.s); got != tt.want {
+			t.Errorf("isVersion(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestVersionStatus(t *testing.T) {
+	db, err := vulndb.ParseJSON(strings.NewReader(`[
+  {"slug": "contact-form-7", "type": "plugin", "versions": "< 5.3.2", "cve": "CVE-2020-35489"},
+  {"slug": "wp-file-manager", "type": "plugin", "versions": ">= 6.0, <= 6.8", "cve": "CVE-2020-25213"},
+  {"slug": "duplicator", "type": "plugin", "versions": "*", "cve": "CVE-0000-0001"}
+]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		slug     string
+		versions []string
+		want     string
+	}{
+		{"contact-form-7", nil, VersionUnknown},
+		{"contact-form-7", []string{"5.3.1"}, VersionAffected},
+		{"contact-form-7", []string{"5.3.2"}, VersionNotAffected},
+		{"contact-form-7", []string{"5.10"}, VersionNotAffected},
+		{"contact-form-7", []string{"5.4", "5.2.9"}, VersionAffected},
+		{"wp-file-manager", []string{"5.9"}, VersionNotAffected},
+		{"wp-file-manager", []string{"6.0"}, VersionAffected},
+		{"wp-file-manager", []string{"6.8"}, VersionAffected},
+		{"wp-file-manager", []string{"6.9", "7.0"}, VersionNotAffected},
+		{"duplicator", []string{"1.5.0"}, VersionAffected},
+		{"duplicator", nil, VersionUnknown},
+	}
+	for _, tt := range tests {
+		vulns := db.Lookup(vulndb.TypePlugin, tt.slug)
+		if len(vulns) != 1 {
+			t.Fatalf("Lookup(%s) = %+v", tt.slug, vulns)
+		}
+		if got := versionStatus(vulns[0], tt.versions); got != tt.want {
+			t.Errorf("versionStatus(%s, %v) = %s, want %s", tt.slug, tt.versions, got, tt.want)
+		}
+	}
+}
+
+func TestLoginIPsCapped(t *testing.T) {
+	cfg := loadTestConfig(t)
+	cfg.HeavyHitters.Capacity = 4
+	a := NewAnalyzer(cfg)
+	login := func(ip string, status int) {
+		a.trackLogin(&parser.LogEntry{ClientIP: ip, Method: "POST", URI: "/wp-login.php", StatusCode: status})
+	}
+	// The brute-forcer keeps its place while single attempts churn through.
+	for i := 0; i < 20; i++ {
+		login("203.0.113.1", 200)
+	}
+	for i := 1; i <= 10; i++ {
+		login(fmt.Sprintf("192.0.2.%d", i), 302)
+	}
+
+	got := a.generateLoginAnalysis()
+	if len(a.loginByIP) > cfg.HeavyHitters.Capacity {
+		t.Errorf("tracked %d IPs, want at most %d", len(a.loginByIP), cfg.HeavyHitters.Capacity)
+	}
+	if got.Attempts != 30 || got.Failures != 20 || got.Successes != 10 {
+		t.Errorf("totals = %d attempts, %d failures, %d successes, want 30, 20, 10", got.Attempts, got.Failures, got.Successes)
+	}
+	if got.Dropped+len(a.loginByIP) != 11 {
+		t.Errorf("dropped %d and kept %d IPs, want 11 in all", got.Dropped, len(a.loginByIP))
+	}
+	if len(got.BruteForceIPs) != 1 || got.BruteForceIPs[0].IP != "203.0.113.1" {
+		t.Errorf("BruteForceIPs = %+v, want 203.0.113.1", got.BruteForceIPs)
+	}
+}
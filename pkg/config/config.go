@@ -3,15 +3,28 @@ package config
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"gopkg.in/yaml.v2"
+
+	"kinsta-log-analyzer/pkg/rules"
+	"kinsta-log-analyzer/pkg/vulndb"
 )
 
 type Config struct {
-	Thresholds Thresholds `yaml:"thresholds"`
-	Security   Security   `yaml:"security"`
-	Output     Output     `yaml:"output"`
+	Thresholds   Thresholds   `yaml:"thresholds"`
+	Security     Security     `yaml:"security"`
+	Output       Output       `yaml:"output"`
+	Anomaly      Anomaly      `yaml:"anomaly"`
+	Sessions     Sessions     `yaml:"sessions"`
+	Uniques      Uniques      `yaml:"uniques"`
+	HeavyHitters HeavyHitters `yaml:"heavy_hitters"`
+	Referer      Referer      `yaml:"referer"`
+	Redirects    Redirects    `yaml:"redirects"`
+	WordPress    WordPress    `yaml:"wordpress"`
+	RateLimit    RateLimit    `yaml:"rate_limit"`
+	Baseline     Baseline     `yaml:"baseline"`
 }
 
 type Thresholds struct {
@@ -22,16 +35,134 @@ type Thresholds struct {
 	BurstThreshold          int     `yaml:"burst_threshold"`
 }
 
+// Security は検出ルールの設定。各ルールは ID・カテゴリ・重大度・対象フィールド
+// （uri / ua / referer）・一致条件（部分一致、"re:" で始まれば正規表現）を持つ。
+// 旧形式のパターン一覧（sql_injection_patterns など）も読み込め、ルールに変換される。
+// crs_files の OWASP CRS 形式（SecRule）のルールも取り込める。
 type Security struct {
+	Rules          []rules.Rule `yaml:"rules"`
+	CRSFiles       []string     `yaml:"crs_files"` // SecRule ファイル（glob 可、相対パスは設定ファイルの場所から）
+	UnusualMethods []string     `yaml:"unusual_methods"`
+
+	// exposure ルールに一致したリクエストへの 2xx 応答がこのバイト数以上なら漏洩とみなす
+	ExposureMinBytes int64 `yaml:"exposure_min_bytes"`
+
+	// 旧形式（非推奨）
 	SQLInjectionPatterns []string `yaml:"sql_injection_patterns"`
 	XSSPatterns          []string `yaml:"xss_patterns"`
 	CrawlerUserAgents    []string `yaml:"crawler_user_agents"`
 	AttackToolPatterns   []string `yaml:"attack_tool_patterns"`
+
+	engine    *rules.Engine
+	crsImport *rules.ImportReport
+}
+
+// legacyRules converts the flat pattern lists of older configurations into
+// rules with generated IDs such as "legacy-sqli-3".
+func (s *Security) legacyRules() []rules.Rule {
+	var result []rules.Rule
+	for _, list := range []struct {
+		patterns []string
+		category string
+		severity rules.Severity
+		targets  []string
+	}{
+		{s.SQLInjectionPatterns, rules.CategorySQLi, rules.SeverityHigh, []string{rules.FieldURI, rules.FieldUserAgent}},
+		{s.XSSPatterns, rules.CategoryXSS, rules.SeverityHigh, []string{rules.FieldURI, rules.FieldUserAgent}},
+		{s.CrawlerUserAgents, rules.CategoryCrawler, rules.SeverityInfo, []string{rules.FieldUserAgent}},
+		{s.AttackToolPatterns, rules.CategoryScanner, rules.SeverityMedium, []string{rules.FieldUserAgent}},
+	} {
+		for i, p := range list.patterns {
+			result = append(result, rules.Rule{
+				ID:          fmt.Sprintf("legacy-%s-%d", list.category, i+1),
+				Category:    list.category,
+				Severity:    list.severity,
+				Targets:     list.targets,
+				Match:       p,
+				Description: p,
+			})
+		}
+	}
+	return result
+}
+
+// Anomaly は分単位の時系列（リクエスト数・エラー数・レイテンシ）に対する
+// ローリング median/MAD ベースの異常検知設定。
+type Anomaly struct {
+	Enabled        bool    `yaml:"enabled"`
+	WindowMinutes  int     `yaml:"window_minutes"`  // ベースラインに使う直前の分数
+	MinBaseline    int     `yaml:"min_baseline"`    // 判定に必要なベースラインの最小点数
+	ScoreThreshold float64 `yaml:"score_threshold"` // ロバストZスコアの閾値
+}
+
+// Sessions は IP+UA 単位のセッション再構成の設定。
+type Sessions struct {
+	InactivityTimeoutMinutes int `yaml:"inactivity_timeout_minutes"` // これを超えて間隔が空くと別セッション
+	PathDepth                int `yaml:"path_depth"`                 // 遷移パスとして集計する先頭ページ数
+}
+
+// Uniques は HyperLogLog によるユニーク数推定の設定。
+type Uniques struct {
+	Precision int `yaml:"precision"` // レジスタ数 2^precision（4〜18、0 で既定値 12）
+}
+
+// HeavyHitters は Top-N 集計（IP・URL・UA）に使うカウンタの設定。
+// Capacity > 0 では Space-Saving でメモリを上限内に保ち、0 では全キーを正確に数える。
+type HeavyHitters struct {
+	Capacity int `yaml:"capacity"` // カウンタごとに監視するキー数の上限
+}
+
+// Referer はリファラー分析（内部/外部、検索エンジン、スパム、ホットリンク）の設定。
+type Referer struct {
+	InternalDomains []string `yaml:"internal_domains"` // ログの Domain 以外に自サイトとみなすドメイン
+	SearchEngines   []string `yaml:"search_engines"`   // 検索エンジンとみなすホストの部分文字列
+	SpamPatterns    []string `yaml:"spam_patterns"`    // リファラースパムとみなすホストの部分文字列
+
+	internalHosts []string
+}
+
+// Redirects はリダイレクトチェーン推定の設定。
+type Redirects struct {
+	ChainWindowSeconds int `yaml:"chain_window_seconds"` // 同一クライアントの次リクエストをチェーンの続きとみなす最大間隔
+	MaxChainLength     int `yaml:"max_chain_length"`     // これを超えるチェーンはループとみなす
+}
+
+// WordPress は wp-login.php・xmlrpc.php・ユーザー列挙・プラグイン探索・分散型ブルートフォースの分析設定。
+// vulnerability_db を指定すると、探索されたプラグイン・テーマを既知の脆弱性と照合する。
+type WordPress struct {
+	BruteForceThreshold int    `yaml:"brute_force_threshold"` // ログイン失敗がこの回数以上のIPをブルートフォースとみなす（0 なら 10）
+	MulticallMinBytes   int64  `yaml:"multicall_min_bytes"`   // xmlrpc.php への POST の応答がこのサイズ以上なら system.multicall の可能性（0 で無効）
+	VulnerabilityDB     string `yaml:"vulnerability_db"`      // 脆弱性リスト（JSON / CSV、相対パスは設定ファイルの場所から）
+
+	// 分散型ブルートフォース（多数のIPがそれぞれ少数回だけ試す攻撃）の検出
+	DistributedWindowMinutes    int     `yaml:"distributed_window_minutes"`      // 集計する時間ウィンドウ（分、0 で無効）
+	DistributedMinIPs           int     `yaml:"distributed_min_ips"`             // ウィンドウ内でログインに失敗した少数試行IPがこの数以上で検出（0 なら 10）
+	DistributedMaxAttemptsPerIP int     `yaml:"distributed_max_attempts_per_ip"` // ウィンドウ内の試行がこの回数以下のIPを少数試行とみなす（0 なら 5）
+	DistributedMinFailureRate   float64 `yaml:"distributed_min_failure_rate"`    // 少数試行IPの失敗率（%）の下限
+
+	vulnerabilities *vulndb.DB
+}
+
+// RateLimit は Nginx limit_req の推奨値算出の設定。
+type RateLimit struct {
+	Percentile float64 `yaml:"percentile"` // 制限しない正規クライアントの割合（%、0 なら 99）
+}
+
+// Baseline は実行ごとの要約を保存し、直近 N 回の実行と比較して
+// 劣化（リグレッション）を検出する設定。
+type Baseline struct {
+	Enabled          bool    `yaml:"enabled"`
+	StateDir         string  `yaml:"state_dir"`          // 実行要約（JSON）の保存先
+	Runs             int     `yaml:"runs"`               // ベースラインに使う直近の実行数（これより古い要約は削除）
+	MinRuns          int     `yaml:"min_runs"`           // 判定に必要な過去実行数
+	ScoreThreshold   float64 `yaml:"score_threshold"`    // ロバストZスコアの閾値
+	MinChangePercent float64 `yaml:"min_change_percent"` // ベースライン中央値からの最小変化率（%）
 }
 
 type Output struct {
 	TopIPsCount      int    `yaml:"top_ips_count"`
 	TopErrorsCount   int    `yaml:"top_errors_count"`
+	StatusURLCodes   []int  `yaml:"status_url_codes"` // URL Top を出すステータスコード（空なら観測された全コード）
 	ReportFormat     string `yaml:"report_format"`
 	OutputDirectory  string `yaml:"output_directory"`
 }
@@ -47,15 +178,56 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
 
-	// Convert patterns to lowercase for case-insensitive matching
-	config.Security.SQLInjectionPatterns = toLowerSlice(config.Security.SQLInjectionPatterns)
-	config.Security.XSSPatterns = toLowerSlice(config.Security.XSSPatterns)
-	config.Security.CrawlerUserAgents = toLowerSlice(config.Security.CrawlerUserAgents)
-	config.Security.AttackToolPatterns = toLowerSlice(config.Security.AttackToolPatterns)
+	// Compile detection rules; the rest are lowercased for case-insensitive matching
+	config.Security.Rules = append(config.Security.Rules, config.Security.legacyRules()...)
+	if len(config.Security.CRSFiles) > 0 {
+		patterns := make([]string, len(config.Security.CRSFiles))
+		for i, p := range config.Security.CRSFiles {
+			if !filepath.IsAbs(p) {
+				p = filepath.Join(filepath.Dir(configPath), p)
+			}
+			patterns[i] = p
+		}
+		crsRules, report, err := rules.LoadSecRuleFiles(patterns)
+		if err != nil {
+			return nil, fmt.Errorf("security.crs_files: %v", err)
+		}
+		config.Security.Rules = append(config.Security.Rules, crsRules...)
+		config.Security.crsImport = report
+	}
+	config.Security.engine, err = rules.Compile(config.Security.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("security.rules: %v", err)
+	}
+	if path := config.WordPress.VulnerabilityDB; path != "" {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(configPath), path)
+		}
+		config.WordPress.vulnerabilities, err = vulndb.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("wordpress.vulnerability_db: %v", err)
+		}
+	}
+	config.Referer.InternalDomains = toLowerSlice(config.Referer.InternalDomains)
+	config.Referer.internalHosts = internalHosts(config.Referer.InternalDomains)
+	config.Referer.SearchEngines = toLowerSlice(config.Referer.SearchEngines)
+	config.Referer.SpamPatterns = toLowerSlice(config.Referer.SpamPatterns)
 
 	return &config, nil
 }
 
+// internalHosts returns the non-empty domains without a leading "www.", the
+// form IsInternalHost compares hosts against.
+func internalHosts(domains []string) []string {
+	var result []string
+	for _, d := range domains {
+		if d = trimWWW(d); d != "" {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
 func toLowerSlice(slice []string) []string {
 	result := make([]string, len(slice))
 	for i, s := range slice {
@@ -64,40 +236,120 @@ func toLowerSlice(slice []string) []string {
 	return result
 }
 
+// Rules returns the compiled detection rules.
+func (c *Config) Rules() *rules.Engine {
+	return c.Security.engine
+}
+
+// CRSImport describes the rules imported from crs_files, or is nil when none
+// are configured.
+func (c *Config) CRSImport() *rules.ImportReport {
+	return c.Security.crsImport
+}
+
+// Vulnerabilities returns the vulnerability database loaded from
+// wordpress.vulnerability_db, or nil when none is configured.
+func (c *Config) Vulnerabilities() *vulndb.DB {
+	return c.WordPress.vulnerabilities
+}
+
+// IsSQLInjectionAttempt reports whether an sqli rule matches the request.
 func (c *Config) IsSQLInjectionAttempt(uri, userAgent string) bool {
-	combined := strings.ToLower(uri + " " + userAgent)
-	for _, pattern := range c.Security.SQLInjectionPatterns {
-		if strings.Contains(combined, pattern) {
+	return c.Security.engine.MatchesCategory(rules.Request{URI: uri, UserAgent: userAgent}, rules.CategorySQLi)
+}
+
+// IsXSSAttempt reports whether an xss rule matches the request.
+func (c *Config) IsXSSAttempt(uri, userAgent string) bool {
+	return c.Security.engine.MatchesCategory(rules.Request{URI: uri, UserAgent: userAgent}, rules.CategoryXSS)
+}
+
+// IsCrawler reports whether a crawler rule matches userAgent.
+func (c *Config) IsCrawler(userAgent string) bool {
+	return c.Security.engine.MatchesCategory(rules.Request{UserAgent: userAgent}, rules.CategoryCrawler)
+}
+
+// IsAttackTool reports whether a scanner rule matches userAgent.
+func (c *Config) IsAttackTool(userAgent string) bool {
+	return c.Security.engine.MatchesCategory(rules.Request{UserAgent: userAgent}, rules.CategoryScanner)
+}
+
+// SearchEngine returns the configured search-engine pattern matching host, or
+// "" if host is not a search engine.
+func (c *Config) SearchEngine(host string) string {
+	hostLower := strings.ToLower(host)
+	for _, engine := range c.Referer.SearchEngines {
+		if strings.Contains(hostLower, engine) {
+			return engine
+		}
+	}
+	return ""
+}
+
+func (c *Config) IsRefererSpam(host string) bool {
+	hostLower := strings.ToLower(host)
+	for _, pattern := range c.Referer.SpamPatterns {
+		if strings.Contains(hostLower, pattern) {
 			return true
 		}
 	}
 	return false
 }
 
-func (c *Config) IsXSSAttempt(uri, userAgent string) bool {
-	combined := strings.ToLower(uri + " " + userAgent)
-	for _, pattern := range c.Security.XSSPatterns {
-		if strings.Contains(combined, pattern) {
+// IsInternalHost reports whether host belongs to the site itself: the log's
+// domain, a subdomain of it, or one of the configured internal domains.
+// It is called for every referer, so it does not allocate.
+func (c *Config) IsInternalHost(host, siteDomain string) bool {
+	if isDomainOrSubdomain(host, trimWWW(siteDomain)) {
+		return true
+	}
+	for _, d := range c.Referer.internalHosts {
+		if isDomainOrSubdomain(host, d) {
 			return true
 		}
 	}
 	return false
 }
 
-func (c *Config) IsCrawler(userAgent string) bool {
-	userAgentLower := strings.ToLower(userAgent)
-	for _, crawler := range c.Security.CrawlerUserAgents {
-		if strings.Contains(userAgentLower, crawler) {
+// trimWWW removes a leading "www.", ignoring case.
+func trimWWW(domain string) string {
+	if len(domain) >= 4 && strings.EqualFold(domain[:4], "www.") {
+		return domain[4:]
+	}
+	return domain
+}
+
+// isDomainOrSubdomain reports whether host is domain or a subdomain of it,
+// ignoring case. An empty domain matches nothing.
+func isDomainOrSubdomain(host, domain string) bool {
+	if domain == "" || len(host) < len(domain) {
+		return false
+	}
+	suffix := host[len(host)-len(domain):]
+	if !strings.EqualFold(suffix, domain) {
+		return false
+	}
+	return len(host) == len(domain) || host[len(host)-len(domain)-1] == '.'
+}
+
+// IsUnusualMethod reports whether method is listed in unusual_methods
+// (compared case-insensitively).
+func (c *Config) IsUnusualMethod(method string) bool {
+	for _, m := range c.Security.UnusualMethods {
+		if strings.EqualFold(m, method) {
 			return true
 		}
 	}
 	return false
 }
 
-func (c *Config) IsAttackTool(userAgent string) bool {
-	userAgentLower := strings.ToLower(userAgent)
-	for _, tool := range c.Security.AttackToolPatterns {
-		if strings.Contains(userAgentLower, tool) {
+// TracksStatusURLs reports whether per-URL tops should be kept for status
+// code. An empty status_url_codes list tracks every code.
+func (c *Config) TracksStatusURLs(code int) bool {
+	if len(c.Output.StatusURLCodes) == 0 {
+		return true
+	}
+	for _, tracked := range c.Output.StatusURLCodes {
+		if tracked == code {
 			return true
 		}
 	}
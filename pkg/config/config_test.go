@@ -0,0 +1,44 @@
+package config
+
+import "testing"
+
+func TestIsInternalHost(t *testing.T) {
+	cfg, err := LoadConfig("../../config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Referer.internalHosts = internalHosts(toLowerSlice([]string{"WWW.Example.org", "cdn.example.net", ""}))
+
+	tests := []struct {
+		host string
+		site string
+		want bool
+	}{
+		{"example.com", "example.com", true},
+		{"Example.COM", "www.example.com", true},
+		{"www.example.com", "WWW.EXAMPLE.COM", true},
+		{"blog.example.com", "example.com", true},
+		{"badexample.com", "example.com", false},
+		{"example.com.evil.test", "example.com", false},
+		{"com", "example.com", false},
+		{"example.org", "", true},
+		{"shop.example.org", "example.com", true},
+		{"img.cdn.example.net", "example.com", true},
+		{"example.net", "example.com", false},
+		{"google.com", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := cfg.IsInternalHost(tt.host, tt.site); got != tt.want {
+			t.Errorf("IsInternalHost(%q, %q) = %v, want %v", tt.host, tt.site, got, tt.want)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		cfg.IsInternalHost("Shop.Example.org", "www.example.com")
+		cfg.IsInternalHost("google.com", "www.example.com")
+	})
+	if allocs > 0 {
+		t.Errorf("IsInternalHost allocated %.0f times", allocs)
+	}
+}
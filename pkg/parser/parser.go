@@ -1,8 +1,8 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -24,127 +24,349 @@ type LogEntry struct {
 	ResponseTime float64
 }
 
-var (
-	timestampRegex = regexp.MustCompile(`\[([^\]]+)\]`)
-	quotedRegex    = regexp.MustCompile(`"([^"]*)"`)
-	numberRegex    = regexp.MustCompile(`\d+\.?\d*`)
-	ipv4Regex      = regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
-	statusRegex    = regexp.MustCompile(`^\d{3}$`)
-)
-
 var httpMethods = map[string]bool{
 	"GET": true, "POST": true, "PUT": true, "DELETE": true,
 	"HEAD": true, "OPTIONS": true, "PATCH": true, "TRACE": true, "CONNECT": true,
+	// WebDAV — rarely legitimate on WordPress, but scanners probe with them.
+	"PROPFIND": true, "PROPPATCH": true, "MKCOL": true, "COPY": true, "MOVE": true,
+	"LOCK": true, "UNLOCK": true, "SEARCH": true,
 }
 
+const timestampLayout = "02/Jan/2006:15:04:05 -0700"
+
+// ParseLogLine parses line into a new LogEntry. Use ParseInto to parse a
+// stream of lines without allocating an entry per line.
 func ParseLogLine(line string) (*LogEntry, error) {
-	if strings.TrimSpace(line) == "" {
-		return nil, fmt.Errorf("empty log line")
+	entry := &LogEntry{}
+	if err := ParseInto(entry, []byte(line)); err != nil {
+		return nil, err
 	}
+	return entry, nil
+}
+
+// ParseInto parses line into e, overwriting every field. line is not
+// retained, so it may be a reused buffer such as bufio.Scanner.Bytes. When a
+// field has the same value as in e's previous line (domain, method, protocol
+// and often the IPs and User-Agent) the existing string is kept instead of
+// allocating a new one. On error e is left in an unspecified state.
+//
+// Fields are split on ASCII whitespace only.
+func ParseInto(e *LogEntry, line []byte) error {
+	prev := *e
+	*e = LogEntry{}
 
-	parts := strings.Fields(line)
-	if len(parts) < 14 {
-		return nil, fmt.Errorf("insufficient fields in log line: got %d, expected at least 14", len(parts))
+	// One pass over the whitespace-separated fields collects everything
+	// that is located by field: the field count, domain, client IP, the
+	// first HTTP method token with the protocol two fields later (new
+	// format), and the first exact 3-digit token (status code).
+	var (
+		count            int
+		domain, clientIP []byte
+		method, protocol []byte
+		methodField      = -1
+		status           = -1
+	)
+	for i := 0; ; count++ {
+		start, end := nextField(line, i)
+		if start == end {
+			break
+		}
+		i = end
+		field := line[start:end]
+		switch count {
+		case 0:
+			domain = field
+		case 1:
+			clientIP = field
+		}
+		if methodField < 0 && field[0] >= 'A' && field[0] <= 'Z' && httpMethods[string(field)] {
+			methodField = count
+			method = field
+		} else if methodField >= 0 && count == methodField+2 && bytes.HasPrefix(field, []byte("HTTP/")) {
+			protocol = field
+		}
+		if status < 0 && len(field) == 3 && isDigit(field[0]) && isDigit(field[1]) && isDigit(field[2]) {
+			status = int(field[0]-'0')*100 + int(field[1]-'0')*10 + int(field[2]-'0')
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("empty log line")
+	}
+	if count < 14 {
+		return fmt.Errorf("insufficient fields in log line: got %d, expected at least 14", count)
 	}
 
-	entry := &LogEntry{}
-	
 	// Domain
-	entry.Domain = parts[0]
-	
+	e.Domain = reuse(prev.Domain, domain)
+
 	// Client IP
-	entry.ClientIP = parts[1]
-	
-	// Timestamp [22/Sep/2021:21:26:10 +0000]
-	timestampMatch := timestampRegex.FindStringSubmatch(line)
-	if len(timestampMatch) > 1 {
-		timestamp, err := time.Parse("02/Jan/2006:15:04:05 -0700", timestampMatch[1])
+	e.ClientIP = reuse(prev.ClientIP, clientIP)
+
+	// Timestamp [22/Sep/2021:21:26:10 +0000] — the first non-empty bracketed text
+	if ts := bracketed(line); ts != nil {
+		timestamp, err := parseTimestamp(ts)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse timestamp: %v", err)
+			return fmt.Errorf("failed to parse timestamp: %v", err)
 		}
-		entry.Timestamp = timestamp
+		e.Timestamp = timestamp
 	}
 
 	// Method, URI, Protocol — supports both log formats:
 	//   old: "GET /path HTTP/1.1"   (everything inside the first quoted string)
 	//   new: GET "/path" HTTP/1.1   (method/protocol unquoted, URI alone in quotes)
-	quotedMatches := quotedRegex.FindAllStringSubmatch(line, -1)
-	quotedIndex := quotedRegex.FindAllStringSubmatchIndex(line, -1)
-	if len(quotedMatches) >= 3 {
-		requestParts := strings.Fields(quotedMatches[0][1])
-		isOldFormat := len(requestParts) >= 3 && strings.HasPrefix(requestParts[len(requestParts)-1], "HTTP/")
-		if isOldFormat {
-			entry.Method = requestParts[0]
-			entry.URI = strings.Join(requestParts[1:len(requestParts)-1], " ")
-			entry.Protocol = requestParts[len(requestParts)-1]
+	// Quotes pair up in order: request (or URI), referer, User-Agent.
+	var quotes [6]int
+	n := 0
+	for i := 0; i < len(line) && n < len(quotes); i++ {
+		if line[i] == '"' {
+			quotes[n] = i
+			n++
+		}
+	}
+	uaEnd := -1
+	if n == len(quotes) {
+		request := line[quotes[0]+1 : quotes[1]]
+		if m, uri, proto, ok := splitRequest(request); ok {
+			e.Method = reuse(prev.Method, m)
+			e.URI = uri
+			e.Protocol = reuse(prev.Protocol, proto)
 		} else {
-			entry.URI = quotedMatches[0][1]
-			for i, part := range parts {
-				if httpMethods[part] {
-					entry.Method = part
-					if i+2 < len(parts) && strings.HasPrefix(parts[i+2], "HTTP/") {
-						entry.Protocol = parts[i+2]
-					}
-					break
-				}
+			e.URI = string(request)
+			if method != nil {
+				e.Method = reuse(prev.Method, method)
+				e.Protocol = reuse(prev.Protocol, protocol)
 			}
 		}
 
-		entry.Referer = quotedMatches[1][1]
-		entry.UserAgent = quotedMatches[2][1]
+		e.Referer = reuse(prev.Referer, line[quotes[2]+1:quotes[3]])
+		e.UserAgent = reuse(prev.UserAgent, line[quotes[4]+1:quotes[5]])
+		uaEnd = quotes[5] + 1
 	}
 
 	// Status Code — first exact 3-digit token
-	for _, part := range parts {
-		if statusRegex.MatchString(part) {
-			if statusCode, err := strconv.Atoi(part); err == nil {
-				entry.StatusCode = statusCode
-				break
-			}
-		}
+	if status >= 0 {
+		e.StatusCode = status
 	}
 
 	// Real IP — first IPv4 token after the User-Agent's closing quote.
 	// Robust to both formats; the old `parts[statusIdx+2]` heuristic broke
 	// for the new format because the UA (which contains spaces) sits between.
-	if len(quotedIndex) >= 3 {
-		uaEnd := quotedIndex[2][1]
-		for _, p := range strings.Fields(line[uaEnd:]) {
-			if ipv4Regex.MatchString(p) {
-				entry.RealIP = p
+	var realIP []byte
+	if uaEnd >= 0 {
+		for i := uaEnd; ; {
+			start, end := nextField(line, i)
+			if start == end {
+				break
+			}
+			i = end
+			if isIPv4(line[start:end]) {
+				realIP = line[start:end]
+				e.RealIP = reuse(prev.RealIP, realIP)
 				break
 			}
 		}
 	}
 
-	// Upstream URI — first quoted string after Real IP
-	if entry.RealIP != "" {
-		realIPPos := strings.LastIndex(line, entry.RealIP)
-		if realIPPos >= 0 {
-			upstreamMatch := quotedRegex.FindStringSubmatch(line[realIPPos+len(entry.RealIP):])
-			if len(upstreamMatch) > 1 {
-				entry.UpstreamURI = upstreamMatch[1]
+	// Upstream URI — first quoted string after the last occurrence of Real IP
+	if realIP != nil {
+		rest := line[bytes.LastIndex(line, realIP)+len(realIP):]
+		if open := bytes.IndexByte(rest, '"'); open >= 0 {
+			if closing := bytes.IndexByte(rest[open+1:], '"'); closing >= 0 {
+				e.UpstreamURI = reuse(prev.UpstreamURI, rest[open+1:open+1+closing])
 			}
 		}
 	}
 
-	// Response Size and Response Time (last two numeric values)
-	numbers := numberRegex.FindAllString(line, -1)
-	if len(numbers) >= 2 {
-		// Response Size
-		if size, err := strconv.ParseInt(numbers[len(numbers)-2], 10, 64); err == nil {
-			entry.ResponseSize = size
+	// Response Size and Response Time. The time is the last numeric value;
+	// the size is the last integer before it. Lines may carry one or two
+	// trailing timings ("472 0.562 0.560" or "256 1.23"). Numbers are runs of
+	// digits with at most one decimal point, scanned across the whole line.
+	var last, lastInt []byte
+	numbers := 0
+	for i := 0; i < len(line); {
+		if !isDigit(line[i]) {
+			i++
+			continue
+		}
+		j := i + 1
+		for j < len(line) && isDigit(line[j]) {
+			j++
 		}
-		
+		if j < len(line) && line[j] == '.' {
+			j++
+			for j < len(line) && isDigit(line[j]) {
+				j++
+			}
+		}
+		if last != nil && bytes.IndexByte(last, '.') < 0 {
+			lastInt = last
+		}
+		last = line[i:j]
+		numbers++
+		i = j
+	}
+	if numbers >= 2 {
 		// Response Time
-		if respTime, err := strconv.ParseFloat(numbers[len(numbers)-1], 64); err == nil {
-			entry.ResponseTime = respTime
+		if respTime, err := strconv.ParseFloat(string(last), 64); err == nil {
+			e.ResponseTime = respTime
+		}
+
+		// Response Size
+		if lastInt != nil {
+			if size, err := strconv.ParseInt(string(lastInt), 10, 64); err == nil {
+				e.ResponseSize = size
+			}
 		}
 	}
 
-	return entry, nil
+	return nil
 }
 
+// nextField returns the bounds of the first field of line at or after i;
+// start == end when there is none.
+func nextField(line []byte, i int) (start, end int) {
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	start = i
+	for i < len(line) && !isSpace(line[i]) {
+		i++
+	}
+	return start, i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\v' || c == '\f' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// reuse returns prev if it already holds b, so repeated values cost no
+// allocation.
+func reuse(prev string, b []byte) string {
+	if prev == string(b) {
+		return prev
+	}
+	return string(b)
+}
+
+// bracketed returns the text of the first non-empty [...] in line, or nil.
+func bracketed(line []byte) []byte {
+	for i := 0; i < len(line); i++ {
+		if line[i] != '[' {
+			continue
+		}
+		closing := bytes.IndexByte(line[i+1:], ']')
+		if closing < 0 {
+			return nil
+		}
+		if closing > 0 {
+			return line[i+1 : i+1+closing]
+		}
+	}
+	return nil
+}
+
+// splitRequest splits an old-format request line ("GET /path HTTP/1.1")
+// into method, URI and protocol. ok is false for anything else, such as a
+// bare new-format URI. A URI containing spaces is rejoined with single spaces.
+func splitRequest(request []byte) (method []byte, uri string, protocol []byte, ok bool) {
+	var fields [][2]int
+	var buf [4][2]int
+	fields = buf[:0]
+	for i := 0; ; {
+		start, end := nextField(request, i)
+		if start == end {
+			break
+		}
+		i = end
+		fields = append(fields, [2]int{start, end})
+	}
+	if len(fields) < 3 {
+		return nil, "", nil, false
+	}
+	lastField := fields[len(fields)-1]
+	protocol = request[lastField[0]:lastField[1]]
+	if !bytes.HasPrefix(protocol, []byte("HTTP/")) {
+		return nil, "", nil, false
+	}
+	method = request[fields[0][0]:fields[0][1]]
+	middle := fields[1 : len(fields)-1]
+	if len(middle) == 1 {
+		return method, string(request[middle[0][0]:middle[0][1]]), protocol, true
+	}
+	parts := make([]string, len(middle))
+	for i, f := range middle {
+		parts[i] = string(request[f[0]:f[1]])
+	}
+	return method, strings.Join(parts, " "), protocol, true
+}
+
+// isIPv4 reports whether b is four dot-separated groups of 1-3 digits.
+func isIPv4(b []byte) bool {
+	groups, digits := 0, 0
+	for _, c := range b {
+		switch {
+		case isDigit(c):
+			digits++
+			if digits > 3 {
+				return false
+			}
+		case c == '.':
+			if digits == 0 {
+				return false
+			}
+			groups++
+			digits = 0
+		default:
+			return false
+		}
+	}
+	return groups == 3 && digits > 0
+}
+
+var monthAbbrevs = [...]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"}
+
+// parseTimestamp parses ts in timestampLayout. The usual UTC form
+// ("22/Sep/2021:21:26:10 +0000") is decoded by hand; anything else goes
+// through time.Parse, which also produces the error for malformed input.
+func parseTimestamp(ts []byte) (time.Time, error) {
+	if len(ts) == len(timestampLayout) && ts[2] == '/' && ts[6] == '/' && ts[11] == ':' &&
+		ts[14] == ':' && ts[17] == ':' && ts[20] == ' ' && string(ts[21:]) == "+0000" {
+		day, ok1 := twoDigits(ts[0:2])
+		hour, ok2 := twoDigits(ts[12:14])
+		minute, ok3 := twoDigits(ts[15:17])
+		sec, ok4 := twoDigits(ts[18:20])
+		hi, ok5 := twoDigits(ts[7:9])
+		lo, ok6 := twoDigits(ts[9:11])
+		year := hi*100 + lo
+		month := 0
+		for i, name := range monthAbbrevs {
+			if string(ts[3:6]) == name {
+				month = i + 1
+				break
+			}
+		}
+		if ok1 && ok2 && ok3 && ok4 && ok5 && ok6 && month > 0 && hour < 24 && minute < 60 && sec < 60 &&
+			day >= 1 && day <= daysIn(time.Month(month), year) {
+			return time.Date(year, time.Month(month), day, hour, minute, sec, 0, time.UTC), nil
+		}
+	}
+	return time.Parse(timestampLayout, string(ts))
+}
+
+func twoDigits(b []byte) (int, bool) {
+	if !isDigit(b[0]) || !isDigit(b[1]) {
+		return 0, false
+	}
+	return int(b[0]-'0')*10 + int(b[1]-'0'), true
+}
+
+func daysIn(m time.Month, year int) int {
+	return time.Date(year, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
 func (e *LogEntry) IsError() bool {
 	return e.StatusCode >= 400
 }
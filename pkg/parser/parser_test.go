@@ -1,6 +1,10 @@
 package parser
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -73,11 +77,29 @@ func TestParseLogLine_NewFormat(t *testing.T) {
 	if entry.UpstreamURI != "/wp-admin/index.php" {
 		t.Errorf("Expected upstream URI '/wp-admin/index.php', got '%s'", entry.UpstreamURI)
 	}
+	if entry.ResponseSize != 472 {
+		t.Errorf("Expected response size 472, got %d", entry.ResponseSize)
+	}
 	if entry.ResponseTime != 0.560 {
 		t.Errorf("Expected response time 0.560, got %f", entry.ResponseTime)
 	}
 }
 
+func TestParseLogLine_WebDAVMethod(t *testing.T) {
+	logLine := `kinstahelptesting.kinsta.cloud 203.0.113.7 [22/Sep/2021:21:26:10 +0000] PROPFIND "/" HTTP/1.1 405 "-" "Mozilla/5.0" 203.0.113.7 "/index.php" - - 0 0.002 0.002`
+
+	entry, err := ParseLogLine(logLine)
+	if err != nil {
+		t.Fatalf("Failed to parse WebDAV log line: %v", err)
+	}
+	if entry.Method != "PROPFIND" {
+		t.Errorf("Expected method 'PROPFIND', got '%s'", entry.Method)
+	}
+	if entry.Protocol != "HTTP/1.1" {
+		t.Errorf("Expected protocol 'HTTP/1.1', got '%s'", entry.Protocol)
+	}
+}
+
 func TestParseLogLineErrors(t *testing.T) {
 	testCases := []struct {
 		name    string
@@ -129,4 +151,202 @@ func TestLogEntryMethods(t *testing.T) {
 	if entry.IsClientError() {
 		t.Error("Expected IsClientError() to return false for 500")
 	}
-}
\ No newline at end of file
+}
+
+// parseLogLineRegexp is the original regex-based parser, kept as the
+// reference that ParseInto must agree with.
+func parseLogLineRegexp(line string) (*LogEntry, error) {
+	if strings.TrimSpace(line) == "" {
+		return nil, fmt.Errorf("empty log line")
+	}
+
+	parts := strings.Fields(line)
+	if len(parts) < 14 {
+		return nil, fmt.Errorf("insufficient fields in log line: got %d, expected at least 14", len(parts))
+	}
+
+	entry := &LogEntry{Domain: parts[0], ClientIP: parts[1]}
+
+	if m := refTimestampRegex.FindStringSubmatch(line); len(m) > 1 {
+		timestamp, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %v", err)
+		}
+		entry.Timestamp = timestamp
+	}
+
+	quotedMatches := refQuotedRegex.FindAllStringSubmatch(line, -1)
+	quotedIndex := refQuotedRegex.FindAllStringSubmatchIndex(line, -1)
+	if len(quotedMatches) >= 3 {
+		requestParts := strings.Fields(quotedMatches[0][1])
+		if len(requestParts) >= 3 && strings.HasPrefix(requestParts[len(requestParts)-1], "HTTP/") {
+			entry.Method = requestParts[0]
+			entry.URI = strings.Join(requestParts[1:len(requestParts)-1], " ")
+			entry.Protocol = requestParts[len(requestParts)-1]
+		} else {
+			entry.URI = quotedMatches[0][1]
+			for i, part := range parts {
+				if httpMethods[part] {
+					entry.Method = part
+					if i+2 < len(parts) && strings.HasPrefix(parts[i+2], "HTTP/") {
+						entry.Protocol = parts[i+2]
+					}
+					break
+				}
+			}
+		}
+		entry.Referer = quotedMatches[1][1]
+		entry.UserAgent = quotedMatches[2][1]
+	}
+
+	for _, part := range parts {
+		if refStatusRegex.MatchString(part) {
+			if statusCode, err := strconv.Atoi(part); err == nil {
+				entry.StatusCode = statusCode
+				break
+			}
+		}
+	}
+
+	if len(quotedIndex) >= 3 {
+		for _, p := range strings.Fields(line[quotedIndex[2][1]:]) {
+			if refIPv4Regex.MatchString(p) {
+				entry.RealIP = p
+				break
+			}
+		}
+	}
+
+	if entry.RealIP != "" {
+		realIPPos := strings.LastIndex(line, entry.RealIP)
+		if m := refQuotedRegex.FindStringSubmatch(line[realIPPos+len(entry.RealIP):]); len(m) > 1 {
+			entry.UpstreamURI = m[1]
+		}
+	}
+
+	numbers := refNumberRegex.FindAllString(line, -1)
+	if len(numbers) >= 2 {
+		if respTime, err := strconv.ParseFloat(numbers[len(numbers)-1], 64); err == nil {
+			entry.ResponseTime = respTime
+		}
+		for i := len(numbers) - 2; i >= 0; i-- {
+			if strings.Contains(numbers[i], ".") {
+				continue
+			}
+			if size, err := strconv.ParseInt(numbers[i], 10, 64); err == nil {
+				entry.ResponseSize = size
+			}
+			break
+		}
+	}
+
+	return entry, nil
+}
+
+var (
+	refTimestampRegex = regexp.MustCompile(`\[([^\]]+)\]`)
+	refQuotedRegex    = regexp.MustCompile(`"([^"]*)"`)
+	refNumberRegex    = regexp.MustCompile(`\d+\.?\d*`)
+	refIPv4Regex      = regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
+	refStatusRegex    = regexp.MustCompile(`^\d{3}$`)
+)
+
+var parityLines = []string{
+	`kinstahelptesting.kinsta.cloud 98.43.13.94 [22/Sep/2021:21:26:10 +0000] "GET /wp-admin/ HTTP/1.0" 302 "-" "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:92.0) Gecko/20100101 Firefox/92.0" 98.43.13.94 "/wp-admin/index.php" - - 472 0.562 0.560`,
+	`kinstahelptesting.kinsta.cloud 98.43.13.94 [22/Sep/2021:21:26:10 +0000] GET "/wp-admin/" HTTP/1.0 302 "-" "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:92.0) Gecko/20100101 Firefox/92.0" 98.43.13.94 "/wp-admin/index.php" - - 472 0.562 0.560`,
+	`kinstahelptesting.kinsta.cloud 203.0.113.7 [22/Sep/2021:21:26:10 +0000] PROPFIND "/" HTTP/1.1 405 "-" "Mozilla/5.0" 203.0.113.7 "/index.php" - - 0 0.002 0.002`,
+	`example.com 10.0.0.5 [01/Jan/2024:00:00:00 +0900] POST "/wp-login.php?a=1 2" HTTP/2.0 200 "https://example.com/" "curl/8.0" 10.0.0.5 "/wp-login.php" - - 256 1.23`,
+	`example.com 10.0.0.5 [31/Dec/2023:23:59:59 -0500] "GET /a b c HTTP/1.1" 404 "-" "UA with 404 inside" 192.168.0.1 "/index.php" - - 1024 0.5`,
+	`example.com 10.0.0.5 [29/Feb/2024:12:00:00 +0000] GET "/x" HTTP/1.1 200 "-" "UA" 10.0.0.5 "/x" - - 99999999999999999999 1.`,
+	`example.com 10.0.0.5 [] [30/Feb/2023:12:00:00 +0000] GET "/x" HTTP/1.1 200 "-" "UA" 10.0.0.5 "/x" - - 1 2`,
+	`example.com 10.0.0.5 [22/sep/2021:1:02:03 +0000] GET "/x" HTTP/1.1 200 "-" "UA" 10.0.0.5 "/x" - - 1 2`,
+	`example.com 10.0.0.5 no timestamp GET "/x" HTTP/1.1 200 "-" "UA" 1.2.3.4 "/x" - - 1.5 2.5`,
+	`example.com 10.0.0.5 [22/Sep/2021:21:26:10 +0000] GET "/only-one-quote HTTP/1.1 200 - UA 10.0.0.5 x - - 1 2`,
+	`example.com 10.0.0.5 [22/Sep/2021:21:26:10 +0000] GET "/x" HTTP/1.1 200 "-" "UA"10.0.0.5 "/up" - - 12 0.1`,
+	"example.com\t10.0.0.5 [22/Sep/2021:21:26:10 +0000]\tGET \"/x\" HTTP/1.1 200 \"-\" \"UA\" 1.1.1.1 \"/x\" - - 12 0.1 \r",
+	"   ",
+	"domain ip",
+}
+
+func TestParseIntoMatchesRegexpParser(t *testing.T) {
+	var reused LogEntry
+	for _, line := range parityLines {
+		want, wantErr := parseLogLineRegexp(line)
+		got, gotErr := ParseLogLine(line)
+		if (wantErr != nil) != (gotErr != nil) {
+			t.Errorf("%q: error mismatch: want %v, got %v", line, wantErr, gotErr)
+			continue
+		}
+		if wantErr != nil {
+			continue
+		}
+		if !entriesEqual(*want, *got) {
+			t.Errorf("%q:\nwant %+v\ngot  %+v", line, *want, *got)
+		}
+		if err := ParseInto(&reused, []byte(line)); err != nil {
+			t.Fatalf("%q: ParseInto: %v", line, err)
+		}
+		if !entriesEqual(*want, reused) {
+			t.Errorf("%q: reused entry:\nwant %+v\ngot  %+v", line, *want, reused)
+		}
+	}
+}
+
+// entriesEqual compares entries with the timestamp compared as an instant.
+func entriesEqual(a, b LogEntry) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return false
+	}
+	a.Timestamp, b.Timestamp = time.Time{}, time.Time{}
+	return a == b
+}
+
+func TestParseIntoReusesStrings(t *testing.T) {
+	line := []byte(parityLines[1])
+	var entry LogEntry
+	if err := ParseInto(&entry, line); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := ParseInto(&entry, line); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// Only the URI is copied when every other field repeats.
+	if allocs > 1 {
+		t.Errorf("expected at most 1 allocation per repeated line, got %.1f", allocs)
+	}
+}
+
+func BenchmarkParseLogLineRegexp(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := parseLogLineRegexp(parityLines[i%3]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseLogLine(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseLogLine(parityLines[i%3]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseInto(b *testing.B) {
+	lines := make([][]byte, 3)
+	for i := range lines {
+		lines[i] = []byte(parityLines[i])
+	}
+	var entry LogEntry
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ParseInto(&entry, lines[i%3]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
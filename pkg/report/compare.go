@@ -0,0 +1,132 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kinsta-log-analyzer/pkg/analyzer"
+	"kinsta-log-analyzer/pkg/utils"
+)
+
+// GenerateComparisonReport writes a Markdown report of cmp and returns its path.
+func (r *MarkdownReporter) GenerateComparisonReport(cmp *analyzer.Comparison) (string, error) {
+	if err := os.MkdirAll(r.outputDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	timestamp := time.Now().In(utils.JST).Format("20060102_150405")
+	filename := fmt.Sprintf("comparison_report_%s.md", timestamp)
+	filepath := filepath.Join(r.outputDir, filename)
+
+	if err := os.WriteFile(filepath, []byte(r.generateComparisonMarkdown(cmp)), 0644); err != nil {
+		return "", fmt.Errorf("failed to write report file: %v", err)
+	}
+
+	return filepath, nil
+}
+
+func (r *MarkdownReporter) generateComparisonMarkdown(cmp *analyzer.Comparison) string {
+	var sb strings.Builder
+
+	sb.WriteString("# Kinsta アクセスログ比較レポート\n\n")
+	sb.WriteString(fmt.Sprintf("**生成日時:** %s (JST)\n\n", time.Now().In(utils.JST).Format("2006-01-02 15:04:05")))
+
+	sb.WriteString("## 比較対象\n\n")
+	sb.WriteString("| | 対象 | 期間 (JST) |\n")
+	sb.WriteString("|------|------|------|\n")
+	sb.WriteString(fmt.Sprintf("| 比較元 (Before) | %s | %s |\n", cmp.BeforeLabel, formatPeriod(cmp.Before)))
+	sb.WriteString(fmt.Sprintf("| 比較先 (After) | %s | %s |\n\n", cmp.AfterLabel, formatPeriod(cmp.After)))
+
+	sb.WriteString("## 主要指標の変化\n\n")
+	sb.WriteString("| 指標 | Before | After | 変化 |\n")
+	sb.WriteString("|------|--------|-------|------|\n")
+	writeDeltaRow(&sb, "総リクエスト数", cmp.Requests, "%.0f", "")
+	writeDeltaRow(&sb, "リクエスト数/時間", cmp.RequestsPerHour, "%.1f", "")
+	writeDeltaRow(&sb, "エラー率", cmp.ErrorRate, "%.2f", "%")
+	writeDeltaRow(&sb, "平均レスポンス時間", cmp.AvgResponseTime, "%.3f", "秒")
+	writeDeltaRow(&sb, "50パーセンタイル", cmp.Percentile50, "%.3f", "秒")
+	writeDeltaRow(&sb, "95パーセンタイル", cmp.Percentile95, "%.3f", "秒")
+	writeDeltaRow(&sb, "99パーセンタイル", cmp.Percentile99, "%.3f", "秒")
+	sb.WriteString("\n*エラー率の変化はパーセントポイント（pt）で表示*\n\n")
+
+	sb.WriteString("## ステータスコードの変化\n\n")
+	if len(cmp.StatusShifts) > 0 {
+		sb.WriteString("| ステータスコード | Before | After | シェア変化 |\n")
+		sb.WriteString("|-------------|--------|-------|----------|\n")
+		for _, s := range cmp.StatusShifts {
+			sb.WriteString(fmt.Sprintf("| %d %s | %s (%.2f%%) | %s (%.2f%%) | %+.2fpt |\n",
+				s.Code, getStatusText(s.Code),
+				utils.FormatNumber(s.Before), s.BeforeShare,
+				utils.FormatNumber(s.After), s.AfterShare,
+				s.ShareChange))
+		}
+	} else {
+		sb.WriteString("ステータスコードのデータがありません。\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("## エラーURLの変化\n\n")
+	sb.WriteString("### 新たに上位に現れたエラーURL\n\n")
+	writeURLList(&sb, cmp.NewErrorURLs, "エラー", "新たに現れたエラーURLはありません。")
+	sb.WriteString("### 上位から消えたエラーURL\n\n")
+	writeURLList(&sb, cmp.DisappearedErrorURLs, "エラー（Before）", "上位から消えたエラーURLはありません。")
+	sb.WriteString("*各期間のエラー頻発URL上位リスト同士の比較です*\n\n")
+
+	sb.WriteString("## 新たな攻撃元IP\n\n")
+	if len(cmp.NewAttackingIPs) > 0 {
+		sb.WriteString("Before では攻撃（SQLi/XSS/パストラバーサル/LFI/RFI）が見られなかったIPです。\n\n")
+		sb.WriteString("| IP | SQLi試行 | XSS試行 | トラバーサル試行 | LFI試行 | RFI試行 | 総リクエスト | スコア |\n")
+		sb.WriteString("|------|---------|--------|--------------|--------|--------|-----------|-------|\n")
+		for _, ip := range cmp.NewAttackingIPs {
+			sb.WriteString(fmt.Sprintf("| %s | %d | %d | %d | %d | %d | %s | %d |\n",
+				ip.IP, ip.SQLAttempts, ip.XSSAttempts, ip.TraversalAttempts, ip.LFIAttempts, ip.RFIAttempts,
+				utils.FormatNumber(ip.TotalRequests), ip.AttackScore))
+		}
+	} else {
+		sb.WriteString("新たな攻撃元IPはありません。\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("\n---\n")
+	sb.WriteString("🤖 Generated with [Claude Code](https://claude.ai/code)\n")
+
+	return sb.String()
+}
+
+func formatPeriod(s analyzer.Summary) string {
+	if s.TotalRequests == 0 {
+		return "（リクエストなし）"
+	}
+	return fmt.Sprintf("%s - %s",
+		s.StartTime.In(utils.JST).Format("2006-01-02 15:04:05"),
+		s.EndTime.In(utils.JST).Format("2006-01-02 15:04:05"))
+}
+
+// writeDeltaRow emits one metric row. The change column is absolute (in
+// percentage points when unit is "%") followed by the relative change when the
+// baseline is non-zero.
+func writeDeltaRow(sb *strings.Builder, label string, d analyzer.MetricDelta, format, unit string) {
+	changeUnit := unit
+	if unit == "%" {
+		changeUnit = "pt"
+	}
+	change := fmt.Sprintf("%+"+format[1:]+"%s", d.Change, changeUnit)
+	if d.Before != 0 {
+		change += fmt.Sprintf(" (%+.1f%%)", d.ChangePercent)
+	}
+	sb.WriteString(fmt.Sprintf("| %s | "+format+"%s | "+format+"%s | %s |\n", label, d.Before, unit, d.After, unit, change))
+}
+
+func writeURLList(sb *strings.Builder, urls []analyzer.URLError, suffix, empty string) {
+	if len(urls) == 0 {
+		sb.WriteString(empty + "\n\n")
+		return
+	}
+	for i, u := range urls {
+		sb.WriteString(fmt.Sprintf("%d. `%s`: %s%s\n", i+1, u.URL, formatCount(u.Count, u.ErrorBound), suffix))
+	}
+	sb.WriteString("\n")
+}
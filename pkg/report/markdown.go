@@ -3,11 +3,14 @@ package report
 import (
 	"fmt"
 	"os"
+	"net/http"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
 	"kinsta-log-analyzer/pkg/analyzer"
+	"kinsta-log-analyzer/pkg/rules"
 	"kinsta-log-analyzer/pkg/utils"
 )
 
@@ -50,6 +53,9 @@ func (r *MarkdownReporter) generateMarkdown(result *analyzer.AnalysisResult) str
 	sb.WriteString("# Kinsta アクセスログ解析レポート\n\n")
 	sb.WriteString(fmt.Sprintf("**生成日時:** %s (JST)\n\n", time.Now().In(utils.JST).Format("2006-01-02 15:04:05")))
 
+	// Sensitive file exposure comes first: a leak needs action before anything else
+	r.writeExposure(&sb, result.Exposure)
+
 	// Summary section
 	r.writeSummary(&sb, result.Summary)
 
@@ -59,12 +65,42 @@ func (r *MarkdownReporter) generateMarkdown(result *analyzer.AnalysisResult) str
 	// Security Analysis section
 	r.writeSecurityAnalysis(&sb, result.SecurityAnalysis)
 
+	// WordPress section
+	r.writeWordPressAnalysis(&sb, result.WordPress)
+
+	// Rate limit recommendations section
+	r.writeRateLimits(&sb, result.RateLimits)
+
 	// Statistics section
 	r.writeStatistics(&sb, result.Statistics)
 
 	// User Agent Analysis section
 	r.writeUserAgentAnalysis(&sb, result.UserAgentAnalysis)
 
+	// Method / Protocol section
+	r.writeMethodAnalysis(&sb, result.Methods)
+
+	// Redirect Analysis section
+	r.writeRedirectAnalysis(&sb, result.Redirects)
+
+	// Upstream Analysis section
+	r.writeUpstreamAnalysis(&sb, result.Upstream)
+
+	// Referer Analysis section
+	r.writeRefererAnalysis(&sb, result.Referers)
+
+	// Unique Counts section
+	r.writeUniques(&sb, result.Uniques)
+
+	// Session Analysis section
+	r.writeSessions(&sb, result.Sessions)
+
+	// Anomaly Detection section
+	r.writeAnomalies(&sb, result.Anomalies)
+
+	// Baseline (previous runs) section
+	r.writeBaseline(&sb, result.Baseline)
+
 	// Footer
 	sb.WriteString("\n---\n")
 	sb.WriteString("🤖 Generated with [Claude Code](https://claude.ai/code)\n")
@@ -72,14 +108,115 @@ func (r *MarkdownReporter) generateMarkdown(result *analyzer.AnalysisResult) str
 	return sb.String()
 }
 
+func (r *MarkdownReporter) writeExposure(sb *strings.Builder, exp analyzer.ExposureAnalysis) {
+	sb.WriteString("## 🚨 機密ファイルの露出（重大度: 高）\n\n")
+	if exp.Requests == 0 {
+		sb.WriteString("機密ファイル（.env、.git/、wp-config.php.bak、*.sql、debug.log、バックアップ等）へのリクエストはありませんでした。\n\n")
+		return
+	}
+
+	sb.WriteString("### 漏洩した可能性のあるファイル\n\n")
+	if len(exp.Leaks) == 0 {
+		sb.WriteString(fmt.Sprintf("機密ファイルへのリクエストに %s バイト以上の 2xx を返したものはありませんでした。\n\n", utils.FormatNumber(int(exp.MinBytes))))
+	} else {
+		sb.WriteString(fmt.Sprintf("**⚠️ 以下のファイルは %s バイト以上の 2xx を返しており、内容が取得された可能性があります。", utils.FormatNumber(int(exp.MinBytes))))
+		sb.WriteString("直ちにファイルを削除またはアクセスを禁止し、含まれていた認証情報（DB パスワード、API キー等）を変更してください。**\n\n")
+		sb.WriteString("| パス | 重大度 | ルールID | 2xx 応答 | 最大サイズ | 初回漏洩 (JST) | 取得したIP |\n")
+		sb.WriteString("|------|-------|---------|-------:|---------:|--------------|-----------|\n")
+		for _, f := range exp.Leaks {
+			sb.WriteString(fmt.Sprintf("| `%s` | %s | %s | %s | %s | %s | %s |\n", tableCell(f.Path), severityLabel(f.Severity),
+				f.RuleID, utils.FormatNumber(f.Leaks), utils.FormatBytes(f.MaxBytes), formatSeen(f.FirstLeak),
+				strings.Join(f.LeakIPs, "、")))
+		}
+		sb.WriteString("\n")
+	}
+	if exp.Rewritten > 0 {
+		sb.WriteString(fmt.Sprintf("このほか %s 件は 2xx でしたが、別の PHP スクリプト（WordPress 等）が応答しておりファイル本体ではないため除外しています。\n\n",
+			utils.FormatNumber(exp.Rewritten)))
+	}
+
+	sb.WriteString("### 探索された機密ファイル\n\n")
+	sb.WriteString(fmt.Sprintf("- **リクエスト数:** %s（%s パス、ユニークIP（推定）: %s）\n",
+		utils.FormatNumber(exp.Requests), utils.FormatNumber(exp.Paths), formatUniqueEstimate(exp.UniqueIPs)))
+	if len(exp.TopIPs) > 0 {
+		sb.WriteString(fmt.Sprintf("- **主要IP:** %s\n", formatIPCounts(exp.TopIPs)))
+	}
+	sb.WriteString("\n")
+	sb.WriteString("| # | パス | 重大度 | ルールID | リクエスト | 漏洩 | 初回 (JST) | 最終 (JST) |\n")
+	sb.WriteString("|---|------|-------|---------|---------:|-----:|-----------|-----------|\n")
+	for i, f := range exp.Files {
+		sb.WriteString(fmt.Sprintf("| %d | `%s` | %s | %s | %s | %s | %s | %s |\n", i+1, tableCell(f.Path), severityLabel(f.Severity),
+			f.RuleID, utils.FormatNumber(f.Requests), utils.FormatNumber(f.Leaks), formatSeen(f.FirstSeen), formatSeen(f.LastSeen)))
+	}
+	sb.WriteString("\n")
+}
+
 func (r *MarkdownReporter) writeSummary(sb *strings.Builder, summary analyzer.Summary) {
 	sb.WriteString("## 概要\n\n")
 	sb.WriteString(fmt.Sprintf("- **解析期間:** %s - %s (JST)\n",
 		summary.StartTime.In(utils.JST).Format("2006-01-02 15:04:05"),
 		summary.EndTime.In(utils.JST).Format("2006-01-02 15:04:05")))
 	sb.WriteString(fmt.Sprintf("- **総リクエスト数:** %s\n", utils.FormatNumber(summary.TotalRequests)))
+	writeStatusClasses(sb, summary.TotalRequests, summary.StatusClasses)
 	sb.WriteString(fmt.Sprintf("- **エラー率:** %.2f%%\n", summary.ErrorRate))
-	sb.WriteString(fmt.Sprintf("- **平均レスポンス時間:** %.3f秒\n\n", summary.AvgResponseTime))
+	sb.WriteString(fmt.Sprintf("- **平均レスポンス時間:** %.3f秒\n", summary.AvgResponseTime))
+	sb.WriteString(fmt.Sprintf("- **ユニークIP数（推定）:** %s\n", formatUniqueEstimate(summary.Uniques.IPs)))
+	sb.WriteString(fmt.Sprintf("- **ユニーク訪問者数（IP+UA、推定）:** %s\n", formatUniqueEstimate(summary.Uniques.Visitors)))
+	sb.WriteString(fmt.Sprintf("- **ユニークURL数（推定）:** %s\n\n", formatUniqueEstimate(summary.Uniques.URLs)))
+
+	writeClassSummaries(sb, summary.ByClass)
+}
+
+func writeStatusClasses(sb *strings.Builder, total int, classes analyzer.StatusClassCounts) {
+	if total == 0 {
+		return
+	}
+	for _, row := range []struct {
+		label string
+		count int
+	}{
+		{"1xx（情報）", classes.Informational},
+		{"2xx（成功）", classes.Success},
+		{"3xx（リダイレクト）", classes.Redirection},
+		{"4xx（クライアントエラー）", classes.ClientError},
+		{"5xx（サーバーエラー）", classes.ServerError},
+		{"その他", classes.Other},
+	} {
+		if row.count == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  - %s: %s (%.2f%%)\n", row.label, utils.FormatNumber(row.count), float64(row.count)/float64(total)*100))
+	}
+}
+
+var classLabels = map[analyzer.RequestClass]string{
+	analyzer.ClassDynamic: "動的ページ (PHP)",
+	analyzer.ClassStatic:  "静的アセット",
+	analyzer.ClassREST:    "REST API",
+	analyzer.ClassAdmin:   "管理画面",
+	analyzer.ClassFeed:    "フィード",
+	analyzer.ClassSitemap: "サイトマップ",
+	analyzer.ClassLogin:   "ログイン (POST・xmlrpc.php)",
+}
+
+func writeClassSummaries(sb *strings.Builder, classes []analyzer.ClassSummary) {
+	if len(classes) == 0 {
+		return
+	}
+	sb.WriteString("### リクエスト分類別\n\n")
+	sb.WriteString("| 分類 | リクエスト | 割合 | エラー率 | 4xx | 5xx | 平均レスポンス | 95パーセンタイル | 遅いリクエスト | ユニークIP（推定） |\n")
+	sb.WriteString("|------|---------:|-----:|-------:|----:|----:|-------------:|---------------:|------------:|----------------:|\n")
+	for _, c := range classes {
+		sb.WriteString(fmt.Sprintf("| %s | %s | %.1f%% | %.2f%% | %s | %s | %.3f秒 | %.3f秒 | %s | %s |\n",
+			classLabels[c.Class], utils.FormatNumber(c.TotalRequests), c.Share, c.ErrorRate,
+			utils.FormatNumber(c.ClientErrors), utils.FormatNumber(c.ServerErrors),
+			c.AvgResponseTime, c.Percentile95, utils.FormatNumber(c.SlowRequests), formatUniqueEstimate(c.UniqueIPs)))
+	}
+	sb.WriteString("\n")
+}
+
+func formatUniqueEstimate(e analyzer.UniqueEstimate) string {
+	return fmt.Sprintf("%s（±%s）", utils.FormatNumber(e.Estimate), utils.FormatNumber(e.ErrorMargin))
 }
 
 func (r *MarkdownReporter) writeHTTPErrors(sb *strings.Builder, errors analyzer.HTTPErrors) {
@@ -88,7 +225,8 @@ func (r *MarkdownReporter) writeHTTPErrors(sb *strings.Builder, errors analyzer.
 	// 4xx Errors
 	sb.WriteString("### 4xxエラー（クライアントエラー）\n\n")
 	if len(errors.ClientErrors) > 0 {
-		for status, count := range errors.ClientErrors {
+		for _, status := range sortedStatusCodes(errors.ClientErrors) {
+			count := errors.ClientErrors[status]
 			statusText := getStatusText(status)
 			sb.WriteString(fmt.Sprintf("- **%d %s:** %sリクエスト\n", status, statusText, utils.FormatNumber(count)))
 		}
@@ -100,7 +238,8 @@ func (r *MarkdownReporter) writeHTTPErrors(sb *strings.Builder, errors analyzer.
 	// 5xx Errors
 	sb.WriteString("### 5xxエラー（サーバーエラー）\n\n")
 	if len(errors.ServerErrors) > 0 {
-		for status, count := range errors.ServerErrors {
+		for _, status := range sortedStatusCodes(errors.ServerErrors) {
+			count := errors.ServerErrors[status]
 			statusText := getStatusText(status)
 			sb.WriteString(fmt.Sprintf("- **%d %s:** %sリクエスト\n", status, statusText, utils.FormatNumber(count)))
 		}
@@ -113,32 +252,36 @@ func (r *MarkdownReporter) writeHTTPErrors(sb *strings.Builder, errors analyzer.
 	sb.WriteString("### エラー頻発URL（上位）\n\n")
 	if len(errors.TopErrorURLs) > 0 {
 		for i, urlError := range errors.TopErrorURLs {
-			sb.WriteString(fmt.Sprintf("%d. `%s`: %sエラー\n", i+1, urlError.URL, utils.FormatNumber(urlError.Count)))
+			sb.WriteString(fmt.Sprintf("%d. `%s`: %sエラー\n", i+1, urlError.URL, formatCount(urlError.Count, urlError.ErrorBound)))
 		}
 	} else {
 		sb.WriteString("エラーURLは見つかりませんでした。\n")
 	}
 	sb.WriteString("\n")
 
-	r.writeErrorURLsByStatus(sb, errors.ErrorURLsByStatus)
+	r.writeURLsByStatus(sb, errors.URLsByStatus)
 }
 
-func (r *MarkdownReporter) writeErrorURLsByStatus(sb *strings.Builder, byStatus map[int][]analyzer.URLError) {
-	sb.WriteString("### ステータスコード別 エラーURL Top\n\n")
+func (r *MarkdownReporter) writeURLsByStatus(sb *strings.Builder, byStatus map[int][]analyzer.URLError) {
+	sb.WriteString("### ステータスコード別 URL Top\n\n")
 	if len(byStatus) == 0 {
-		sb.WriteString("対象ステータスコードのエラーは検出されませんでした。\n\n")
+		sb.WriteString("対象ステータスコードのリクエストは検出されませんでした。\n\n")
 		return
 	}
-	// Display in a fixed, expected order so output is deterministic.
-	order := []int{404, 500, 502, 503, 504}
-	for _, code := range order {
-		urls, ok := byStatus[code]
-		if !ok || len(urls) == 0 {
+	// Display in ascending code order so output is deterministic.
+	codes := make([]int, 0, len(byStatus))
+	for code := range byStatus {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		urls := byStatus[code]
+		if len(urls) == 0 {
 			continue
 		}
 		sb.WriteString(fmt.Sprintf("#### %d %s\n\n", code, getStatusText(code)))
 		for i, u := range urls {
-			sb.WriteString(fmt.Sprintf("%d. `%s`: %s件\n", i+1, u.URL, utils.FormatNumber(u.Count)))
+			sb.WriteString(fmt.Sprintf("%d. `%s`: %s件\n", i+1, u.URL, formatCount(u.Count, u.ErrorBound)))
 		}
 		sb.WriteString("\n")
 	}
@@ -147,37 +290,19 @@ func (r *MarkdownReporter) writeErrorURLsByStatus(sb *strings.Builder, byStatus
 func (r *MarkdownReporter) writeSecurityAnalysis(sb *strings.Builder, security analyzer.SecurityAnalysis) {
 	sb.WriteString("## セキュリティ分析\n\n")
 
-	// SQL Injection
-	sb.WriteString("### SQLインジェクション攻撃\n\n")
-	sb.WriteString(fmt.Sprintf("- **攻撃試行数:** %s\n", utils.FormatNumber(security.SQLInjectionAttempts)))
-	
-	if security.SQLInjectionAttempts > 0 {
-		sb.WriteString("- **主要攻撃IP:**\n")
-		count := 0
-		for _, ip := range security.SuspiciousIPs {
-			if ip.SQLAttempts > 0 && count < 5 {
-				sb.WriteString(fmt.Sprintf("  - %s: %d回の攻撃\n", ip.IP, ip.SQLAttempts))
-				count++
-			}
-		}
-	}
-	sb.WriteString("\n")
+	r.writeDetectionCategories(sb, security.Categories)
+	r.writeCVERules(sb, security.CVERules)
 
-	// XSS Attempts
-	sb.WriteString("### XSS攻撃\n\n")
-	sb.WriteString(fmt.Sprintf("- **攻撃試行数:** %s\n", utils.FormatNumber(security.XSSAttempts)))
-	
-	if security.XSSAttempts > 0 {
-		sb.WriteString("- **主要攻撃IP:**\n")
-		count := 0
-		for _, ip := range security.SuspiciousIPs {
-			if ip.XSSAttempts > 0 && count < 5 {
-				sb.WriteString(fmt.Sprintf("  - %s: %d回の攻撃\n", ip.IP, ip.XSSAttempts))
-				count++
-			}
-		}
-	}
-	sb.WriteString("\n")
+	writeAttackSection(sb, "SQLインジェクション攻撃", security.SQLInjectionAttempts, security.SuspiciousIPs,
+		func(ip analyzer.SuspiciousIP) int { return ip.SQLAttempts })
+	writeAttackSection(sb, "XSS攻撃", security.XSSAttempts, security.SuspiciousIPs,
+		func(ip analyzer.SuspiciousIP) int { return ip.XSSAttempts })
+	writeAttackSection(sb, "パストラバーサル", security.PathTraversalAttempts, security.SuspiciousIPs,
+		func(ip analyzer.SuspiciousIP) int { return ip.TraversalAttempts })
+	writeAttackSection(sb, "ローカルファイルインクルード (LFI)", security.LFIAttempts, security.SuspiciousIPs,
+		func(ip analyzer.SuspiciousIP) int { return ip.LFIAttempts })
+	writeAttackSection(sb, "リモートファイルインクルード (RFI)", security.RFIAttempts, security.SuspiciousIPs,
+		func(ip analyzer.SuspiciousIP) int { return ip.RFIAttempts })
 
 	// Suspicious IPs (Recommended for blocking)
 	sb.WriteString("### 疑わしいIP（ブロック推奨）\n\n")
@@ -193,6 +318,15 @@ func (r *MarkdownReporter) writeSecurityAnalysis(sb *strings.Builder, security a
 			if ip.XSSAttempts > 0 {
 				reasons = append(reasons, fmt.Sprintf("XSS: %d回", ip.XSSAttempts))
 			}
+			if ip.TraversalAttempts > 0 {
+				reasons = append(reasons, fmt.Sprintf("パストラバーサル: %d回", ip.TraversalAttempts))
+			}
+			if ip.LFIAttempts > 0 {
+				reasons = append(reasons, fmt.Sprintf("LFI: %d回", ip.LFIAttempts))
+			}
+			if ip.RFIAttempts > 0 {
+				reasons = append(reasons, fmt.Sprintf("RFI: %d回", ip.RFIAttempts))
+			}
 			sb.WriteString(fmt.Sprintf("%d. **%s** - %s（総リクエスト数: %d）\n",
 				i+1, ip.IP, strings.Join(reasons, "、"), ip.TotalRequests))
 		}
@@ -201,11 +335,228 @@ func (r *MarkdownReporter) writeSecurityAnalysis(sb *strings.Builder, security a
 	}
 	sb.WriteString("\n")
 
+	r.writeDetectionRules(sb, security.Rules)
+	r.writeCRSImport(sb, security.CRSImport)
 	r.writeErrorSuspiciousIPs(sb, security.ErrorSuspiciousIPs)
 	r.writeErrorProneIPs(sb, security.ErrorProneIPs)
 	r.writeBurstIPs(sb, security.BurstIPs)
 }
 
+// writeAttackSection writes the attempt count of one attack type and the
+// five suspicious IPs with the most attempts of it.
+func writeAttackSection(sb *strings.Builder, title string, total int, ips []analyzer.SuspiciousIP, attempts func(analyzer.SuspiciousIP) int) {
+	sb.WriteString(fmt.Sprintf("### %s\n\n", title))
+	sb.WriteString(fmt.Sprintf("- **攻撃試行数:** %s\n", utils.FormatNumber(total)))
+
+	if total > 0 {
+		var attackers []analyzer.SuspiciousIP
+		for _, ip := range ips {
+			if attempts(ip) > 0 {
+				attackers = append(attackers, ip)
+			}
+		}
+		sort.SliceStable(attackers, func(i, j int) bool { return attempts(attackers[i]) > attempts(attackers[j]) })
+		sb.WriteString("- **主要攻撃IP:**\n")
+		for i, ip := range attackers {
+			if i >= 5 {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("  - %s: %d回の攻撃\n", ip.IP, attempts(ip)))
+		}
+	}
+	sb.WriteString("\n")
+}
+
+var categoryLabels = map[string]string{
+	rules.CategorySQLi:       "SQLインジェクション",
+	rules.CategoryXSS:        "XSS",
+	rules.CategoryTraversal:  "パストラバーサル",
+	rules.CategoryLFI:        "ローカルファイルインクルード (LFI)",
+	rules.CategoryRFI:        "リモートファイルインクルード (RFI)",
+	rules.CategoryRCE:        "PHP コード実行 (RCE)",
+	rules.CategoryCmdi:       "OSコマンドインジェクション",
+	rules.CategoryLog4Shell:  "Log4Shell (JNDI インジェクション)",
+	rules.CategoryShellshock: "Shellshock",
+	rules.CategoryExposure:   "機密ファイルの露出",
+	rules.CategoryScanner:    "攻撃・スキャンツール",
+	rules.CategoryCrawler:    "クローラー",
+}
+
+func categoryLabel(category string) string {
+	if label, ok := categoryLabels[category]; ok {
+		return label
+	}
+	return category
+}
+
+var severityLabels = map[rules.Severity]string{
+	rules.SeverityCritical: "🔴 critical",
+	rules.SeverityHigh:     "🟠 high",
+	rules.SeverityMedium:   "🟡 medium",
+	rules.SeverityLow:      "🔵 low",
+	rules.SeverityInfo:     "⚪ info",
+}
+
+func severityLabel(s rules.Severity) string {
+	if label, ok := severityLabels[s]; ok {
+		return label
+	}
+	if s == "" {
+		return "-"
+	}
+	return string(s)
+}
+
+// tableCell makes s safe to put inside a Markdown table cell.
+func tableCell(s string) string {
+	return strings.NewReplacer("|", "\\|", "`", "'", "\n", " ").Replace(s)
+}
+
+func (r *MarkdownReporter) writeDetectionCategories(sb *strings.Builder, categories []analyzer.CategoryHit) {
+	sb.WriteString("### カテゴリ別の検出\n\n")
+	if len(categories) == 0 {
+		sb.WriteString("検出ルールに一致したリクエストはありませんでした。\n\n")
+		return
+	}
+	sb.WriteString("| カテゴリ | 最大重大度 | リクエスト | 一致ルール数 | ユニークIP（推定） | 初検出 (JST) | 最終検出 (JST) | 主要IP |\n")
+	sb.WriteString("|---------|----------|---------:|----------:|----------------:|------------|--------------|-------|\n")
+	for _, c := range categories {
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %d | %s | %s | %s | %s |\n",
+			categoryLabel(c.Category), severityLabel(c.Severity), utils.FormatNumber(c.Requests),
+			c.Rules, formatUniqueEstimate(c.UniqueIPs), formatSeen(c.FirstSeen), formatSeen(c.LastSeen),
+			formatIPCounts(c.TopIPs)))
+	}
+	sb.WriteString("\n")
+}
+
+func (r *MarkdownReporter) writeCVERules(sb *strings.Builder, hits []analyzer.RuleHit) {
+	if len(hits) == 0 {
+		return
+	}
+	sb.WriteString("### 既知の脆弱性（CVE）を狙った攻撃\n\n")
+	sb.WriteString("CVE が設定された検出ルールの一覧です。「未検出」はその期間に該当する攻撃がなかったことを示します。\n\n")
+	sb.WriteString("| CVE | ルールID | 説明 | 検出数 | 初検出 (JST) | 最終検出 (JST) | 送信元IP |\n")
+	sb.WriteString("|-----|---------|------|------:|------------|--------------|---------|\n")
+	for _, h := range hits {
+		if h.Hits == 0 {
+			sb.WriteString(fmt.Sprintf("| %s | %s | %s | 未検出 | - | - | - |\n",
+				strings.Join(h.CVE, ", "), h.ID, tableCell(h.Description)))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s |\n",
+			strings.Join(h.CVE, ", "), h.ID, tableCell(h.Description), utils.FormatNumber(h.Hits),
+			formatSeen(h.FirstSeen), formatSeen(h.LastSeen), formatIPCounts(h.TopIPs)))
+	}
+	sb.WriteString("\n")
+}
+
+// formatSeen formats a first/last detection time in JST.
+func formatSeen(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.In(utils.JST).Format("2006-01-02 15:04")
+}
+
+func formatIPCounts(ips []analyzer.IPCount) string {
+	parts := make([]string, len(ips))
+	for i, ip := range ips {
+		parts[i] = fmt.Sprintf("%s (%s)", ip.IP, formatCount(ip.Count, ip.ErrorBound))
+	}
+	return strings.Join(parts, "、")
+}
+
+func (r *MarkdownReporter) writeDetectionRules(sb *strings.Builder, hits []analyzer.RuleHit) {
+	if len(hits) == 0 {
+		return
+	}
+	sb.WriteString("### ルール別の検出\n\n")
+	sb.WriteString("検出数の多い低重大度ルールは誤検知の可能性があります。`config.yaml` の `security.rules` で調整してください。\n\n")
+	sb.WriteString("| ルールID | カテゴリ | 重大度 | 検出数 | ユニークIP（推定） | 説明 | 一致例 |\n")
+	sb.WriteString("|---------|---------|-------|------:|----------------:|------|-------|\n")
+	for _, h := range hits {
+		example := h.Example
+		if len(example) > 80 {
+			example = example[:77] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s: `%s` |\n",
+			h.ID, categoryLabel(h.Category), severityLabel(h.Severity), utils.FormatNumber(h.Hits),
+			formatUniqueEstimate(h.UniqueIPs), tableCell(h.Description), h.Field, tableCell(example)))
+	}
+	sb.WriteString("\n")
+}
+
+var skipReasonLabels = map[rules.SkipReason]string{
+	rules.SkipOperator:  "未対応のオペレーター",
+	rules.SkipNegated:   "否定オペレーター（!@rx 等）",
+	rules.SkipVariables: "アクセスログにない変数",
+	rules.SkipChain:     "chain ルール",
+	rules.SkipRegex:     "Go で使えない正規表現",
+	rules.SkipDuplicate: "ID の重複",
+	rules.SkipInvalid:   "不正な記述",
+}
+
+// skipReasonOrder fixes the display order of skip reasons.
+var skipReasonOrder = []rules.SkipReason{
+	rules.SkipOperator, rules.SkipVariables, rules.SkipRegex, rules.SkipChain,
+	rules.SkipNegated, rules.SkipDuplicate, rules.SkipInvalid,
+}
+
+func (r *MarkdownReporter) writeCRSImport(sb *strings.Builder, imp *rules.ImportReport) {
+	if imp == nil {
+		return
+	}
+	sb.WriteString("### OWASP CRS ルールの取り込み\n\n")
+	files := make([]string, len(imp.Files))
+	for i, f := range imp.Files {
+		files[i] = "`" + filepath.Base(f) + "`"
+	}
+	sb.WriteString(fmt.Sprintf("- **ファイル:** %d件（%s）\n", len(imp.Files), strings.Join(files, "、")))
+	sb.WriteString(fmt.Sprintf("- **取り込んだルール:** %s件\n", utils.FormatNumber(imp.Imported)))
+	sb.WriteString(fmt.Sprintf("- **スキップしたルール:** %s件\n\n", utils.FormatNumber(len(imp.Skipped))))
+	if len(imp.Skipped) == 0 {
+		return
+	}
+
+	byReason := make(map[rules.SkipReason][]rules.Skipped)
+	for _, s := range imp.Skipped {
+		byReason[s.Reason] = append(byReason[s.Reason], s)
+	}
+	sb.WriteString("| 理由 | 件数 | ルールID | 内訳 |\n")
+	sb.WriteString("|------|-----:|---------|------|\n")
+	for _, reason := range skipReasonOrder {
+		skipped := byReason[reason]
+		if len(skipped) == 0 {
+			continue
+		}
+		var ids []string
+		details := make(map[string]int)
+		for _, s := range skipped {
+			if s.ID != "" && len(ids) < 10 {
+				ids = append(ids, s.ID)
+			}
+			if s.Detail != "" && (reason == rules.SkipOperator || reason == rules.SkipVariables) {
+				details[s.Detail]++
+			}
+		}
+		idList := strings.Join(ids, ", ")
+		if len(skipped) > len(ids) {
+			idList = strings.TrimSpace(idList + fmt.Sprintf(" 他%d件", len(skipped)-len(ids)))
+		}
+		var breakdown []string
+		for i, d := range sortedKeysByCount(details) {
+			if i >= 5 {
+				breakdown = append(breakdown, "...")
+				break
+			}
+			breakdown = append(breakdown, fmt.Sprintf("`%s` (%d)", tableCell(d), details[d]))
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n",
+			skipReasonLabels[reason], utils.FormatNumber(len(skipped)), idList, strings.Join(breakdown, "、")))
+	}
+	sb.WriteString("\n")
+}
+
 func (r *MarkdownReporter) writeErrorSuspiciousIPs(sb *strings.Builder, ips []analyzer.ErrorSuspiciousIP) {
 	sb.WriteString("### 疑わしいIP（エラー観点 / ブロック推奨）\n\n")
 	if len(ips) == 0 {
@@ -267,6 +618,327 @@ func (r *MarkdownReporter) writeBurstIPs(sb *strings.Builder, burstIPs []analyze
 	sb.WriteString("\n")
 }
 
+func (r *MarkdownReporter) writeWordPressAnalysis(sb *strings.Builder, wp analyzer.WordPressAnalysis) {
+	sb.WriteString("## WordPress 分析\n\n")
+	writeLoginAnalysis(sb, wp.Login)
+	writeDistributedLogin(sb, wp.Distributed)
+	writeXMLRPCAnalysis(sb, wp.XMLRPC)
+	writeUserEnumAnalysis(sb, wp.UserEnum)
+	writeComponentProbes(sb, wp.Probes)
+	writeVulnerableComponents(sb, wp.Probes)
+}
+
+func writeLoginAnalysis(sb *strings.Builder, login analyzer.LoginAnalysis) {
+	sb.WriteString("### wp-login.php ログイン試行\n\n")
+	if login.Attempts == 0 {
+		sb.WriteString("ログイン試行（wp-login.php への POST）はありませんでした。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("- **ログイン試行:** %s回（推定 %s IP）\n", utils.FormatNumber(login.Attempts), formatUniqueEstimate(login.IPs)))
+	sb.WriteString(fmt.Sprintf("- **成功（302 リダイレクト）:** %s回\n", utils.FormatNumber(login.Successes)))
+	sb.WriteString(fmt.Sprintf("- **失敗（200 フォーム再表示）:** %s回\n", utils.FormatNumber(login.Failures)))
+	sb.WriteString(fmt.Sprintf("- **拒否（401/403/429/503 等）:** %s回\n\n", utils.FormatNumber(login.Blocked)))
+
+	sb.WriteString(fmt.Sprintf("#### ブルートフォース（失敗 %d回以上）\n\n", login.BruteForceThreshold))
+	if len(login.BruteForceIPs) == 0 {
+		sb.WriteString("ブルートフォースと判定されたIPはありませんでした。\n\n")
+	} else {
+		sb.WriteString("失敗の後に成功しているIPはパスワードが破られた可能性があります。\n\n")
+		writeLoginIPs(sb, login.BruteForceIPs)
+	}
+	if login.Dropped > 0 {
+		sb.WriteString(fmt.Sprintf("> **注:** IP別の集計が上限（heavy_hitters.capacity）に達したため、試行の少ない %s IP を一覧の対象から外しています（合計の回数には含まれます）。\n\n", utils.FormatNumber(login.Dropped)))
+	}
+
+	sb.WriteString("#### 試行数の多いIP\n\n")
+	writeLoginIPs(sb, login.TopIPs)
+}
+
+func writeLoginIPs(sb *strings.Builder, ips []analyzer.LoginIP) {
+	sb.WriteString("| # | IP | 試行 | 失敗 | 成功 | 拒否 | 初回 (JST) | 最終 (JST) |\n")
+	sb.WriteString("|---|----|-----:|-----:|-----:|-----:|-----------|-----------|\n")
+	for i, ip := range ips {
+		successes := utils.FormatNumber(ip.Successes)
+		if ip.Successes > 0 && ip.Failures > 0 {
+			successes = "⚠️ " + successes
+		}
+		sb.WriteString(fmt.Sprintf("| %d | %s | %s | %s | %s | %s | %s | %s |\n", i+1, ip.IP,
+			utils.FormatNumber(ip.Attempts), utils.FormatNumber(ip.Failures), successes,
+			utils.FormatNumber(ip.Blocked), formatSeen(ip.FirstSeen), formatSeen(ip.LastSeen)))
+	}
+	sb.WriteString("\n")
+}
+
+// rateLimitTargets describes where each class's limit_req belongs.
+var rateLimitTargets = map[analyzer.RequestClass]string{
+	analyzer.ClassLogin:   "wp-login.php・xmlrpc.php・/wp-json/jwt-auth/・my-account の location",
+	analyzer.ClassAdmin:   "/wp-admin/ の location",
+	analyzer.ClassREST:    "/wp-json/ の location",
+	analyzer.ClassDynamic: "PHP（location ~ \\.php$ と location /）",
+	analyzer.ClassFeed:    "フィードの location",
+	analyzer.ClassSitemap: "サイトマップの location",
+}
+
+func (r *MarkdownReporter) writeRateLimits(sb *strings.Builder, rl analyzer.RateLimitAnalysis) {
+	sb.WriteString("## 🚦 レート制限の推奨値（Nginx limit_req）\n\n")
+	if len(rl.Classes) == 0 {
+		sb.WriteString("対象となるリクエストはありませんでした。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("IP ごとのリクエストを Nginx の `limit_req`（`nodelay`）で再生し、正規クライアントの %.0f%% を制限せずに悪用トラフィックを最も多く制限する rate / burst を分類ごとに求めています。", rl.Percentile))
+	sb.WriteString("悪用クライアントは検出ルール（クローラー以外）に一致したIP、ブルートフォースIP、分散型ブルートフォースの参加IPです。")
+	sb.WriteString(fmt.Sprintf("ログに現れたクライアントだけに合わせ込まないよう、候補は rate が正規 p%.0f の1分あたりピークの %d 倍以上（分類ごとの下限あり）、burst が1秒あたりピークの %d 倍以上（5 以上）のものに限っています。", rl.Percentile, rl.Headroom, rl.Headroom))
+	sb.WriteString(fmt.Sprintf("悪用リクエストの %.0f%% 以上を制限できない分類は、少数ずつ送る多数のIPによる悪用で IP 単位の制限が効かないため推奨していません。ログの時刻は秒単位のため、同じ秒のリクエストは同時とみなしています（実際より厳しめの評価）。静的アセットは対象外です。\n\n", rl.MinShare))
+	sb.WriteString(fmt.Sprintf("| 分類 | リクエスト | IP数 | 悪用（リクエスト / IP） | 正規 p%.0f ピーク（回/分 / 回/秒） | 候補の下限 | 推奨 | 悪用の制限率 | 制限（リクエスト / IP） | うち悪用 | うち正規 |\n", rl.Percentile))
+	sb.WriteString("|------|---------:|-----:|--------------------:|-------------------------------:|----------|------|----------:|-------------------:|--------:|--------:|\n")
+	var recommended []analyzer.RateLimitRecommendation
+	truncated, dropped := 0, 0
+	for _, c := range rl.Classes {
+		truncated += c.Truncated
+		dropped += c.Dropped
+		setting, share, limited, abusive, legit := rateLimitSkipLabels[c.Skipped], "-", "-", "-", "-"
+		if c.Skipped == analyzer.RateLimitIneffective {
+			share = fmt.Sprintf("最大 %.1f%%", c.AbusiveShare)
+		}
+		if c.RatePerMinute > 0 {
+			recommended = append(recommended, c)
+			setting = fmt.Sprintf("`rate=%s burst=%d`", formatNginxRate(c.RatePerMinute), c.Burst)
+			share = fmt.Sprintf("%.1f%%", c.AbusiveShare)
+			limited = utils.FormatNumber(c.LimitedRequests) + " / " + utils.FormatNumber(c.LimitedClients)
+			abusive = utils.FormatNumber(c.LimitedAbusiveRequests) + " / " + utils.FormatNumber(c.LimitedAbusiveClients)
+			legit = utils.FormatNumber(c.LimitedLegitRequests) + " / " + utils.FormatNumber(c.LimitedLegitClients)
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s / %s | %s / %s | `rate=%s burst=%d` | %s | %s | %s | %s | %s |\n", classLabels[c.Class],
+			utils.FormatNumber(c.Requests), utils.FormatNumber(c.Clients),
+			utils.FormatNumber(c.AbusiveRequests), utils.FormatNumber(c.AbusiveClients),
+			utils.FormatNumber(c.PeakLegit), utils.FormatNumber(c.PeakLegitSecond),
+			formatNginxRate(c.MinRate), c.MinBurst, setting, share, limited, abusive, legit))
+	}
+	sb.WriteString("\n")
+	if truncated > 0 {
+		sb.WriteString(fmt.Sprintf("> **注:** %s クライアントはリクエストのあった秒数が上限を超えたため、先頭の期間のみ評価しています。\n\n", utils.FormatNumber(truncated)))
+	}
+	if dropped > 0 {
+		sb.WriteString(fmt.Sprintf("> **注:** 分類ごとのクライアント数が上限（heavy_hitters.capacity）に達したため、リクエストの少ない %s クライアントを評価から外しています。正規クライアントのピークは多めに、候補の下限は緩めになります。\n\n", utils.FormatNumber(dropped)))
+	}
+	if len(recommended) == 0 {
+		sb.WriteString("推奨できるレート制限はありませんでした。\n\n")
+		return
+	}
+
+	listed := false
+	for _, c := range recommended {
+		if len(c.LimitedLegitIPs) > 0 {
+			sb.WriteString(fmt.Sprintf("- **%s で制限される正規クライアント:** %s（クローラー等は許可リストへの追加を検討）\n", classLabels[c.Class], formatIPCounts(c.LimitedLegitIPs)))
+			listed = true
+		}
+	}
+	if listed {
+		sb.WriteString("\n")
+	}
+	sb.WriteString("設定例:\n\n")
+	sb.WriteString("```nginx\n")
+	sb.WriteString("# http ブロック\n")
+	for _, c := range recommended {
+		sb.WriteString(fmt.Sprintf("limit_req_zone $binary_remote_addr zone=%s:10m rate=%s;\n", c.Class, formatNginxRate(c.RatePerMinute)))
+	}
+	sb.WriteString("\n")
+	for _, c := range recommended {
+		sb.WriteString(fmt.Sprintf("# %s\n", rateLimitTargets[c.Class]))
+		sb.WriteString(fmt.Sprintf("limit_req zone=%s burst=%d nodelay;\n", c.Class, c.Burst))
+	}
+	sb.WriteString("limit_req_status 429;\n")
+	sb.WriteString("```\n\n")
+}
+
+var rateLimitSkipLabels = map[string]string{
+	analyzer.RateLimitNoAbuse:     "不要（悪用なし）",
+	analyzer.RateLimitNoCandidate: "なし（正規クライアントを巻き込む）",
+	analyzer.RateLimitIneffective: "なし（IP 単位では効果が小さい）",
+}
+
+// formatNginxRate writes a per-minute rate the way limit_req_zone takes it.
+func formatNginxRate(perMinute int) string {
+	if perMinute%60 == 0 {
+		return fmt.Sprintf("%dr/s", perMinute/60)
+	}
+	return fmt.Sprintf("%dr/m", perMinute)
+}
+
+var loginEndpointLabels = map[string]string{
+	analyzer.LoginEndpointWPLogin:     "wp-login.php",
+	analyzer.LoginEndpointJWT:         "JWT 認証（/wp-json/jwt-auth/）",
+	analyzer.LoginEndpointWooCommerce: "WooCommerce（my-account）",
+}
+
+func writeDistributedLogin(sb *strings.Builder, d analyzer.DistributedLoginAnalysis) {
+	sb.WriteString("### 分散型ブルートフォース\n\n")
+	if d.WindowMinutes <= 0 {
+		sb.WriteString("分散型ブルートフォースの検出は無効です（`config.yaml` の `wordpress.distributed_window_minutes`）。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("wp-login.php・JWT 認証・WooCommerce（my-account）へのログイン POST を %d分ごとに集計し、"+
+		"試行 %d回以下でログインに失敗したIPが %d以上集まり失敗率が %.0f%% 以上のウィンドウを検出しています。連続するウィンドウは1件にまとめています。"+
+		"ボットネットは IP ごとの閾値を下回るよう試行を分散させるため、IP 単位のブルートフォース判定では検出されません。\n\n",
+		d.WindowMinutes, d.MaxAttemptsPerIP, d.MinIPs, d.MinFailureRate))
+	if len(d.Campaigns) == 0 {
+		sb.WriteString(fmt.Sprintf("ログイン試行 %s回のうち、分散型ブルートフォースは検出されませんでした。\n\n", utils.FormatNumber(d.Attempts)))
+		return
+	}
+	sb.WriteString("| # | エンドポイント | 開始 (JST) | 終了 (JST) | 試行 | 失敗 | 成功 | IP数 | 1IPあたり最大 |\n")
+	sb.WriteString("|---|--------------|-----------|-----------|-----:|-----:|-----:|-----:|------------:|\n")
+	for i, c := range d.Campaigns {
+		successes := utils.FormatNumber(c.Successes)
+		if c.Successes > 0 {
+			successes = "⚠️ " + successes
+		}
+		sb.WriteString(fmt.Sprintf("| %d | %s | %s | %s | %s | %s | %s | %s | %s |\n", i+1, loginEndpointLabels[c.Endpoint],
+			formatSeen(c.Start), formatSeen(c.End), utils.FormatNumber(c.Attempts), utils.FormatNumber(c.Failures),
+			successes, utils.FormatNumber(c.IPs), utils.FormatNumber(c.MaxPerIP)))
+	}
+	sb.WriteString("\n")
+
+	for i, c := range d.Campaigns {
+		sb.WriteString(fmt.Sprintf("#### #%d %s（%s〜%s）\n\n", i+1, loginEndpointLabels[c.Endpoint], formatSeen(c.Start), formatSeen(c.End)))
+		sb.WriteString(fmt.Sprintf("- **User-Agent:** %s\n", formatLoginClusters(c.UserAgents, func(key string) string { return "`" + tableCell(key) + "`" })))
+		sb.WriteString(fmt.Sprintf("- **サブネット:** %s\n", formatLoginClusters(c.Subnets, func(key string) string { return key })))
+		if len(c.SuccessIPs) > 0 {
+			sb.WriteString(fmt.Sprintf("- **⚠️ ログインに成功したIP:** %s（アカウントが侵害された可能性があります）\n", strings.Join(c.SuccessIPs, "、")))
+		}
+		ips := formatIPCounts(c.TopIPs)
+		if c.IPs > len(c.TopIPs) {
+			ips += fmt.Sprintf(" ほか %s IP", utils.FormatNumber(c.IPs-len(c.TopIPs)))
+		}
+		sb.WriteString(fmt.Sprintf("- **参加IP（%s）:** %s\n\n", utils.FormatNumber(c.IPs), ips))
+	}
+}
+
+func formatLoginClusters(clusters []analyzer.LoginCluster, label func(string) string) string {
+	parts := make([]string, len(clusters))
+	for i, c := range clusters {
+		parts[i] = fmt.Sprintf("%s（%s IP / %s回）", label(c.Key), utils.FormatNumber(c.IPs), utils.FormatNumber(c.Attempts))
+	}
+	return strings.Join(parts, "、")
+}
+
+func writeXMLRPCAnalysis(sb *strings.Builder, x analyzer.XMLRPCAnalysis) {
+	sb.WriteString("### XML-RPC (xmlrpc.php)\n\n")
+	if x.Requests == 0 {
+		sb.WriteString("xmlrpc.php へのリクエストはありませんでした。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("- **リクエスト数:** %s（POST: %s、拒否: %s）\n",
+		utils.FormatNumber(x.Requests), utils.FormatNumber(x.Posts), utils.FormatNumber(x.Blocked)))
+	sb.WriteString(fmt.Sprintf("- **ユニークIP（推定）:** %s\n", formatUniqueEstimate(x.UniqueIPs)))
+	if x.MulticallMinBytes > 0 {
+		sb.WriteString(fmt.Sprintf("- **system.multicall の可能性:** %s回（応答 %s バイト以上の POST）\n",
+			utils.FormatNumber(x.LargePosts), utils.FormatNumber(int(x.MulticallMinBytes))))
+	}
+	sb.WriteString("\n")
+	if x.MulticallMinBytes > 0 {
+		sb.WriteString("リクエストボディはアクセスログに残らないため、system.multicall（1リクエストで複数パスワードを試す手法）は応答サイズから推定しています。\n\n")
+	}
+	if len(x.TopIPs) > 0 {
+		sb.WriteString(fmt.Sprintf("- **POST の多いIP:** %s\n\n", formatIPCounts(x.TopIPs)))
+	}
+}
+
+func writeUserEnumAnalysis(sb *strings.Builder, u analyzer.UserEnumAnalysis) {
+	sb.WriteString("### ユーザー列挙\n\n")
+	if u.AuthorRequests == 0 && u.RESTRequests == 0 {
+		sb.WriteString("ユーザー列挙の試行はありませんでした。\n\n")
+		return
+	}
+	sb.WriteString("| 手法 | リクエスト | ユーザー名が判明 |\n")
+	sb.WriteString("|------|---------:|--------------:|\n")
+	sb.WriteString(fmt.Sprintf("| `?author=N`（%d種類、最大 ID %d） | %s | %s（3xx） |\n", u.AuthorIDs, u.MaxAuthorID,
+		utils.FormatNumber(u.AuthorRequests), utils.FormatNumber(u.AuthorRedirects)))
+	sb.WriteString(fmt.Sprintf("| REST API `/wp-json/wp/v2/users` | %s | %s（200） |\n",
+		utils.FormatNumber(u.RESTRequests), utils.FormatNumber(u.RESTExposed)))
+	sb.WriteString("\n")
+	if len(u.TopIPs) > 0 {
+		sb.WriteString(fmt.Sprintf("- **列挙を試みたIP:** %s\n\n", formatIPCounts(u.TopIPs)))
+	}
+}
+
+var componentTypeLabels = map[string]string{
+	analyzer.ComponentPlugin: "プラグイン",
+	analyzer.ComponentTheme:  "テーマ",
+}
+
+func writeComponentProbes(sb *strings.Builder, p analyzer.ComponentProbeAnalysis) {
+	sb.WriteString("### プラグイン・テーマの探索\n\n")
+	if p.Requests == 0 {
+		sb.WriteString("プラグイン・テーマの探索はありませんでした。\n\n")
+		return
+	}
+	sb.WriteString("`/wp-content/plugins/<slug>/` と `/wp-content/themes/<slug>/` への readme.txt 等の取得、ディレクトリ自体へのアクセス、404 を探索とみなしています。\n\n")
+	sb.WriteString(fmt.Sprintf("- **探索リクエスト:** %s（%s スラッグ、ユニークIP（推定）: %s）\n",
+		utils.FormatNumber(p.Requests), utils.FormatNumber(p.Slugs), formatUniqueEstimate(p.UniqueIPs)))
+	if len(p.TopIPs) > 0 {
+		sb.WriteString(fmt.Sprintf("- **主要IP:** %s\n", formatIPCounts(p.TopIPs)))
+	}
+	sb.WriteString("\n")
+	sb.WriteString("| # | 種別 | スラッグ | 探索数 | 2xx（存在） |\n")
+	sb.WriteString("|---|------|---------|------:|----------:|\n")
+	for i, c := range p.Components {
+		sb.WriteString(fmt.Sprintf("| %d | %s | `%s` | %s | %s |\n", i+1, componentTypeLabels[c.Type],
+			tableCell(c.Slug), utils.FormatNumber(c.Requests), utils.FormatNumber(c.Found)))
+	}
+	sb.WriteString("\n")
+}
+
+var versionStatusLabels = map[string]string{
+	analyzer.VersionAffected:    "⚠️ 影響あり",
+	analyzer.VersionNotAffected: "影響なし",
+	analyzer.VersionUnknown:     "不明",
+}
+
+func writeVulnerableComponents(sb *strings.Builder, p analyzer.ComponentProbeAnalysis) {
+	sb.WriteString("### 既知の脆弱性を持つプラグイン・テーマへの探索\n\n")
+	if p.VulnDB == 0 {
+		sb.WriteString("脆弱性リストが設定されていません（`config.yaml` の `wordpress.vulnerability_db`）。\n\n")
+		return
+	}
+	if len(p.Vulnerable) == 0 {
+		sb.WriteString(fmt.Sprintf("脆弱性リスト（%s件）に載っているプラグイン・テーマへの探索はありませんでした。\n\n", utils.FormatNumber(p.VulnDB)))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("脆弱性リスト（%s件）に載っているプラグイン・テーマへの探索です。", utils.FormatNumber(p.VulnDB)))
+	sb.WriteString("2xx を返したパスがあるものはサイト上に存在する可能性が高く、優先して確認してください。")
+	sb.WriteString("サイト上のバージョンはページが読み込む CSS/JS の `?ver=` から推定しています。\n\n")
+	sb.WriteString("| 種別 | スラッグ | 探索数 | 2xx を返したパス | サイト上のバージョン | CVE | 影響バージョン | 判定 |\n")
+	sb.WriteString("|------|---------|------:|----------------|------------------|-----|--------------|------|\n")
+	for _, c := range p.Vulnerable {
+		paths := "-"
+		if len(c.FoundPaths) > 0 {
+			quoted := make([]string, len(c.FoundPaths))
+			for i, path := range c.FoundPaths {
+				quoted[i] = "`" + tableCell(path) + "`"
+			}
+			paths = strings.Join(quoted, "<br>")
+		}
+		versions := "-"
+		if len(c.Versions) > 0 {
+			versions = strings.Join(c.Versions, ", ")
+		}
+		for _, v := range c.Vulnerabilities {
+			cve := v.CVE
+			if v.Title != "" {
+				cve += " " + v.Title
+			}
+			affected := v.Versions
+			if affected == "" {
+				affected = "*"
+			}
+			sb.WriteString(fmt.Sprintf("| %s | `%s` | %s | %s | %s | %s | %s | %s |\n", componentTypeLabels[c.Type],
+				tableCell(c.Slug), utils.FormatNumber(c.Requests), paths, tableCell(versions),
+				tableCell(cve), tableCell(affected), versionStatusLabels[v.Status]))
+		}
+	}
+	sb.WriteString("\n")
+}
+
 func (r *MarkdownReporter) writeStatistics(sb *strings.Builder, stats analyzer.Statistics) {
 	sb.WriteString("## 統計情報\n\n")
 
@@ -286,18 +958,21 @@ func (r *MarkdownReporter) writeStatistics(sb *strings.Builder, stats analyzer.S
 	sb.WriteString("### 頻出IPアドレス（上位）\n\n")
 	if len(stats.TopIPs) > 0 {
 		for i, ip := range stats.TopIPs {
-			sb.WriteString(fmt.Sprintf("%d. **%s:** %sリクエスト\n", i+1, ip.IP, utils.FormatNumber(ip.Count)))
+			sb.WriteString(fmt.Sprintf("%d. **%s:** %sリクエスト\n", i+1, ip.IP, formatCount(ip.Count, ip.ErrorBound)))
 		}
 	} else {
 		sb.WriteString("IPデータがありません。\n")
 	}
 	sb.WriteString("\n")
+	writeTopNAccuracy(sb, stats.TopNAccuracy)
 
 	// Response Time Analysis
 	sb.WriteString("### レスポンスタイム分析\n\n")
 	sb.WriteString(fmt.Sprintf("- **平均:** %.3f秒\n", stats.ResponseTimeStats.Average))
 	sb.WriteString(fmt.Sprintf("- **最大:** %.3f秒\n", stats.ResponseTimeStats.Maximum))
+	sb.WriteString(fmt.Sprintf("- **50パーセンタイル（中央値）:** %.3f秒\n", stats.ResponseTimeStats.Percentile50))
 	sb.WriteString(fmt.Sprintf("- **95パーセンタイル:** %.3f秒\n", stats.ResponseTimeStats.Percentile95))
+	sb.WriteString(fmt.Sprintf("- **99パーセンタイル:** %.3f秒\n", stats.ResponseTimeStats.Percentile99))
 	sb.WriteString(fmt.Sprintf("- **遅いリクエスト（3秒超）:** %s\n", utils.FormatNumber(stats.ResponseTimeStats.SlowRequests)))
 	sb.WriteString("\n")
 
@@ -307,7 +982,8 @@ func (r *MarkdownReporter) writeStatistics(sb *strings.Builder, stats analyzer.S
 	sb.WriteString("### ステータスコード別集計\n\n")
 	sb.WriteString("| ステータスコード | 件数 |\n")
 	sb.WriteString("|-------------|-------|\n")
-	for status, count := range stats.StatusCodes {
+	for _, status := range sortedStatusCodes(stats.StatusCodes) {
+		count := stats.StatusCodes[status]
 		statusText := getStatusText(status)
 		sb.WriteString(fmt.Sprintf("| %d %s | %s |\n", status, statusText, utils.FormatNumber(count)))
 	}
@@ -340,7 +1016,8 @@ func (r *MarkdownReporter) writeUserAgentAnalysis(sb *strings.Builder, ua analyz
 	// Crawlers
 	sb.WriteString("### 検出されたクローラー\n\n")
 	if len(ua.Crawlers) > 0 {
-		for agent, count := range ua.Crawlers {
+		for _, agent := range sortedKeysByCount(ua.Crawlers) {
+			count := ua.Crawlers[agent]
 			sb.WriteString(fmt.Sprintf("- **%s:** %sリクエスト\n", agent, utils.FormatNumber(count)))
 		}
 	} else {
@@ -351,7 +1028,8 @@ func (r *MarkdownReporter) writeUserAgentAnalysis(sb *strings.Builder, ua analyz
 	// Attack Tools
 	sb.WriteString("### 検出された攻撃ツール\n\n")
 	if len(ua.AttackTools) > 0 {
-		for tool, count := range ua.AttackTools {
+		for _, tool := range sortedKeysByCount(ua.AttackTools) {
+			count := ua.AttackTools[tool]
 			sb.WriteString(fmt.Sprintf("- **%s:** %sリクエスト\n", tool, utils.FormatNumber(count)))
 		}
 	} else {
@@ -371,7 +1049,7 @@ func (r *MarkdownReporter) writeUserAgentAnalysis(sb *strings.Builder, ua analyz
 			if len(userAgent) > 80 {
 				userAgent = userAgent[:77] + "..."
 			}
-			sb.WriteString(fmt.Sprintf("%d. `%s` - %sリクエスト\n", i+1, userAgent, utils.FormatNumber(suspicious.Count)))
+			sb.WriteString(fmt.Sprintf("%d. `%s` - %sリクエスト\n", i+1, userAgent, formatCount(suspicious.Count, suspicious.ErrorBound)))
 		}
 	} else {
 		sb.WriteString("不審なユーザーエージェントは検出されませんでした。\n")
@@ -407,31 +1085,435 @@ func (r *MarkdownReporter) writeSlowURLs(sb *strings.Builder, urls []analyzer.UR
 		return
 	}
 	for i, u := range urls {
-		sb.WriteString(fmt.Sprintf("%d. `%s`: %s件\n", i+1, u.URL, utils.FormatNumber(u.Count)))
+		sb.WriteString(fmt.Sprintf("%d. `%s`: %s件\n", i+1, u.URL, formatCount(u.Count, u.ErrorBound)))
 	}
 	sb.WriteString("\n")
 }
 
+// formatCount formats a Top-N count, noting the Space-Saving overcount bound
+// when the count is not exact.
+func formatCount(count, errorBound int) string {
+	if errorBound == 0 {
+		return utils.FormatNumber(count)
+	}
+	return fmt.Sprintf("%s（誤差 ≤%s）", utils.FormatNumber(count), utils.FormatNumber(errorBound))
+}
+
+func writeTopNAccuracy(sb *strings.Builder, acc analyzer.TopNAccuracy) {
+	if acc.MaxError == 0 {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("> **注:** Top-N 集計は監視キー数 %s の Space-Saving による近似です。各件数は最大 %s 件過大な可能性があり、これ以下の件数のキーは表に現れないことがあります。\n\n",
+		utils.FormatNumber(acc.Capacity), utils.FormatNumber(acc.MaxError)))
+}
+
+func (r *MarkdownReporter) writeMethodAnalysis(sb *strings.Builder, m analyzer.MethodAnalysis) {
+	sb.WriteString("## HTTPメソッド・プロトコル分析\n\n")
+	if len(m.Methods) == 0 {
+		sb.WriteString("データがありません。\n\n")
+		return
+	}
+
+	sb.WriteString("### メソッド × ステータスクラス\n\n")
+	sb.WriteString("| メソッド | 合計 | 2xx | 3xx | 4xx | 5xx | その他 |\n")
+	sb.WriteString("|---------|-----:|----:|----:|----:|----:|------:|\n")
+	for _, method := range sortedKeysByCount(m.Methods) {
+		var classes [6]int // index 2..5 = 2xx..5xx, 0 = その他
+		for status, c := range m.MethodStatus[method] {
+			if cls := status / 100; cls >= 2 && cls <= 5 {
+				classes[cls] += c
+			} else {
+				classes[0] += c
+			}
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s |\n", method,
+			utils.FormatNumber(m.Methods[method]),
+			utils.FormatNumber(classes[2]), utils.FormatNumber(classes[3]),
+			utils.FormatNumber(classes[4]), utils.FormatNumber(classes[5]), utils.FormatNumber(classes[0])))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### プロトコル\n\n")
+	total := 0
+	for _, c := range m.Protocols {
+		total += c
+	}
+	for _, protocol := range sortedKeysByCount(m.Protocols) {
+		c := m.Protocols[protocol]
+		sb.WriteString(fmt.Sprintf("- **%s:** %sリクエスト (%.1f%%)\n", protocol, utils.FormatNumber(c), float64(c)/float64(total)*100))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### 通常使われないメソッド（TRACE / CONNECT / PROPFIND 等）\n\n")
+	if len(m.UnusualMethods) > 0 {
+		for i, u := range m.UnusualMethods {
+			sb.WriteString(fmt.Sprintf("%d. **%s** %s: %sリクエスト\n", i+1, u.IP, u.Method, utils.FormatNumber(u.Count)))
+		}
+	} else {
+		sb.WriteString("通常使われないメソッドは検出されませんでした。\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### HTTP/1.0 クライアント（上位）\n\n")
+	if len(m.HTTP10Clients) > 0 {
+		sb.WriteString("HTTP/1.0 は現在のブラウザでは使われないため、スクリプトやボットの兆候です。\n\n")
+		for i, ip := range m.HTTP10Clients {
+			sb.WriteString(fmt.Sprintf("%d. **%s:** %sリクエスト\n", i+1, ip.IP, formatCount(ip.Count, ip.ErrorBound)))
+		}
+	} else {
+		sb.WriteString("HTTP/1.0 のリクエストは検出されませんでした。\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### POST 先URL（上位）\n\n")
+	if len(m.TopPostURLs) > 0 {
+		for i, u := range m.TopPostURLs {
+			sb.WriteString(fmt.Sprintf("%d. `%s`: %sリクエスト\n", i+1, u.URL, formatCount(u.Count, u.ErrorBound)))
+		}
+	} else {
+		sb.WriteString("POST リクエストは検出されませんでした。\n")
+	}
+	sb.WriteString("\n")
+}
+
+func (r *MarkdownReporter) writeRedirectAnalysis(sb *strings.Builder, rd analyzer.RedirectAnalysis) {
+	sb.WriteString("## リダイレクト分析\n\n")
+	if rd.Total == 0 {
+		sb.WriteString("リダイレクトは検出されませんでした。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("- **リダイレクト総数:** %s\n", utils.FormatNumber(rd.Total)))
+	codes := make([]int, 0, len(rd.ByCode))
+	for code := range rd.ByCode {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		c := rd.ByCode[code]
+		sb.WriteString(fmt.Sprintf("- **%d %s:** %s (%.1f%%)\n", code, getStatusText(code), utils.FormatNumber(c), float64(c)/float64(rd.Total)*100))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### リダイレクト元URL（上位）\n\n")
+	for i, u := range rd.TopRedirectURLs {
+		sb.WriteString(fmt.Sprintf("%d. `%s`: %s件\n", i+1, u.URL, formatCount(u.Count, u.ErrorBound)))
+	}
+	sb.WriteString("\n")
+
+	if len(rd.TopMappings) > 0 {
+		sb.WriteString("### リダイレクト元 → upstream\n\n")
+		sb.WriteString("| # | URL | upstream URI | 件数 |\n")
+		sb.WriteString("|---|-----|-------------|-----:|\n")
+		for i, m := range rd.TopMappings {
+			sb.WriteString(fmt.Sprintf("| %d | `%s` | `%s` | %s |\n", i+1, m.URL, m.UpstreamURI, formatCount(m.Count, m.ErrorBound)))
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("### リダイレクトチェーン（2回以上の連続リダイレクト）\n\n")
+	writeRedirectChains(sb, rd.Chains, "リダイレクトチェーンは検出されませんでした。")
+
+	sb.WriteString("### リダイレクトループ\n\n")
+	writeRedirectChains(sb, rd.Loops, "リダイレクトループは検出されませんでした。")
+}
+
+func (r *MarkdownReporter) writeUpstreamAnalysis(sb *strings.Builder, up analyzer.UpstreamAnalysis) {
+	sb.WriteString("## Upstream（Nginx ルーティング）分析\n\n")
+	total := up.PHP.Requests + up.Static.Requests + up.Unknown
+	if total == 0 {
+		sb.WriteString("データがありません。\n\n")
+		return
+	}
+	sb.WriteString("### PHP 到達 vs 静的配信\n\n")
+	sb.WriteString("| 区分 | リクエスト | 割合 | エラー数 | 平均レスポンス |\n")
+	sb.WriteString("|------|---------:|-----:|-------:|-------------:|\n")
+	for _, row := range []struct {
+		label string
+		stats analyzer.UpstreamStats
+	}{
+		{"PHP 到達", up.PHP},
+		{"静的配信", up.Static},
+	} {
+		sb.WriteString(fmt.Sprintf("| %s | %s | %.1f%% | %s | %.3f秒 |\n", row.label,
+			utils.FormatNumber(row.stats.Requests), float64(row.stats.Requests)/float64(total)*100,
+			utils.FormatNumber(row.stats.Errors), row.stats.AvgResponseTime))
+	}
+	if up.Unknown > 0 {
+		sb.WriteString(fmt.Sprintf("| upstream 不明 | %s | %.1f%% | - | - |\n", utils.FormatNumber(up.Unknown), float64(up.Unknown)/float64(total)*100))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### upstream スクリプト別\n\n")
+	if len(up.Scripts) > 0 {
+		sb.WriteString("| # | スクリプト | リクエスト | PHP内割合 | エラー率 | 平均レスポンス | 最大レスポンス |\n")
+		sb.WriteString("|---|----------|---------:|--------:|-------:|-------------:|-------------:|\n")
+		for i, sc := range up.Scripts {
+			sb.WriteString(fmt.Sprintf("| %d | `%s` | %s | %.1f%% | %.1f%% | %.3f秒 | %.3f秒 |\n", i+1, sc.Script,
+				utils.FormatNumber(sc.Requests), sc.Share, sc.ErrorRate, sc.AvgResponseTime, sc.MaxResponseTime))
+		}
+	} else {
+		sb.WriteString("PHP に到達したリクエストはありませんでした。\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### リクエストURL → upstream（書き換え上位）\n\n")
+	if len(up.TopRewrites) == 0 {
+		sb.WriteString("書き換えられたリクエストはありませんでした。\n\n")
+		return
+	}
+	sb.WriteString("| # | リクエストURL | upstream URI | 件数 |\n")
+	sb.WriteString("|---|-------------|-------------|-----:|\n")
+	for i, m := range up.TopRewrites {
+		sb.WriteString(fmt.Sprintf("| %d | `%s` | `%s` | %s |\n", i+1, m.URL, m.UpstreamURI, formatCount(m.Count, m.ErrorBound)))
+	}
+	sb.WriteString("\n")
+}
+
+func writeRedirectChains(sb *strings.Builder, chains []analyzer.RedirectChain, empty string) {
+	if len(chains) == 0 {
+		sb.WriteString(empty + "\n\n")
+		return
+	}
+	sb.WriteString("同一クライアント（IP+UA）の連続リクエストから推定しています（→ はリダイレクト、⇒ は最終的に到達したURL）。\n\n")
+	for i, c := range chains {
+		sb.WriteString(fmt.Sprintf("%d. `%s`（%d回）: %s件\n", i+1, c.Path, c.Redirects, formatCount(c.Count, c.ErrorBound)))
+	}
+	sb.WriteString("\n")
+}
+
+// sortedKeysByCount returns the keys of counts ordered by count desc, then key.
+func sortedKeysByCount(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+func (r *MarkdownReporter) writeRefererAnalysis(sb *strings.Builder, ref analyzer.RefererAnalysis) {
+	sb.WriteString("## リファラー分析\n\n")
+	total := ref.NoReferer + ref.Internal + ref.External
+	if total == 0 {
+		sb.WriteString("データがありません。\n\n")
+		return
+	}
+	share := func(n int) float64 { return float64(n) / float64(total) * 100 }
+	sb.WriteString(fmt.Sprintf("- **リファラーなし:** %s (%.1f%%)\n", utils.FormatNumber(ref.NoReferer), share(ref.NoReferer)))
+	sb.WriteString(fmt.Sprintf("- **内部リファラー:** %s (%.1f%%)\n", utils.FormatNumber(ref.Internal), share(ref.Internal)))
+	sb.WriteString(fmt.Sprintf("- **外部リファラー:** %s (%.1f%%)\n\n", utils.FormatNumber(ref.External), share(ref.External)))
+
+	sb.WriteString("### 検索エンジン\n\n")
+	if len(ref.SearchEngines) > 0 {
+		for _, e := range sortedKeysByCount(ref.SearchEngines) {
+			sb.WriteString(fmt.Sprintf("- **%s:** %sリクエスト\n", e, utils.FormatNumber(ref.SearchEngines[e])))
+		}
+	} else {
+		sb.WriteString("検索エンジンからの流入は検出されませんでした。\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### 外部リファラー（上位ホスト）\n\n")
+	if len(ref.TopReferringHosts) > 0 {
+		for i, h := range ref.TopReferringHosts {
+			sb.WriteString(fmt.Sprintf("%d. `%s`: %sリクエスト\n", i+1, h.Host, formatCount(h.Count, h.ErrorBound)))
+		}
+	} else {
+		sb.WriteString("外部リファラーは検出されませんでした。\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### リファラースパム\n\n")
+	if len(ref.SpamReferers) > 0 {
+		for i, h := range ref.SpamReferers {
+			sb.WriteString(fmt.Sprintf("%d. `%s`: %sリクエスト\n", i+1, h.Host, formatCount(h.Count, h.ErrorBound)))
+		}
+	} else {
+		sb.WriteString("リファラースパムは検出されませんでした。\n")
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### ホットリンク（外部サイトからの画像・メディア直接参照）\n\n")
+	if len(ref.HotlinkHosts) == 0 {
+		sb.WriteString("ホットリンクは検出されませんでした。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("- **合計:** %sリクエスト / %s\n\n", utils.FormatNumber(ref.HotlinkRequests), utils.FormatBytes(ref.HotlinkBytes)))
+	sb.WriteString("| # | 参照元ホスト | リクエスト | 転送量 |\n")
+	sb.WriteString("|---|------------|---------:|------:|\n")
+	for i, h := range ref.HotlinkHosts {
+		sb.WriteString(fmt.Sprintf("| %d | %s | %s | %s |\n", i+1, h.Host, utils.FormatNumber(h.Requests), utils.FormatBytes(h.Bytes)))
+	}
+	sb.WriteString("\n")
+}
+
+func (r *MarkdownReporter) writeUniques(sb *strings.Builder, uniques analyzer.UniqueAnalysis) {
+	sb.WriteString("## ユニーク数（推定）\n\n")
+	if len(uniques.Daily) == 0 {
+		sb.WriteString("データがありません。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("HyperLogLog による推定値です（相対標準誤差 約%.1f%%、±は95%%信頼区間の目安）。\n\n", uniques.RelativeError*100))
+
+	sb.WriteString("### 日別 (JST)\n\n")
+	writeUniquePeriodTable(sb, "日付", "2006-01-02", uniques.Daily)
+
+	sb.WriteString("### 時間別 (JST)\n\n")
+	writeUniquePeriodTable(sb, "時間", "2006-01-02 15:00", uniques.Hourly)
+}
+
+func writeUniquePeriodTable(sb *strings.Builder, label, layout string, periods []analyzer.UniquePeriod) {
+	sb.WriteString(fmt.Sprintf("| %s | ユニークIP | ユニーク訪問者 (IP+UA) | ユニークURL |\n", label))
+	sb.WriteString("|------|----------:|--------------------:|-----------:|\n")
+	for _, p := range periods {
+		sb.WriteString(fmt.Sprintf("| %s | %s | %s | %s |\n",
+			p.Start.In(utils.JST).Format(layout),
+			formatUniqueEstimate(p.Counts.IPs), formatUniqueEstimate(p.Counts.Visitors), formatUniqueEstimate(p.Counts.URLs)))
+	}
+	sb.WriteString("\n")
+}
+
+func (r *MarkdownReporter) writeSessions(sb *strings.Builder, sessions analyzer.SessionAnalysis) {
+	sb.WriteString("## セッション分析\n\n")
+	if sessions.TotalSessions == 0 {
+		sb.WriteString("セッションは検出されませんでした。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("- **セッション数:** %s\n", utils.FormatNumber(sessions.TotalSessions)))
+	sb.WriteString(fmt.Sprintf("- **平均ページ数/セッション:** %.2f\n", sessions.AvgPagesPerSession))
+	sb.WriteString(fmt.Sprintf("- **平均セッション時間:** %.1f秒\n", sessions.AvgDuration))
+	sb.WriteString(fmt.Sprintf("- **直帰率:** %.1f%%\n\n", sessions.BounceRate))
+
+	sb.WriteString("### ランディングページ（上位）\n\n")
+	for i, u := range sessions.TopEntryPages {
+		sb.WriteString(fmt.Sprintf("%d. `%s`: %sセッション\n", i+1, u.URL, utils.FormatNumber(u.Count)))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### 離脱ページ（上位）\n\n")
+	for i, u := range sessions.TopExitPages {
+		sb.WriteString(fmt.Sprintf("%d. `%s`: %sセッション\n", i+1, u.URL, utils.FormatNumber(u.Count)))
+	}
+	sb.WriteString("\n")
+
+	sb.WriteString("### 主な遷移パス\n\n")
+	if len(sessions.TopPaths) == 0 {
+		sb.WriteString("複数ページを閲覧したセッションはありませんでした。\n\n")
+		return
+	}
+	for i, p := range sessions.TopPaths {
+		sb.WriteString(fmt.Sprintf("%d. `%s`: %sセッション\n", i+1, p.Path, utils.FormatNumber(p.Count)))
+	}
+	sb.WriteString("\n")
+}
+
+func (r *MarkdownReporter) writeAnomalies(sb *strings.Builder, anomalies analyzer.AnomalyAnalysis) {
+	sb.WriteString("## 異常検知（分単位の時系列）\n\n")
+	if !anomalies.Enabled || anomalies.WindowMinutes <= 0 {
+		sb.WriteString("異常検知は無効です（設定の anomaly.enabled）。\n\n")
+		return
+	}
+	sb.WriteString(fmt.Sprintf("直前%d分のローリング median/MAD をベースラインとし、ロバストZスコアが %.1f 以上の分を異常と判定しています。\n\n",
+		anomalies.WindowMinutes, anomalies.ScoreThreshold))
+	if len(anomalies.Intervals) == 0 {
+		sb.WriteString("異常な区間は検出されませんでした。\n\n")
+		return
+	}
+	sb.WriteString("| # | 期間 (JST) | 系列 | スコア | リクエスト | エラー | 平均レスポンス |\n")
+	sb.WriteString("|---|-----------|------|------:|---------:|------:|------------:|\n")
+	for i, iv := range anomalies.Intervals {
+		sb.WriteString(fmt.Sprintf("| %d | %s - %s | %s | %.1f | %s | %s | %.3f秒 |\n",
+			i+1,
+			iv.Start.In(utils.JST).Format("2006-01-02 15:04"),
+			iv.End.Add(time.Minute).In(utils.JST).Format("15:04"),
+			strings.Join(iv.Series, "、"), iv.Score,
+			utils.FormatNumber(iv.Requests), utils.FormatNumber(iv.Errors), iv.AvgResponseTime))
+	}
+	sb.WriteString("\n")
+
+	for i, iv := range anomalies.Intervals {
+		sb.WriteString(fmt.Sprintf("#### #%d %s の主な要因\n\n", i+1, iv.Start.In(utils.JST).Format("2006-01-02 15:04")))
+		if len(iv.TopURLs) > 0 {
+			sb.WriteString("- **URL:**\n")
+			for _, u := range iv.TopURLs {
+				sb.WriteString(fmt.Sprintf("  - `%s`: %s件\n", u.URL, utils.FormatNumber(u.Count)))
+			}
+		}
+		if len(iv.TopIPs) > 0 {
+			sb.WriteString("- **IP:**\n")
+			for _, ip := range iv.TopIPs {
+				sb.WriteString(fmt.Sprintf("  - %s: %s件\n", ip.IP, utils.FormatNumber(ip.Count)))
+			}
+		}
+		sb.WriteString("\n")
+	}
+}
+
+func (r *MarkdownReporter) writeBaseline(sb *strings.Builder, b analyzer.BaselineAnalysis) {
+	if !b.Enabled {
+		return
+	}
+	sb.WriteString("## ベースライン比較（過去の実行）\n\n")
+	if b.Runs < b.MinRuns || len(b.Metrics) == 0 {
+		sb.WriteString(fmt.Sprintf("過去の実行要約が %d件しかないため比較できません（%d件以上必要）。今回の要約は保存されました。\n\n", b.Runs, b.MinRuns))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("直近%d回の実行の中央値をベースラインとし、ロバストZスコアが %.1f 以上の劣化を ⚠️ で示しています。劣化: **%d件**\n\n",
+		b.Runs, b.Threshold, b.Regressions))
+	sb.WriteString("| 対象 | 指標 | 今回 | ベースライン | 変化率 | スコア | 判定 |\n")
+	sb.WriteString("|------|------|-----:|-----------:|------:|------:|------|\n")
+	for _, m := range b.Metrics {
+		route := m.Route
+		if route == "" {
+			route = "全体"
+		}
+		change := "-"
+		if m.Median != 0 {
+			change = fmt.Sprintf("%+.1f%%", m.ChangePercent)
+		}
+		verdict := ""
+		if m.Regression {
+			verdict = "⚠️ 劣化"
+		}
+		sb.WriteString(fmt.Sprintf("| %s | %s | %.3f | %.3f | %s | %.1f | %s |\n",
+			route, m.Name, m.Current, m.Median, change, m.Score, verdict))
+	}
+	sb.WriteString("\n")
+}
+
+// sortedStatusCodes returns the keys of a status -> count map in ascending order.
+func sortedStatusCodes(counts map[int]int) []int {
+	codes := make([]int, 0, len(counts))
+	for code := range counts {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// nginxStatusTexts covers the non-standard codes Nginx writes to access logs.
+var nginxStatusTexts = map[int]string{
+	444: "No Response (Nginx)",
+	494: "Request Header Too Large (Nginx)",
+	495: "SSL Certificate Error (Nginx)",
+	496: "SSL Certificate Required (Nginx)",
+	497: "HTTP Request Sent to HTTPS Port (Nginx)",
+	499: "Client Closed Request (Nginx)",
+}
+
+// getStatusText returns the IANA reason phrase for code, falling back to
+// Nginx-specific codes.
 func getStatusText(code int) string {
-	statusTexts := map[int]string{
-		200: "OK",
-		301: "Moved Permanently",
-		302: "Found",
-		304: "Not Modified",
-		400: "Bad Request",
-		401: "Unauthorized",
-		403: "Forbidden",
-		404: "Not Found",
-		405: "Method Not Allowed",
-		429: "Too Many Requests",
-		500: "Internal Server Error",
-		502: "Bad Gateway",
-		503: "Service Unavailable",
-		504: "Gateway Timeout",
-	}
-	
-	if text, exists := statusTexts[code]; exists {
+	if text := http.StatusText(code); text != "" {
+		return text
+	}
+	if text, exists := nginxStatusTexts[code]; exists {
 		return text
 	}
 	return "Unknown"
-}
\ No newline at end of file
+}
@@ -0,0 +1,396 @@
+package rules
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// SkipReason says why a SecRule could not be imported.
+type SkipReason string
+
+const (
+	SkipOperator  SkipReason = "unsupported-operator"  // anything but @rx, @pm, @contains
+	SkipNegated   SkipReason = "negated-operator"      // !@rx ...
+	SkipVariables SkipReason = "unsupported-variables" // none of the variables map to a request field
+	SkipChain     SkipReason = "chained-rule"          // the match depends on a chained rule
+	SkipRegex     SkipReason = "incompatible-regex"    // PCRE-only syntax such as lookarounds
+	SkipDuplicate SkipReason = "duplicate-id"
+	SkipInvalid   SkipReason = "invalid" // missing id or malformed directive
+)
+
+// Skipped is a SecRule that was not imported.
+type Skipped struct {
+	ID     string // rule id, "" if it has none
+	Source string // file:line
+	Reason SkipReason
+	Detail string
+}
+
+// ImportReport summarises a SecRule import.
+type ImportReport struct {
+	Files    []string
+	Imported int
+	Skipped  []Skipped
+}
+
+// crsVariables maps SecRule variables to the request fields that hold them.
+// Access logs have no request body, cookies or other headers, and the query
+// string is only available as part of the URI, so ARGS rules are matched
+// against the whole URI.
+var crsVariables = map[string][]string{
+	"REQUEST_URI":                {FieldURI},
+	"REQUEST_URI_RAW":            {FieldURI},
+	"REQUEST_LINE":               {FieldURI},
+	"REQUEST_FILENAME":           {FieldURI},
+	"REQUEST_BASENAME":           {FieldURI},
+	"QUERY_STRING":               {FieldURI},
+	"ARGS":                       {FieldURI},
+	"ARGS_GET":                   {FieldURI},
+	"ARGS_NAMES":                 {FieldURI},
+	"ARGS_GET_NAMES":             {FieldURI},
+	"REQUEST_HEADERS:USER-AGENT": {FieldUserAgent},
+	"REQUEST_HEADERS:REFERER":    {FieldReferer},
+	"REQUEST_HEADERS":            {FieldUserAgent, FieldReferer},
+}
+
+// crsCategories maps CRS "attack-*" tags to rule categories; other attack
+// tags keep their suffix ("attack-protocol" -> "protocol").
+var crsCategories = map[string]string{
+	"attack-sqli":               CategorySQLi,
+	"attack-xss":                CategoryXSS,
+	"attack-lfi":                CategoryLFI,
+	"attack-rce":                CategoryCmdi, // REQUEST-932: OS command injection
+	"attack-injection-php":      CategoryRCE,
+	"attack-reputation-scanner": CategoryScanner,
+}
+
+// crsSeverities maps ModSecurity severities, by name or number, to ours.
+var crsSeverities = map[string]Severity{
+	"0": SeverityCritical, "EMERGENCY": SeverityCritical,
+	"1": SeverityCritical, "ALERT": SeverityCritical,
+	"2": SeverityCritical, "CRITICAL": SeverityCritical,
+	"3": SeverityHigh, "ERROR": SeverityHigh,
+	"4": SeverityMedium, "WARNING": SeverityMedium,
+	"5": SeverityLow, "NOTICE": SeverityLow,
+	"6": SeverityInfo, "INFO": SeverityInfo,
+	"7": SeverityInfo, "DEBUG": SeverityInfo,
+}
+
+// crsCategory is used for imported rules without an attack tag.
+const crsCategory = "crs"
+
+// LoadSecRuleFiles imports the SecRule directives of every file matching
+// patterns (glob syntax), in order. Rules get the ID "crs-<id>". Rules that
+// cannot be expressed in this engine are listed in the report's Skipped
+// instead of failing the import; only unreadable files and patterns that
+// match nothing are errors.
+func LoadSecRuleFiles(patterns []string) ([]Rule, *ImportReport, error) {
+	report := &ImportReport{}
+	var imported []Rule
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid pattern %q: %v", pattern, err)
+		}
+		if len(files) == 0 {
+			return nil, nil, fmt.Errorf("no files match %q", pattern)
+		}
+		for _, file := range files {
+			f, err := os.Open(file)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to open rule file: %v", err)
+			}
+			rules, skipped, err := ParseSecRules(f, file)
+			f.Close()
+			if err != nil {
+				return nil, nil, err
+			}
+			report.Files = append(report.Files, file)
+			report.Skipped = append(report.Skipped, skipped...)
+			for _, r := range rules {
+				if seen[r.ID] {
+					report.Skipped = append(report.Skipped, Skipped{ID: strings.TrimPrefix(r.ID, "crs-"), Source: file, Reason: SkipDuplicate})
+					continue
+				}
+				seen[r.ID] = true
+				imported = append(imported, r)
+			}
+		}
+	}
+	report.Imported = len(imported)
+	return imported, report, nil
+}
+
+// ParseSecRules translates the SecRule directives read from r into rules.
+// source names r in Skipped entries. Directives other than SecRule
+// (SecAction, SecMarker...) only configure ModSecurity and are ignored.
+func ParseSecRules(r io.Reader, source string) ([]Rule, []Skipped, error) {
+	var result []Rule
+	var skipped []Skipped
+	inChain := false // the previous rule had the chain action
+	var chainStart *Skipped
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	var directive strings.Builder
+	start := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if directive.Len() == 0 {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			start = lineNo
+			line = trimmed
+		} else {
+			line = strings.TrimLeft(line, " \t")
+		}
+		if cont, ok := strings.CutSuffix(line, "\\"); ok {
+			directive.WriteString(cont)
+			continue
+		}
+		directive.WriteString(line)
+		text := directive.String()
+		directive.Reset()
+
+		where := fmt.Sprintf("%s:%d", source, start)
+		args := splitDirective(text)
+		if len(args) == 0 || !strings.EqualFold(args[0], "SecRule") {
+			continue
+		}
+		rule, actions, skip := translateSecRule(args, where)
+
+		// A chain matches only when every rule in it does; the
+		// analyzer cannot express that, so the whole chain is skipped
+		// and reported under the id of its first rule.
+		if inChain {
+			inChain = actions.chain
+			if !inChain && chainStart != nil {
+				skipped = append(skipped, *chainStart)
+				chainStart = nil
+			}
+			continue
+		}
+		if actions.chain {
+			inChain = true
+			chainStart = &Skipped{ID: actions.id, Source: where, Reason: SkipChain}
+			continue
+		}
+		if skip != nil {
+			skipped = append(skipped, *skip)
+			continue
+		}
+		result = append(result, rule)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error reading %s: %v", source, err)
+	}
+	if chainStart != nil {
+		skipped = append(skipped, *chainStart)
+	}
+	return result, skipped, nil
+}
+
+type secRuleActions struct {
+	id       string
+	msg      string
+	severity string
+	tags     []string
+	chain    bool
+}
+
+// translateSecRule converts one SecRule (split into its arguments) to a
+// Rule, or says why it cannot be.
+func translateSecRule(args []string, where string) (Rule, secRuleActions, *Skipped) {
+	var actions secRuleActions
+	if len(args) >= 4 {
+		actions = parseActions(args[3])
+	}
+	skip := func(reason SkipReason, detail string) (Rule, secRuleActions, *Skipped) {
+		return Rule{}, actions, &Skipped{ID: actions.id, Source: where, Reason: reason, Detail: detail}
+	}
+	if len(args) < 3 {
+		return skip(SkipInvalid, "SecRule needs variables and an operator")
+	}
+	if actions.id == "" {
+		return skip(SkipInvalid, "missing id")
+	}
+
+	// Operator
+	op, arg := "@rx", args[2]
+	if strings.HasPrefix(arg, "!") {
+		return skip(SkipNegated, args[2])
+	}
+	if strings.HasPrefix(arg, "@") {
+		op, arg, _ = strings.Cut(arg, " ")
+		arg = strings.TrimSpace(arg)
+	}
+	var match string
+	switch op {
+	case "@rx":
+		match = RegexPrefix + arg
+	case "@pm":
+		words := strings.Fields(arg)
+		if len(words) == 0 {
+			return skip(SkipInvalid, "@pm without phrases")
+		}
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		match = RegexPrefix + strings.Join(words, "|")
+	case "@contains":
+		if arg == "" {
+			return skip(SkipInvalid, "@contains without a string")
+		}
+		match = RegexPrefix + regexp.QuoteMeta(arg)
+	default:
+		return skip(SkipOperator, op)
+	}
+	if _, err := compileMatcher(match); err != nil {
+		return skip(SkipRegex, err.Error())
+	}
+
+	// Variables
+	var targets []string
+	var unsupported []string
+	for _, v := range strings.Split(args[1], "|") {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.HasPrefix(v, "!") {
+			// Exclusions only narrow collections we match as a whole.
+			continue
+		}
+		fields, ok := crsVariables[strings.ToUpper(v)]
+		if !ok {
+			unsupported = append(unsupported, v)
+			continue
+		}
+		for _, f := range fields {
+			if !containsString(targets, f) {
+				targets = append(targets, f)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return skip(SkipVariables, strings.Join(unsupported, "|"))
+	}
+
+	rule := Rule{
+		ID:          "crs-" + actions.id,
+		Category:    crsCategory,
+		Severity:    SeverityMedium,
+		Targets:     targets,
+		Match:       match,
+		Description: actions.msg,
+	}
+	if s, ok := crsSeverities[strings.ToUpper(actions.severity)]; ok {
+		rule.Severity = s
+	}
+	for _, tag := range actions.tags {
+		if c, ok := crsCategories[tag]; ok {
+			rule.Category = c
+			break
+		}
+		if suffix, ok := strings.CutPrefix(tag, "attack-"); ok {
+			rule.Category = suffix
+			break
+		}
+	}
+	return rule, actions, nil
+}
+
+// splitDirective splits a configuration line into whitespace-separated
+// arguments. Double-quoted arguments may contain spaces and \" escapes;
+// every other backslash is kept, since it belongs to the regex.
+func splitDirective(s string) []string {
+	var args []string
+	for i := 0; i < len(s); {
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		var sb strings.Builder
+		if s[i] == '"' {
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) && s[i+1] == '"' {
+					i++
+				}
+				sb.WriteByte(s[i])
+				i++
+			}
+			i++ // closing quote
+		} else {
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+				sb.WriteByte(s[i])
+				i++
+			}
+		}
+		args = append(args, sb.String())
+	}
+	return args
+}
+
+// parseActions extracts the actions the import needs from a SecRule action
+// list such as "id:942100,phase:2,msg:'SQL Injection',tag:'attack-sqli'".
+func parseActions(s string) secRuleActions {
+	var a secRuleActions
+	for _, action := range splitActions(s) {
+		name, value, _ := strings.Cut(action, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.Trim(strings.TrimSpace(value), "'")
+		switch name {
+		case "id":
+			a.id = value
+		case "msg":
+			a.msg = value
+		case "severity":
+			a.severity = value
+		case "tag":
+			a.tags = append(a.tags, value)
+		case "chain":
+			a.chain = true
+		}
+	}
+	return a
+}
+
+// splitActions splits on commas outside single quotes.
+func splitActions(s string) []string {
+	var result []string
+	quoted := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'':
+			if i == 0 || s[i-1] != '\\' {
+				quoted = !quoted
+			}
+		case ',':
+			if !quoted {
+				result = append(result, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(result, s[start:])
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const crsSample = `# ------------------------------------------------------------------------
+# OWASP CRS excerpt
+# ------------------------------------------------------------------------
+SecRule TX:DETECTION_PARANOIA_LEVEL "@lt 1" "id:942011,phase:1,pass,nolog,skipAfter:END-REQUEST-942-APPLICATION-ATTACK-SQLI"
+
+SecRule REQUEST_COOKIES|!REQUEST_COOKIES:/__utm/|ARGS_NAMES|ARGS|XML:/* "@rx (?i)\bunion\b.{1,100}?\bselect\b" \
+    "id:942100,\
+    phase:2,\
+    block,\
+    t:none,t:urlDecodeUni,\
+    msg:'SQL Injection Attack: UNION SELECT',\
+    tag:'application-multi',\
+    tag:'attack-sqli',\
+    severity:'CRITICAL'"
+
+SecRule REQUEST_HEADERS:User-Agent "@pm sqlmap nikto" \
+    "id:913100,phase:1,block,msg:'Found User-Agent associated with security scanner',tag:'attack-reputation-scanner',severity:'CRITICAL'"
+
+SecRule REQUEST_URI "@contains /etc/passwd" "id:930120,phase:1,msg:'OS File Access Attempt',tag:'attack-lfi',severity:'2'"
+
+SecRule ARGS "@rx \x22\s*;" "id:932100,phase:2,msg:'Quoted command',tag:'attack-protocol'"
+
+SecRule ARGS "@detectSQLi" "id:942101,phase:2,block,msg:'SQL Injection Attack Detected via libinjection',tag:'attack-sqli'"
+
+SecRule ARGS "!@rx ^[a-z]+$" "id:920999,phase:2,pass"
+
+SecRule ARGS "@rx (?<=select)\s+from" "id:942200,phase:2,tag:'attack-sqli'"
+
+SecRule REQUEST_BODY "@rx <script" "id:941999,phase:2,tag:'attack-xss'"
+
+SecRule REQUEST_METHOD "@streq POST" "id:920180,phase:1,chain,msg:'POST without Content-Length'"
+    SecRule &REQUEST_HEADERS:Content-Length "@eq 0" "t:none"
+
+SecRule ARGS "@rx a" "phase:2,msg:'no id'"
+
+SecMarker "END-REQUEST-942-APPLICATION-ATTACK-SQLI"
+`
+
+func TestParseSecRules(t *testing.T) {
+	rules, skipped, err := ParseSecRules(strings.NewReader(crsSample), "sample.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Rule{
+		{ID: "crs-942100", Category: CategorySQLi, Severity: SeverityCritical, Targets: []string{FieldURI},
+			Match: `re:(?i)\bunion\b.{1,100}?\bselect\b`, Description: "SQL Injection Attack: UNION SELECT"},
+		{ID: "crs-913100", Category: CategoryScanner, Severity: SeverityCritical, Targets: []string{FieldUserAgent},
+			Match: "re:sqlmap|nikto", Description: "Found User-Agent associated with security scanner"},
+		{ID: "crs-930120", Category: CategoryLFI, Severity: SeverityCritical, Targets: []string{FieldURI},
+			Match: "re:/etc/passwd", Description: "OS File Access Attempt"},
+		{ID: "crs-932100", Category: "protocol", Severity: SeverityMedium, Targets: []string{FieldURI},
+			Match: `re:\x22\s*;`, Description: "Quoted command"},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("rules:\n got %+v\nwant %+v", rules, expected)
+	}
+
+	var got []string
+	for _, s := range skipped {
+		got = append(got, s.ID+" "+string(s.Reason))
+	}
+	wantSkipped := []string{
+		"942011 unsupported-operator",
+		"942101 unsupported-operator",
+		"920999 negated-operator",
+		"942200 incompatible-regex",
+		"941999 unsupported-variables",
+		"920180 chained-rule",
+		" invalid",
+	}
+	if !reflect.DeepEqual(got, wantSkipped) {
+		t.Errorf("skipped:\n got %v\nwant %v", got, wantSkipped)
+	}
+	if skipped[0].Source != "sample.conf:4" {
+		t.Errorf("source = %q, want sample.conf:4", skipped[0].Source)
+	}
+
+	// The imported rules compile and match what the CRS rules would.
+	engine, err := Compile(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := engine.Match(Request{URI: "/?id=1%20UNION%20ALL%20SELECT%20user", UserAgent: "Nikto/2.1.6"})
+	if len(matches) != 2 || matches[0].Rule.ID != "crs-942100" || matches[1].Rule.ID != "crs-913100" {
+		t.Errorf("unexpected matches %+v", matches)
+	}
+}
+
+func TestLoadSecRuleFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"REQUEST-930-LFI.conf", "REQUEST-942-SQLI.conf"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(crsSample), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rules, report, err := LoadSecRuleFiles([]string{filepath.Join(dir, "REQUEST-*.conf")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Files) != 2 || report.Imported != 4 || len(rules) != 4 {
+		t.Errorf("files=%d imported=%d rules=%d, want 2/4/4", len(report.Files), report.Imported, len(rules))
+	}
+	duplicates := 0
+	for _, s := range report.Skipped {
+		if s.Reason == SkipDuplicate {
+			duplicates++
+		}
+	}
+	if duplicates != 4 {
+		t.Errorf("duplicates = %d, want 4", duplicates)
+	}
+
+	if _, _, err := LoadSecRuleFiles([]string{filepath.Join(dir, "missing-*.conf")}); err == nil {
+		t.Error("expected an error for a pattern matching no files")
+	}
+}
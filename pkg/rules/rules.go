@@ -0,0 +1,399 @@
+// Package rules matches requests against a set of detection rules. Each rule
+// has an ID, a category (sqli, xss, scanner...), a severity, the request
+// fields it inspects and a match expression, so detections can be reported
+// and tuned per rule rather than per hard-coded pattern list.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+
+	"kinsta-log-analyzer/pkg/utils"
+)
+
+// Categories used by the bundled configuration. Rules may use any other
+// category name as well.
+const (
+	CategorySQLi       = "sqli"
+	CategoryXSS        = "xss"
+	CategoryTraversal  = "traversal"
+	CategoryLFI        = "lfi"
+	CategoryRFI        = "rfi"
+	CategoryRCE        = "rce"
+	CategoryCmdi       = "cmdi"
+	CategoryLog4Shell  = "log4shell"
+	CategoryShellshock = "shellshock"
+	CategoryExposure   = "exposure"
+	CategoryScanner    = "scanner"
+	CategoryCrawler    = "crawler"
+)
+
+// Request fields a rule can target.
+const (
+	FieldURI       = "uri"
+	FieldUserAgent = "ua"
+	FieldReferer   = "referer"
+)
+
+var fieldIndex = map[string]int{FieldURI: 0, FieldUserAgent: 1, FieldReferer: 2}
+
+const numFields = 3
+
+// Severity is how serious a detection is, from info to critical.
+type Severity string
+
+const (
+	SeverityInfo     Severity = "info"
+	SeverityLow      Severity = "low"
+	SeverityMedium   Severity = "medium"
+	SeverityHigh     Severity = "high"
+	SeverityCritical Severity = "critical"
+)
+
+var severityRanks = map[Severity]int{
+	SeverityInfo: 1, SeverityLow: 2, SeverityMedium: 3, SeverityHigh: 4, SeverityCritical: 5,
+}
+
+// Rank orders severities: info is 1, critical is 5, anything unknown is 0.
+func (s Severity) Rank() int {
+	return severityRanks[s]
+}
+
+// RegexPrefix marks a match expression as a regular expression; anything
+// else is a substring.
+const RegexPrefix = "re:"
+
+// Rule is one detection rule. Match is a case-insensitive substring, or a
+// regular expression when prefixed with "re:". Targets defaults to uri.
+// CVE lists the vulnerabilities a rule detects exploitation of, if any.
+type Rule struct {
+	ID          string   `yaml:"id"`
+	Category    string   `yaml:"category"`
+	Severity    Severity `yaml:"severity"`
+	Targets     []string `yaml:"targets"`
+	Match       string   `yaml:"match"`
+	Description string   `yaml:"description"`
+	CVE         []string `yaml:"cve"`
+}
+
+// Request holds the fields of a request that rules are matched against.
+type Request struct {
+	URI       string
+	UserAgent string
+	Referer   string
+}
+
+func (r *Request) field(i int) string {
+	switch i {
+	case 0:
+		return r.URI
+	case 1:
+		return r.UserAgent
+	default:
+		return r.Referer
+	}
+}
+
+// Match is a rule that matched a request, with the field it matched in and
+// that field's raw value.
+type Match struct {
+	Rule  *Rule
+	Field string
+	Value string
+}
+
+// Engine is a compiled, read-only rule set; it is safe for concurrent use.
+// A nil Engine matches nothing.
+type Engine struct {
+	rules      []Rule
+	compiled   []compiledRule
+	byID       map[string]int
+	byCategory map[string][]int
+}
+
+type compiledRule struct {
+	targets [numFields]bool
+	matcher matcher
+}
+
+// Compile validates rules and prepares them for matching. IDs must be unique,
+// and every rule needs a category, a known severity and targets, and a valid
+// match expression.
+func Compile(rules []Rule) (*Engine, error) {
+	e := &Engine{
+		rules:      make([]Rule, len(rules)),
+		compiled:   make([]compiledRule, len(rules)),
+		byID:       make(map[string]int, len(rules)),
+		byCategory: make(map[string][]int),
+	}
+	for i, r := range rules {
+		_, duplicate := e.byID[r.ID]
+		switch {
+		case r.ID == "":
+			return nil, fmt.Errorf("rule %d: missing id", i+1)
+		case duplicate:
+			return nil, fmt.Errorf("rule %s: duplicate id", r.ID)
+		case r.Category == "":
+			return nil, fmt.Errorf("rule %s: missing category", r.ID)
+		case r.Severity.Rank() == 0:
+			return nil, fmt.Errorf("rule %s: unknown severity %q", r.ID, r.Severity)
+		case r.Match == "" || r.Match == RegexPrefix:
+			return nil, fmt.Errorf("rule %s: missing match expression", r.ID)
+		}
+		if len(r.Targets) == 0 {
+			r.Targets = []string{FieldURI}
+		}
+		var c compiledRule
+		for _, t := range r.Targets {
+			idx, ok := fieldIndex[strings.ToLower(t)]
+			if !ok {
+				return nil, fmt.Errorf("rule %s: unknown target %q", r.ID, t)
+			}
+			c.targets[idx] = true
+		}
+		m, err := compileMatcher(r.Match)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %v", r.ID, err)
+		}
+		c.matcher = m
+		e.rules[i] = r
+		e.compiled[i] = c
+		e.byID[r.ID] = i
+		e.byCategory[r.Category] = append(e.byCategory[r.Category], i)
+	}
+	return e, nil
+}
+
+// Rules returns the compiled rules in configuration order.
+func (e *Engine) Rules() []Rule {
+	if e == nil {
+		return nil
+	}
+	return e.rules
+}
+
+// Rule returns the rule with the given ID.
+func (e *Engine) Rule(id string) (*Rule, bool) {
+	if e == nil {
+		return nil, false
+	}
+	i, ok := e.byID[id]
+	if !ok {
+		return nil, false
+	}
+	return &e.rules[i], true
+}
+
+// Match returns every rule that matches req, in configuration order. Each
+// targeted field is tried as-is (lowercased) and in its normalised form (see
+// utils.NormalizePayload), so encoded or comment-obfuscated payloads are
+// caught as well. It returns nil without allocating when nothing matches.
+func (e *Engine) Match(req Request) []Match {
+	if e == nil {
+		return nil
+	}
+	var views requestViews
+	var matches []Match
+	for i := range e.compiled {
+		if field, ok := e.compiled[i].match(&req, &views); ok {
+			matches = append(matches, Match{Rule: &e.rules[i], Field: field, Value: req.field(fieldIndex[field])})
+		}
+	}
+	return matches
+}
+
+// MatchesCategory reports whether any rule of category matches req.
+func (e *Engine) MatchesCategory(req Request, category string) bool {
+	if e == nil {
+		return false
+	}
+	var views requestViews
+	for _, i := range e.byCategory[category] {
+		if _, ok := e.compiled[i].match(&req, &views); ok {
+			return true
+		}
+	}
+	return false
+}
+
+var fieldNames = [numFields]string{FieldURI, FieldUserAgent, FieldReferer}
+
+// match returns the first targeted field of req that c matches.
+func (c *compiledRule) match(req *Request, views *requestViews) (string, bool) {
+	for i, targeted := range c.targets {
+		if !targeted {
+			continue
+		}
+		raw, normalized := views.get(req, i)
+		if c.matcher.match(raw) || (normalized != "" && c.matcher.match(normalized)) {
+			return fieldNames[i], true
+		}
+	}
+	return "", false
+}
+
+// requestViews lazily builds the lowercase and normalised view of each field,
+// so fields no rule targets cost nothing.
+type requestViews struct {
+	done       [numFields]bool
+	raw        [numFields]string
+	normalized [numFields]string // "" when equal to raw
+}
+
+func (v *requestViews) get(req *Request, i int) (raw, normalized string) {
+	if !v.done[i] {
+		s := req.field(i)
+		v.raw[i] = strings.ToLower(s)
+		if n := utils.NormalizePayload(s); n != v.raw[i] {
+			v.normalized[i] = n
+		}
+		v.done[i] = true
+	}
+	return v.raw[i], v.normalized[i]
+}
+
+// matcher is a compiled match expression: a case-insensitive regular
+// expression, or lowercase substrings of which any must occur. Every match of
+// re contains at least one string of each group in required, so text missing
+// a group is rejected without running the regex.
+type matcher struct {
+	substrings []string
+	re         *regexp.Regexp
+	required   [][]string
+}
+
+// maxClassLiterals bounds the size of a character class that
+// requiredLiterals expands into single-character literals.
+const maxClassLiterals = 8
+
+// regexMeta are the characters that make a pattern more than a list of
+// "|"-separated literals.
+const regexMeta = `\.+*?()[]{}^$`
+
+func compileMatcher(expr string) (matcher, error) {
+	pattern, ok := strings.CutPrefix(expr, RegexPrefix)
+	if !ok {
+		return matcher{substrings: []string{strings.ToLower(expr)}}, nil
+	}
+	if !strings.ContainsAny(pattern, regexMeta) && !strings.Contains("|"+pattern+"|", "||") {
+		// "googlebot|bingbot" is just a set of substrings.
+		return matcher{substrings: strings.Split(strings.ToLower(pattern), "|")}, nil
+	}
+	re, err := regexp.Compile("(?i)" + pattern)
+	if err != nil {
+		return matcher{}, fmt.Errorf("invalid pattern %q: %v", expr, err)
+	}
+	m := matcher{re: re}
+	if parsed, err := syntax.Parse("(?i)"+pattern, syntax.Perl); err == nil {
+		m.required = requiredLiterals(parsed.Simplify())
+		// Check the cheapest groups first, then the most selective.
+		sort.SliceStable(m.required, func(i, j int) bool {
+			gi, gj := m.required[i], m.required[j]
+			if len(gi) != len(gj) {
+				return len(gi) < len(gj)
+			}
+			return shortestLen(gi) > shortestLen(gj)
+		})
+	}
+	return m, nil
+}
+
+// match reports whether the expression occurs in s, which must be lowercase.
+func (m matcher) match(s string) bool {
+	if m.re == nil {
+		return containsAny(s, m.substrings)
+	}
+	for _, group := range m.required {
+		if !containsAny(s, group) {
+			return false
+		}
+	}
+	return m.re.MatchString(s)
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// requiredLiterals returns groups of lowercase strings such that every match
+// of re contains at least one string of each group. It returns nil when
+// nothing is known to be required.
+func requiredLiterals(re *syntax.Regexp) [][]string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return [][]string{{strings.ToLower(string(re.Rune))}}
+	case syntax.OpCharClass:
+		// A small class such as [;|`] is an alternation of characters.
+		var group []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			if re.Rune[i+1]-re.Rune[i] >= maxClassLiterals {
+				return nil
+			}
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if l := strings.ToLower(string(r)); !containsString(group, l) {
+					group = append(group, l)
+				}
+			}
+			if len(group) > maxClassLiterals {
+				return nil
+			}
+		}
+		if len(group) > 0 {
+			return [][]string{group}
+		}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var result [][]string
+		for _, sub := range re.Sub {
+			result = append(result, requiredLiterals(sub)...)
+		}
+		return result
+	case syntax.OpAlternate:
+		// Only a single group per alternative can be combined: a match
+		// contains one of the alternatives' strings.
+		var group []string
+		for _, sub := range re.Sub {
+			groups := requiredLiterals(sub)
+			if len(groups) == 0 {
+				return nil
+			}
+			group = append(group, longestGroup(groups)...)
+		}
+		return [][]string{group}
+	}
+	return nil
+}
+
+// longestGroup picks the group whose shortest string is longest, the one
+// that rejects the most text.
+func longestGroup(groups [][]string) []string {
+	var best []string
+	bestLen := 0
+	for _, g := range groups {
+		if shortest := shortestLen(g); shortest > bestLen {
+			best, bestLen = g, shortest
+		}
+	}
+	return best
+}
+
+func shortestLen(group []string) int {
+	shortest := len(group[0])
+	for _, l := range group[1:] {
+		shortest = min(shortest, len(l))
+	}
+	return shortest
+}
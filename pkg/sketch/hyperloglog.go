@@ -0,0 +1,150 @@
+// Package sketch provides fixed-memory probabilistic data structures used to
+// keep aggregation bounded on very large logs.
+package sketch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+const (
+	// DefaultPrecision uses 2^12 registers (4KB), ~1.6% standard error.
+	DefaultPrecision = 12
+	minPrecision     = 4
+	maxPrecision     = 18
+)
+
+// HyperLogLog estimates the number of distinct strings added to it using
+// 2^precision one-byte registers, independent of the true cardinality.
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+// NewHyperLogLog creates a sketch with 2^precision registers. Precision is
+// clamped to [4, 18]; 0 selects DefaultPrecision.
+func NewHyperLogLog(precision int) *HyperLogLog {
+	if precision == 0 {
+		precision = DefaultPrecision
+	}
+	if precision < minPrecision {
+		precision = minPrecision
+	}
+	if precision > maxPrecision {
+		precision = maxPrecision
+	}
+	return &HyperLogLog{
+		precision: uint8(precision),
+		registers: make([]uint8, 1<<precision),
+	}
+}
+
+// Precision returns log2 of the number of registers.
+func (h *HyperLogLog) Precision() int {
+	return int(h.precision)
+}
+
+// Add records s in the sketch.
+func (h *HyperLogLog) Add(s string) {
+	x := hash64(s)
+	idx := x >> (64 - h.precision)
+	// Rank of the first set bit in the remaining 64-p bits (1-based).
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1))) + 1
+	if rank > h.registers[idx] {
+		h.registers[idx] = rank
+	}
+}
+
+// Count returns the estimated number of distinct strings added.
+func (h *HyperLogLog) Count() int {
+	m := float64(len(h.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := alpha(m) * m * m / sum
+	// Small-range correction: linear counting is more accurate while many
+	// registers are still empty.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(estimate + 0.5)
+}
+
+// RelativeError returns the standard error of Count as a fraction (1.04/√m).
+func (h *HyperLogLog) RelativeError() float64 {
+	return 1.04 / math.Sqrt(float64(len(h.registers)))
+}
+
+// Merge folds other into h so that h estimates the cardinality of the union.
+// Both sketches must have the same precision.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if h.precision != other.precision {
+		return fmt.Errorf("cannot merge HyperLogLog with precision %d into %d", other.precision, h.precision)
+	}
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+	return nil
+}
+
+type hyperLogLogJSON struct {
+	Precision uint8  `json:"precision"`
+	Registers []byte `json:"registers"`
+}
+
+// MarshalJSON encodes the registers (base64) so the sketch can be persisted.
+func (h *HyperLogLog) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hyperLogLogJSON{Precision: h.precision, Registers: h.registers})
+}
+
+// UnmarshalJSON restores a sketch written by MarshalJSON.
+func (h *HyperLogLog) UnmarshalJSON(data []byte) error {
+	var v hyperLogLogJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v.Precision < minPrecision || v.Precision > maxPrecision || len(v.Registers) != 1<<v.Precision {
+		return fmt.Errorf("invalid HyperLogLog: precision %d with %d registers", v.Precision, len(v.Registers))
+	}
+	h.precision = v.Precision
+	h.registers = v.Registers
+	return nil
+}
+
+func alpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/m)
+}
+
+// hash64 is FNV-1a followed by the murmur3 64-bit finaliser. FNV alone has
+// poor avalanche on short, similar keys such as IP addresses. The hash is
+// deterministic across processes so sketches can be persisted and merged.
+func hash64(s string) uint64 {
+	x := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		x ^= uint64(s[i])
+		x *= 1099511628211
+	}
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
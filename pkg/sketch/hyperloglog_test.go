@@ -0,0 +1,96 @@
+package sketch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestHyperLogLogCount(t *testing.T) {
+	tests := []struct {
+		name     string
+		distinct int
+	}{
+		{"empty", 0},
+		{"small", 100},
+		{"medium", 10000},
+		{"large", 200000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHyperLogLog(DefaultPrecision)
+			for i := 0; i < tt.distinct; i++ {
+				ip := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+				h.Add(ip)
+				h.Add(ip) // duplicates must not change the estimate
+			}
+			got := h.Count()
+			tolerance := 3 * h.RelativeError() * float64(tt.distinct)
+			if math.Abs(float64(got-tt.distinct)) > tolerance {
+				t.Errorf("Count() = %d, want %d ± %.0f", got, tt.distinct, tolerance)
+			}
+		})
+	}
+}
+
+func TestHyperLogLogPrecisionClamp(t *testing.T) {
+	if h := NewHyperLogLog(0); len(h.registers) != 1<<DefaultPrecision {
+		t.Errorf("precision 0: got %d registers, want %d", len(h.registers), 1<<DefaultPrecision)
+	}
+	if h := NewHyperLogLog(1); len(h.registers) != 1<<minPrecision {
+		t.Errorf("precision 1: got %d registers, want %d", len(h.registers), 1<<minPrecision)
+	}
+	if h := NewHyperLogLog(30); len(h.registers) != 1<<maxPrecision {
+		t.Errorf("precision 30: got %d registers, want %d", len(h.registers), 1<<maxPrecision)
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	a := NewHyperLogLog(DefaultPrecision)
+	b := NewHyperLogLog(DefaultPrecision)
+	// 0..59999 in a, 40000..99999 in b: 100000 distinct in the union.
+	for i := 0; i < 100000; i++ {
+		key := fmt.Sprintf("/page/%d", i)
+		if i < 60000 {
+			a.Add(key)
+		}
+		if i >= 40000 {
+			b.Add(key)
+		}
+	}
+	if err := a.Merge(b); err != nil {
+		t.Fatalf("Merge() error = %v", err)
+	}
+	tolerance := 3 * a.RelativeError() * 100000
+	if got := a.Count(); math.Abs(float64(got-100000)) > tolerance {
+		t.Errorf("Count() after merge = %d, want 100000 ± %.0f", got, tolerance)
+	}
+
+	if err := a.Merge(NewHyperLogLog(10)); err == nil {
+		t.Error("Expected an error when merging different precisions")
+	}
+}
+
+func TestHyperLogLogJSON(t *testing.T) {
+	h := NewHyperLogLog(10)
+	for i := 0; i < 5000; i++ {
+		h.Add(fmt.Sprintf("192.0.2.%d-%d", i%256, i))
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	restored := &HyperLogLog{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if restored.Count() != h.Count() {
+		t.Errorf("restored Count() = %d, want %d", restored.Count(), h.Count())
+	}
+
+	if err := json.Unmarshal([]byte(`{"precision":10,"registers":"AAAA"}`), restored); err == nil {
+		t.Error("Expected an error for a register count that does not match the precision")
+	}
+}
@@ -0,0 +1,258 @@
+package sketch
+
+import (
+	"container/heap"
+	"encoding/json"
+	"sort"
+)
+
+// Item is a counted key. Count may overestimate the true count by at most
+// Error (always 0 in exact mode or while the table has not overflowed).
+type Item struct {
+	Key   string `json:"key"`
+	Count int    `json:"count"`
+	Error int    `json:"error,omitempty"`
+}
+
+// TopK tracks the most frequent keys. With capacity > 0 it uses the
+// Space-Saving algorithm: at most capacity keys are monitored and, once the
+// table is full, a new key replaces the current minimum and inherits its count
+// as error. Any key whose true count exceeds Total()/capacity is guaranteed to
+// be monitored. With capacity <= 0 it counts every key exactly.
+type TopK struct {
+	capacity   int
+	total      int
+	overflowed bool
+	items      map[string]*topKEntry
+	heap       topKHeap
+}
+
+type topKEntry struct {
+	Item
+	index int
+}
+
+// NewTopK creates a counter bounded to capacity keys; capacity <= 0 selects
+// exact (unbounded) counting.
+func NewTopK(capacity int) *TopK {
+	return &TopK{
+		capacity: capacity,
+		items:    make(map[string]*topKEntry),
+	}
+}
+
+// Add increments key by n.
+func (t *TopK) Add(key string, n int) {
+	t.total += n
+	if e, ok := t.items[key]; ok {
+		e.Count += n
+		if t.capacity > 0 {
+			heap.Fix(&t.heap, e.index)
+		}
+		return
+	}
+	if t.capacity <= 0 {
+		t.items[key] = &topKEntry{Item: Item{Key: key, Count: n}}
+		return
+	}
+	if len(t.items) < t.capacity {
+		e := &topKEntry{Item: Item{Key: key, Count: n}}
+		t.items[key] = e
+		heap.Push(&t.heap, e)
+		return
+	}
+	t.overflowed = true
+	min := t.heap[0]
+	delete(t.items, min.Key)
+	min.Key = key
+	min.Error = min.Count
+	min.Count += n
+	t.items[key] = min
+	heap.Fix(&t.heap, 0)
+}
+
+// Get returns the (possibly overestimated) count of key and whether it is
+// currently monitored.
+func (t *TopK) Get(key string) (Item, bool) {
+	e, ok := t.items[key]
+	if !ok {
+		return Item{Key: key}, false
+	}
+	return e.Item, true
+}
+
+// Capacity returns the maximum number of monitored keys; <= 0 means exact.
+func (t *TopK) Capacity() int {
+	return t.capacity
+}
+
+// Len returns the number of monitored keys.
+func (t *TopK) Len() int {
+	return len(t.items)
+}
+
+// Total returns the sum of all increments, including evicted keys.
+func (t *TopK) Total() int {
+	return t.total
+}
+
+// Exact reports whether every count is exact: either exact mode, or the
+// table has never overflowed.
+func (t *TopK) Exact() bool {
+	return !t.overflowed
+}
+
+// MaxError bounds both the overestimation of any monitored count and the
+// true count of any unmonitored key: the current minimum monitored count.
+func (t *TopK) MaxError() int {
+	if !t.overflowed {
+		return 0
+	}
+	return t.heap[0].Count
+}
+
+// Items returns every monitored key in unspecified order.
+func (t *TopK) Items() []Item {
+	result := make([]Item, 0, len(t.items))
+	for _, e := range t.items {
+		result = append(result, e.Item)
+	}
+	return result
+}
+
+// Top returns up to n keys by descending count; ties are broken by key so
+// the output is deterministic.
+func (t *TopK) Top(n int) []Item {
+	result := t.Items()
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if n < 0 {
+		n = 0
+	}
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// Merge adds other's counts into t, as if every increment of other had been
+// applied to t. A key monitored on only one side may have been evicted on the
+// other, so it is credited with that side's MaxError (as count and as error),
+// which keeps Count an upper bound. If the union exceeds t's capacity only the
+// largest counts are kept. t adopts the larger of the two capacities; an exact
+// t merged with a bounded other becomes bounded, since other's counts are
+// already approximate.
+func (t *TopK) Merge(other *TopK) {
+	tMin, oMin := t.MaxError(), other.MaxError()
+	merged := make([]Item, 0, len(t.items)+len(other.items))
+	for key, e := range t.items {
+		item := e.Item
+		if o, ok := other.items[key]; ok {
+			item.Count += o.Count
+			item.Error += o.Error
+		} else {
+			item.Count += oMin
+			item.Error += oMin
+		}
+		merged = append(merged, item)
+	}
+	for key, o := range other.items {
+		if _, ok := t.items[key]; ok {
+			continue
+		}
+		item := o.Item
+		item.Count += tMin
+		item.Error += tMin
+		merged = append(merged, item)
+	}
+	t.total += other.total
+	t.overflowed = t.overflowed || other.overflowed
+	if other.capacity > 0 && (t.capacity <= 0 || other.capacity > t.capacity) {
+		t.capacity = other.capacity
+	}
+	t.reset(merged)
+}
+
+// reset replaces the monitored keys with items, keeping the largest counts if
+// they exceed capacity. Items are sorted first so that the heap layout, and
+// therefore which key is evicted next on a tie, does not depend on the order
+// items arrived in.
+func (t *TopK) reset(items []Item) {
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Key < items[j].Key
+	})
+	if t.capacity > 0 && len(items) > t.capacity {
+		items = items[:t.capacity]
+		t.overflowed = true
+	}
+	t.items = make(map[string]*topKEntry, len(items))
+	t.heap = nil
+	for _, item := range items {
+		e := &topKEntry{Item: item}
+		t.items[item.Key] = e
+		if t.capacity > 0 {
+			t.heap = append(t.heap, e)
+			e.index = len(t.heap) - 1
+		}
+	}
+	heap.Init(&t.heap)
+}
+
+type topKJSON struct {
+	Capacity   int    `json:"capacity"`
+	Total      int    `json:"total"`
+	Overflowed bool   `json:"overflowed,omitempty"`
+	Items      []Item `json:"items"`
+}
+
+// MarshalJSON encodes the monitored keys and counters so a TopK can be
+// persisted and later merged.
+func (t *TopK) MarshalJSON() ([]byte, error) {
+	items := t.Items()
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	return json.Marshal(topKJSON{Capacity: t.capacity, Total: t.total, Overflowed: t.overflowed, Items: items})
+}
+
+// UnmarshalJSON restores a TopK written by MarshalJSON.
+func (t *TopK) UnmarshalJSON(data []byte) error {
+	var v topKJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	t.capacity = v.Capacity
+	t.total = v.Total
+	t.overflowed = v.Overflowed
+	t.reset(v.Items)
+	return nil
+}
+
+// topKHeap is a min-heap on Count used to find the eviction candidate.
+type topKHeap []*topKEntry
+
+func (h topKHeap) Len() int           { return len(h) }
+func (h topKHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *topKHeap) Push(x any) {
+	e := x.(*topKEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *topKHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,188 @@
+package sketch
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestTopKExact(t *testing.T) {
+	topk := NewTopK(0)
+	for i := 0; i < 1000; i++ {
+		topk.Add(fmt.Sprintf("/random/%d", i), 1)
+	}
+	topk.Add("/wp-login.php", 50)
+
+	if topk.Len() != 1001 {
+		t.Errorf("Len() = %d, want 1001", topk.Len())
+	}
+	if !topk.Exact() {
+		t.Error("Expected exact mode to report Exact() == true")
+	}
+	top := topk.Top(1)
+	if len(top) != 1 || top[0].Key != "/wp-login.php" || top[0].Count != 50 {
+		t.Errorf("Top(1) = %+v, want /wp-login.php with 50", top)
+	}
+}
+
+func TestTopKSpaceSaving(t *testing.T) {
+	const capacity = 100
+	topk := NewTopK(capacity)
+	heavy := map[string]int{"/wp-login.php": 5000, "/xmlrpc.php": 3000, "/": 1000}
+
+	// Interleave heavy hitters with a scanner hitting unique random URLs.
+	for i := 0; i < 20000; i++ {
+		topk.Add(fmt.Sprintf("/scan/%d", i), 1)
+		for key, n := range heavy {
+			if i < n {
+				topk.Add(key, 1)
+			}
+		}
+	}
+
+	if topk.Len() > capacity {
+		t.Fatalf("Len() = %d, exceeds capacity %d", topk.Len(), capacity)
+	}
+	if topk.Exact() {
+		t.Error("Expected Exact() == false after overflow")
+	}
+	if topk.Total() != 29000 {
+		t.Errorf("Total() = %d, want 29000", topk.Total())
+	}
+
+	top := topk.Top(3)
+	for i, want := range []string{"/wp-login.php", "/xmlrpc.php", "/"} {
+		if top[i].Key != want {
+			t.Errorf("Top(3)[%d].Key = %s, want %s", i, top[i].Key, want)
+		}
+		trueCount := heavy[want]
+		if top[i].Count < trueCount || top[i].Count-top[i].Error > trueCount {
+			t.Errorf("%s: count %d (error %d) does not bound true count %d", want, top[i].Count, top[i].Error, trueCount)
+		}
+	}
+
+	bound := topk.Total() / capacity
+	if topk.MaxError() > bound {
+		t.Errorf("MaxError() = %d, want <= Total/capacity = %d", topk.MaxError(), bound)
+	}
+}
+
+func TestTopKMerge(t *testing.T) {
+	a, b := NewTopK(0), NewTopK(0)
+	a.Add("/", 10)
+	a.Add("/shop/", 3)
+	b.Add("/", 5)
+	b.Add("/cart/", 7)
+	a.Merge(b)
+
+	want := map[string]int{"/": 15, "/cart/": 7, "/shop/": 3}
+	for key, count := range want {
+		if item, ok := a.Get(key); !ok || item.Count != count || item.Error != 0 {
+			t.Errorf("Get(%s) = %+v, want exact count %d", key, item, count)
+		}
+	}
+	if a.Total() != 25 || !a.Exact() {
+		t.Errorf("Total() = %d, Exact() = %v; want 25, true", a.Total(), a.Exact())
+	}
+}
+
+func TestTopKMergeBounded(t *testing.T) {
+	const capacity = 50
+	a, b := NewTopK(capacity), NewTopK(capacity)
+	for i := 0; i < 5000; i++ {
+		a.Add(fmt.Sprintf("/scan-a/%d", i), 1)
+		b.Add(fmt.Sprintf("/scan-b/%d", i), 1)
+		if i < 2000 {
+			a.Add("/wp-login.php", 1)
+		}
+		if i < 1500 {
+			b.Add("/wp-login.php", 1)
+		}
+	}
+	a.Merge(b)
+
+	if a.Len() > capacity {
+		t.Fatalf("Len() = %d, exceeds capacity %d", a.Len(), capacity)
+	}
+	top := a.Top(1)
+	if len(top) != 1 || top[0].Key != "/wp-login.php" {
+		t.Fatalf("Top(1) = %+v, want /wp-login.php", top)
+	}
+	if top[0].Count < 3500 || top[0].Count-top[0].Error > 3500 {
+		t.Errorf("count %d (error %d) does not bound true count 3500", top[0].Count, top[0].Error)
+	}
+	if a.Total() != 13500 {
+		t.Errorf("Total() = %d, want 13500", a.Total())
+	}
+}
+
+func TestTopKMergeCapacity(t *testing.T) {
+	bounded := func(capacity int) *TopK {
+		topk := NewTopK(capacity)
+		for i := 0; i < 100; i++ {
+			topk.Add(fmt.Sprintf("/page/%d", i), i+1)
+		}
+		return topk
+	}
+	tests := []struct {
+		name     string
+		into     *TopK
+		other    *TopK
+		capacity int
+	}{
+		{"exact into bounded", bounded(20), NewTopK(0), 20},
+		{"bounded into exact", NewTopK(0), bounded(20), 20},
+		{"larger capacity wins", bounded(10), bounded(20), 20},
+		{"keeps larger capacity", bounded(20), bounded(10), 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.into.Add("/", 1)
+			tt.into.Merge(tt.other)
+			if tt.into.capacity != tt.capacity || tt.into.Len() > tt.capacity {
+				t.Fatalf("capacity = %d, Len() = %d; want capacity %d", tt.into.capacity, tt.into.Len(), tt.capacity)
+			}
+			if tt.into.Exact() {
+				t.Fatal("Exact() = true after merging an overflowed counter")
+			}
+			if got := tt.into.MaxError(); got <= 0 {
+				t.Errorf("MaxError() = %d, want > 0", got)
+			}
+			tt.into.Add("/new", 1) // must not panic on the rebuilt heap
+			if top := tt.into.Top(1); len(top) != 1 || top[0].Key != "/page/99" {
+				t.Errorf("Top(1) = %+v, want /page/99", top)
+			}
+		})
+	}
+}
+
+func TestTopKJSON(t *testing.T) {
+	topk := NewTopK(10)
+	for i := 0; i < 100; i++ {
+		topk.Add(fmt.Sprintf("/page/%d", i%20), i)
+	}
+	data, err := json.Marshal(topk)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	restored := &TopK{}
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if restored.Total() != topk.Total() || restored.Exact() != topk.Exact() || restored.MaxError() != topk.MaxError() {
+		t.Errorf("restored Total/Exact/MaxError = %d/%v/%d, want %d/%v/%d",
+			restored.Total(), restored.Exact(), restored.MaxError(), topk.Total(), topk.Exact(), topk.MaxError())
+	}
+	got, want := restored.Top(10), topk.Top(10)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Top(10)[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// The restored counter must keep working as a bounded counter.
+	restored.Add("/new", 1000)
+	if restored.Len() > 10 || restored.Top(1)[0].Key != "/new" {
+		t.Errorf("restored counter did not accept new keys correctly: %+v", restored.Top(1))
+	}
+}
@@ -35,3 +35,18 @@ func addCommas(num int) string {
 	}
 	return result
 }
+
+// FormatBytes formats a byte count with a binary unit suffix.
+// Example: 1536 -> "1.5 KiB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -25,3 +25,26 @@ func TestFormatNumber(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    int64
+		expected string
+	}{
+		{"zero", 0, "0 B"},
+		{"bytes", 1023, "1023 B"},
+		{"kibibytes", 1536, "1.5 KiB"},
+		{"mebibytes", 5 * 1024 * 1024, "5.0 MiB"},
+		{"gibibytes", 3 * 1024 * 1024 * 1024, "3.0 GiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FormatBytes(tt.input)
+			if result != tt.expected {
+				t.Errorf("FormatBytes(%d) = %s, want %s", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
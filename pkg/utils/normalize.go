@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxDecodePasses bounds repeated decoding. Real payloads rarely nest more
+// than two or three layers, and each pass costs a copy.
+const maxDecodePasses = 4
+
+// NormalizePayload returns the view of a request field that attack patterns
+// are matched against. Obfuscations that a server or database would undo are
+// undone here:
+//   - URL decoding (including %uXXXX and "+" as space), repeated until stable
+//   - HTML entity decoding (&lt;, &#60;, &#x3c;), repeated with the above
+//   - ASCII lowercasing (bytes that are not valid UTF-8 are kept as they are)
+//   - SQL block comments removed ("UNION/**/SELECT"); the content of MySQL
+//     versioned comments ("/*!50000SELECT*/") is kept
+//   - "--" and "#" line comments removed up to a newline
+//   - whitespace runs (including NUL and NBSP) collapsed to a single space
+//
+// Example: "UNION%2F%2A%2A%2FSELECT" -> "union select"
+func NormalizePayload(s string) string {
+	for i := 0; i < maxDecodePasses; i++ {
+		decoded := html.UnescapeString(percentDecode(s))
+		if decoded == s {
+			break
+		}
+		s = decoded
+	}
+	s = asciiLower(s)
+	s = stripComments(s)
+	return collapseSpace(s)
+}
+
+// percentDecode decodes %XX, %uXXXX and "+" leniently: malformed escapes are
+// kept as they are instead of failing the whole string.
+func percentDecode(s string) string {
+	if strings.IndexByte(s, '%') < 0 && strings.IndexByte(s, '+') < 0 {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '+':
+			sb.WriteByte(' ')
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			sb.WriteByte(unhex(s[i+1])<<4 | unhex(s[i+2]))
+			i += 2
+		case c == '%' && i+5 < len(s) && (s[i+1] == 'u' || s[i+1] == 'U') &&
+			isHex(s[i+2]) && isHex(s[i+3]) && isHex(s[i+4]) && isHex(s[i+5]):
+			r := rune(unhex(s[i+2]))<<12 | rune(unhex(s[i+3]))<<8 | rune(unhex(s[i+4]))<<4 | rune(unhex(s[i+5]))
+			sb.WriteRune(r)
+			i += 5
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// asciiLower lowercases A-Z only. Unlike strings.ToLower it leaves invalid
+// UTF-8, such as a lone 0xA0 from "%A0", untouched.
+func asciiLower(s string) string {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c >= 'A' && c <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				if b[j] >= 'A' && b[j] <= 'Z' {
+					b[j] += 'a' - 'A'
+				}
+			}
+			return string(b)
+		}
+	}
+	return s
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// stripComments replaces SQL comments with a space. Block comments run to
+// "*/" (or the end of s); line comments are only removed when a newline ends
+// them, since in a single-line request a trailing "--" or "#" hides nothing.
+func stripComments(s string) string {
+	if !strings.Contains(s, "/*") && !strings.Contains(s, "--") && strings.IndexByte(s, '#') < 0 {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		switch {
+		case strings.HasPrefix(s[i:], "/*!"):
+			// MySQL executes the body of a versioned comment: drop the
+			// marker and version number, keep the body.
+			i += 3
+			for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+				i++
+			}
+			sb.WriteByte(' ')
+			i--
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			if end < 0 {
+				i = len(s)
+			} else {
+				i += 2 + end + 1
+			}
+			sb.WriteByte(' ')
+		case strings.HasPrefix(s[i:], "*/"):
+			i++
+			sb.WriteByte(' ')
+		case s[i] == '#' || strings.HasPrefix(s[i:], "--"):
+			if nl := strings.IndexByte(s[i:], '\n'); nl >= 0 {
+				i += nl
+				sb.WriteByte(' ')
+			} else {
+				sb.WriteByte(s[i])
+			}
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
+
+// collapseSpace replaces every run of whitespace with a single space and
+// trims both ends.
+func collapseSpace(s string) string {
+	clean := len(s) == 0 || (s[0] != ' ' && s[len(s)-1] != ' ')
+	for i := 0; clean && i < len(s); i++ {
+		c := s[i]
+		clean = c != '\t' && c != '\n' && c != '\r' && c != '\v' && c != '\f' && c != 0 && c < 0x80 &&
+			(c != ' ' || s[i+1] != ' ')
+	}
+	if clean {
+		return s
+	}
+	var sb strings.Builder
+	sb.Grow(len(s))
+	pending := false
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if isPayloadSpace(s[i], r) {
+			pending = sb.Len() > 0
+		} else {
+			if pending {
+				sb.WriteByte(' ')
+				pending = false
+			}
+			sb.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return sb.String()
+}
+
+// isPayloadSpace reports whether c (the first byte of r) separates tokens for
+// a web server or database: ASCII whitespace, NUL, and NBSP either as a rune
+// or as the raw byte 0xA0 left by decoding %A0.
+func isPayloadSpace(c byte, r rune) bool {
+	switch c {
+	case ' ', '\t', '\n', '\r', '\v', '\f', 0:
+		return true
+	}
+	return r == '\u00a0' || (r == utf8.RuneError && c == 0xa0)
+}
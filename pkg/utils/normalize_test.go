@@ -0,0 +1,37 @@
+package utils
+
+import "testing"
+
+func TestNormalizePayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain", "/about/", "/about/"},
+		{"percent encoded space", "/?id=1%20UNION%20SELECT", "/?id=1 union select"},
+		{"plus as space", "/?q=1+or+1=1", "/?q=1 or 1=1"},
+		{"double encoded", "/?id=1%2527%2520or%25201=1", "/?id=1' or 1=1"},
+		{"iis unicode escape", "/?q=%u003cscript%u003e", "/?q=<script>"},
+		{"malformed escape kept", "/100%zz", "/100%zz"},
+		{"block comment", "/?id=1 UNION/**/SELECT", "/?id=1 union select"},
+		{"encoded block comment", "/?id=1%20UNION%2F%2A%2A%2FSELECT", "/?id=1 union select"},
+		{"versioned comment", "/?id=1 /*!50000UNION*/ /*!SELECT*/", "/?id=1 union select"},
+		{"line comment ended by newline", "/?id=1 union--x%0Aselect", "/?id=1 union select"},
+		{"trailing line comment kept", "/?id=1' or 1=1-- -", "/?id=1' or 1=1-- -"},
+		{"html entities", "/?q=&lt;script&#62;alert&#x28;1)", "/?q=<script>alert(1)"},
+		{"encoded entity", "/?q=%26lt%3Bsvg onload%3Dalert(1)%26gt%3B", "/?q=<svg onload=alert(1)>"},
+		{"whitespace collapsed", "/?q=union%09%0D%0A  select%00from", "/?q=union select from"},
+		{"nbsp collapsed", "/?q=union%A0select%C2%A0from", "/?q=union select from"},
+		{"trimmed", "%20%20/x%20%20", "/x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NormalizePayload(tt.input)
+			if result != tt.expected {
+				t.Errorf("NormalizePayload(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+// Package vulndb loads a local list of known-vulnerable WordPress plugins and
+// themes, from JSON or CSV, and looks components up by slug. Each entry names
+// the component, the versions it affects and its CVE, so probes seen in the
+// logs can be matched against vulnerabilities that matter.
+package vulndb
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Component types. An entry without a type matches both.
+const (
+	TypePlugin = "plugin"
+	TypeTheme  = "theme"
+)
+
+// Vulnerability is one entry of the database. Versions lists the affected
+// versions as comma-separated constraints such as "< 5.3.2" or
+// ">= 6.0, <= 6.8"; empty or "*" means every version.
+type Vulnerability struct {
+	Type     string `json:"type"`
+	Slug     string `json:"slug"`
+	Versions string `json:"versions"`
+	CVE      string `json:"cve"`
+	Title    string `json:"title"`
+
+	constraints []constraint
+}
+
+// DB is a loaded vulnerability database; it is read-only and safe for
+// concurrent use. A nil DB contains nothing.
+type DB struct {
+	vulns  []Vulnerability
+	bySlug map[string][]int
+}
+
+// Load reads a database from path: CSV when the file ends in .csv, JSON
+// otherwise.
+func Load(path string) (*DB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ParseCSV(f)
+	}
+	return ParseJSON(f)
+}
+
+// ParseJSON reads a JSON array of entries.
+func ParseJSON(r io.Reader) (*DB, error) {
+	var vulns []Vulnerability
+	if err := json.NewDecoder(r).Decode(&vulns); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return build(vulns, func(i int) string { return fmt.Sprintf("entry %d", i+1) })
+}
+
+// ParseCSV reads CSV with a header row. The slug, versions and cve columns
+// are required; type and title are optional. Columns may be in any order.
+func ParseCSV(r io.Reader) (*DB, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("missing CSV header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"slug", "versions", "cve"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header is missing the %q column", required)
+		}
+	}
+	column := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	reader.FieldsPerRecord = -1
+	var vulns []Vulnerability
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		vulns = append(vulns, Vulnerability{
+			Type:     column(record, "type"),
+			Slug:     column(record, "slug"),
+			Versions: column(record, "versions"),
+			CVE:      column(record, "cve"),
+			Title:    column(record, "title"),
+		})
+		lines = append(lines, line)
+	}
+	return build(vulns, func(i int) string { return "line " + strconv.Itoa(lines[i]) })
+}
+
+func build(vulns []Vulnerability, position func(int) string) (*DB, error) {
+	db := &DB{vulns: vulns, bySlug: make(map[string][]int)}
+	for i := range db.vulns {
+		v := &db.vulns[i]
+		v.Type = strings.ToLower(strings.TrimSpace(v.Type))
+		v.Slug = strings.ToLower(strings.TrimSpace(v.Slug))
+		switch {
+		case v.Slug == "":
+			return nil, fmt.Errorf("%s: missing slug", position(i))
+		case v.Type != "" && v.Type != TypePlugin && v.Type != TypeTheme:
+			return nil, fmt.Errorf("%s: unknown type %q", position(i), v.Type)
+		}
+		constraints, err := parseConstraints(v.Versions)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", position(i), err)
+		}
+		v.constraints = constraints
+		db.bySlug[v.Slug] = append(db.bySlug[v.Slug], i)
+	}
+	return db, nil
+}
+
+// Len returns the number of entries.
+func (db *DB) Len() int {
+	if db == nil {
+		return 0
+	}
+	return len(db.vulns)
+}
+
+// Lookup returns the entries for a component, in file order. kind is
+// TypePlugin or TypeTheme; slug is compared case-insensitively.
+func (db *DB) Lookup(kind, slug string) []Vulnerability {
+	if db == nil {
+		return nil
+	}
+	var result []Vulnerability
+	for _, i := range db.bySlug[strings.ToLower(slug)] {
+		if v := db.vulns[i]; v.Type == "" || v.Type == kind {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Affects reports whether version is within the affected versions.
+func (v Vulnerability) Affects(version string) bool {
+	for _, c := range v.constraints {
+		if !c.allows(version) {
+			return false
+		}
+	}
+	return true
+}
+
+type constraint struct {
+	op      string
+	version string
+}
+
+var constraintOps = []string{"<=", ">=", "<", ">", "="}
+
+func parseConstraints(s string) ([]constraint, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == "*" {
+		return nil, nil
+	}
+	var result []constraint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		c := constraint{op: "="}
+		for _, op := range constraintOps {
+			if rest, ok := strings.CutPrefix(part, op); ok {
+				c.op, part = op, strings.TrimSpace(rest)
+				break
+			}
+		}
+		if part == "" || strings.ContainsAny(part, " <>=") {
+			return nil, fmt.Errorf("invalid version constraint %q", s)
+		}
+		c.version = part
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func (c constraint) allows(version string) bool {
+	order := CompareVersions(version, c.version)
+	switch c.op {
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	default:
+		return order == 0
+	}
+}
+
+// CompareVersions compares dotted versions such as "5.3.2" and "5.10":
+// numeric segments numerically, others as strings, and a missing segment as
+// zero. It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	as := strings.FieldsFunc(a, isVersionSeparator)
+	bs := strings.FieldsFunc(b, isVersionSeparator)
+	for i := 0; i < max(len(as), len(bs)); i++ {
+		x, y := "0", "0"
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xerr := strconv.Atoi(x)
+		yn, yerr := strconv.Atoi(y)
+		switch {
+		case xerr == nil && yerr == nil:
+			if xn != yn {
+				return cmp.Compare(xn, yn)
+			}
+		case x != y:
+			return strings.Compare(strings.ToLower(x), strings.ToLower(y))
+		}
+	}
+	return 0
+}
+
+func isVersionSeparator(r rune) bool {
+	return r == '.' || r == '-' || r == '_' || r == '+'
+}
@@ -0,0 +1,155 @@
+package vulndb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const csvSample = `# slug, affected versions, CVE
+slug,versions,cve,type,title
+wp-file-manager,">= 6.0, <= 6.8",CVE-2020-25213,plugin,Unauthenticated file upload
+contact-form-7,< 5.3.2,CVE-2020-35489,plugin,Unrestricted file upload
+duplicator,< 1.3.28,CVE-2020-11738,,Directory traversal
+`
+
+const jsonSample = `[
+  {"slug": "Elementor", "type": "plugin", "versions": ">= 3.6.0, <= 3.6.2", "cve": "CVE-2022-1329", "title": "Authenticated RCE"},
+  {"slug": "duplicator", "type": "theme", "versions": "*", "cve": "CVE-0000-0001"}
+]`
+
+func TestParseCSV(t *testing.T) {
+	db, err := ParseCSV(strings.NewReader(csvSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", db.Len())
+	}
+	vulns := db.Lookup(TypePlugin, "WP-File-Manager")
+	if len(vulns) != 1 || vulns[0].CVE != "CVE-2020-25213" || vulns[0].Title != "Unauthenticated file upload" {
+		t.Fatalf("Lookup(wp-file-manager) = %+v", vulns)
+	}
+	if got := db.Lookup(TypeTheme, "contact-form-7"); len(got) != 0 {
+		t.Errorf("plugin entry matched a theme: %+v", got)
+	}
+	// An entry without a type matches plugins and themes.
+	if got := db.Lookup(TypeTheme, "duplicator"); len(got) != 1 {
+		t.Errorf("Lookup(theme, duplicator) = %+v, want 1 entry", got)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	db, err := ParseJSON(strings.NewReader(jsonSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := db.Lookup(TypePlugin, "elementor"); len(got) != 1 || got[0].Slug != "elementor" {
+		t.Fatalf("Lookup(elementor) = %+v", got)
+	}
+	if got := db.Lookup(TypePlugin, "duplicator"); len(got) != 0 {
+		t.Errorf("theme entry matched a plugin: %+v", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func() error
+		want  string
+	}{
+		{"missing column", func() error {
+			_, err := ParseCSV(strings.NewReader("slug,cve\nfoo,CVE-1\n"))
+			return err
+		}, `"versions"`},
+		{"missing slug", func() error {
+			_, err := ParseCSV(strings.NewReader("slug,versions,cve\nfoo,<1,CVE-1\n,<1,CVE-2\n"))
+			return err
+		}, "line 3: missing slug"},
+		{"bad constraint", func() error {
+			_, err := ParseJSON(strings.NewReader(`[{"slug":"foo","versions":"<= 1.0 2.0"}]`))
+			return err
+		}, "entry 1: invalid version constraint"},
+		{"bad type", func() error {
+			_, err := ParseJSON(strings.NewReader(`[{"slug":"foo","type":"core"}]`))
+			return err
+		}, `unknown type "core"`},
+	}
+	for _, tt := range tests {
+		err := tt.parse()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error = %v, want it to contain %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestAffects(t *testing.T) {
+	db, err := ParseCSV(strings.NewReader(csvSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileManager := db.Lookup(TypePlugin, "wp-file-manager")[0]
+	cf7 := db.Lookup(TypePlugin, "contact-form-7")[0]
+	tests := []struct {
+		vuln    Vulnerability
+		version string
+		want    bool
+	}{
+		{fileManager, "6.0", true},
+		{fileManager, "6.8", true},
+		{fileManager, "6.9", false},
+		{fileManager, "5.9.1", false},
+		{cf7, "5.3.1", true},
+		{cf7, "5.3.2", false},
+		{cf7, "5.10", false},
+		{Vulnerability{}, "1.0", true},
+	}
+	for _, tt := range tests {
+		if got := tt.vuln.Affects(tt.version); got != tt.want {
+			t.Errorf("%s %q Affects(%q) = %v, want %v", tt.vuln.Slug, tt.vuln.Versions, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"5.3.2", "5.3.2", 0},
+		{"5.3", "5.3.0", 0},
+		{"5.10", "5.9", 1},
+		{"1.3.27", "1.3.28", -1},
+		{"2.0-RC1", "2.0-rc2", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	csvPath := filepath.Join(dir, "vulns.CSV")
+	jsonPath := filepath.Join(dir, "vulns.json")
+	if err := os.WriteFile(csvPath, []byte(csvSample), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(jsonPath, []byte(jsonSample), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]int{csvPath: 3, jsonPath: 2} {
+		db, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load(%s): %v", path, err)
+		}
+		if db.Len() != want {
+			t.Errorf("Load(%s).Len() = %d, want %d", path, db.Len(), want)
+		}
+	}
+	if _, err := Load(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Load of a missing file succeeded")
+	}
+}